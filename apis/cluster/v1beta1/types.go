@@ -29,12 +29,43 @@ type ProviderCredentials struct {
 // A ProviderConfigStatus reflects the observed state of a ProviderConfig.
 type ProviderConfigStatus struct {
 	xpv1.ProviderConfigStatus `json:",inline"`
+
+	// ServerVersion is the Keycloak server version detected by the most
+	// recent health check.
+	// +optional
+	ServerVersion string `json:"serverVersion,omitempty"`
+
+	// ActiveEndpoint is the Keycloak endpoint the most recent health check
+	// connected to. It differs from the configured url after a failover.
+	// +optional
+	ActiveEndpoint string `json:"activeEndpoint,omitempty"`
+
+	// Realm is the realm the provider authenticated against in the most
+	// recent health check.
+	// +optional
+	Realm string `json:"realm,omitempty"`
+
+	// TokenExpiry is the expiry time of the access token obtained by the
+	// most recent health check.
+	// +optional
+	TokenExpiry *metav1.Time `json:"tokenExpiry,omitempty"`
+
+	// LastCheckTime is the time of the most recent health check.
+	// +optional
+	LastCheckTime *metav1.Time `json:"lastCheckTime,omitempty"`
+
+	// LastError is the error of the most recent health check. It is empty
+	// when the check succeeded.
+	// +optional
+	LastError string `json:"lastError,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 
 // A ProviderConfig configures a keycloak provider.
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="HEALTHY",type="string",JSONPath=".status.conditions[?(@.type=='Healthy')].status"
+// +kubebuilder:printcolumn:name="VERSION",type="string",JSONPath=".status.serverVersion"
 // +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
 // +kubebuilder:printcolumn:name="SECRET-NAME",type="string",JSONPath=".spec.credentials.secretRef.name",priority=1
 // +kubebuilder:resource:scope=Cluster
@@ -13,20 +13,292 @@ import (
 
 // A ProviderConfigSpec defines the desired state of a ProviderConfig.
 type ClusterProviderConfigSpec struct {
-	// Credentials required to authenticate to this provider.
+	// Credentials required to authenticate to this provider. Settings read
+	// from the credentials are overridden by the typed fields of this spec.
+	// Use source None to configure the provider from the typed fields only.
 	Credentials ClusterProviderCredentials `json:"credentials"`
+
+	ConnectionConfig `json:",inline"`
+
+	// PasswordSecretRef references the password of the password grant.
+	// +optional
+	PasswordSecretRef *xpv1.SecretKeySelector `json:"passwordSecretRef,omitempty"`
+
+	// ClientSecretSecretRef references the secret of the client.
+	// +optional
+	ClientSecretSecretRef *xpv1.SecretKeySelector `json:"clientSecretSecretRef,omitempty"`
+
+	// TLSClientPrivateKeySecretRef references the PEM encoded private key of
+	// the TLS client certificate.
+	// +optional
+	TLSClientPrivateKeySecretRef *xpv1.SecretKeySelector `json:"tlsClientPrivateKeySecretRef,omitempty"`
+
+	// JWTSigningKeySecretRef references the key used to sign the JWT of the
+	// client JWT grant.
+	// +optional
+	JWTSigningKeySecretRef *xpv1.SecretKeySelector `json:"jwtSigningKeySecretRef,omitempty"`
+
+	// TLSSecretRef references a kubernetes.io/tls Secret, e.g. issued by
+	// cert-manager, whose tls.crt and tls.key are presented to the server as
+	// client certificate. They override tlsClientCertificate and
+	// tlsClientPrivateKeySecretRef, and renewed certificates are picked up
+	// without a restart.
+	// +optional
+	TLSSecretRef *xpv1.SecretReference `json:"tlsSecretRef,omitempty"`
+
+	// RootCAConfigMapRef references a ConfigMap key holding the PEM encoded CA
+	// bundle used to verify the server certificate. It overrides
+	// rootCaCertificate.
+	// +optional
+	RootCAConfigMapRef *ConfigMapKeySelector `json:"rootCaConfigMapRef,omitempty"`
+
+	// RateLimit bounds the rate of operations against Keycloak. Operations
+	// are not rate limited if unset.
+	// +optional
+	RateLimit *RateLimit `json:"rateLimit,omitempty"`
+
+	// AllowedRealms are the Keycloak realms that managed resources using this
+	// ProviderConfig may manage. All realms are allowed if empty.
+	// +optional
+	AllowedRealms []string `json:"allowedRealms,omitempty"`
+
+	// AllowedNamespaces are the namespaces whose managed resources may use
+	// this ClusterProviderConfig. All namespaces are allowed if empty.
+	// +optional
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+
+	// CleanupOrphanedSessions makes the provider record the Keycloak
+	// sessions it starts with the credentials of this ClusterProviderConfig, and
+	// log out the ones left behind by a previous provider process, e.g. one
+	// that crashed, when it starts.
+	// +optional
+	CleanupOrphanedSessions bool `json:"cleanupOrphanedSessions,omitempty"`
+
+	// AdoptionPolicy is the default of the managed resources using this
+	// ClusterProviderConfig for what to do when the Keycloak object they would
+	// create already exists. The provider-keycloak.crossplane.io/adoption-policy
+	// annotation of a managed resource overrides it. Defaults to Adopt.
+	// +optional
+	AdoptionPolicy AdoptionPolicy `json:"adoptionPolicy,omitempty"`
 }
 
 // A ProviderConfigSpec defines the desired state of a ProviderConfig.
 type ProviderConfigSpec struct {
-	// CredentialsSecretRef required to authenticate to this provider.
-	CredentialsSecretRef ProviderCredentials `json:"credentialsSecretRef"`
+	// CredentialsSecretRef references credentials to authenticate to this
+	// provider. Settings read from the Secret are overridden by the typed
+	// fields of this spec. If unset, the provider is configured from the
+	// typed fields only.
+	// +optional
+	CredentialsSecretRef *ProviderCredentials `json:"credentialsSecretRef,omitempty"`
+
+	ConnectionConfig `json:",inline"`
+
+	// PasswordSecretRef references the password of the password grant.
+	// +optional
+	PasswordSecretRef *xpv1.LocalSecretKeySelector `json:"passwordSecretRef,omitempty"`
+
+	// ClientSecretSecretRef references the secret of the client.
+	// +optional
+	ClientSecretSecretRef *xpv1.LocalSecretKeySelector `json:"clientSecretSecretRef,omitempty"`
+
+	// TLSClientPrivateKeySecretRef references the PEM encoded private key of
+	// the TLS client certificate.
+	// +optional
+	TLSClientPrivateKeySecretRef *xpv1.LocalSecretKeySelector `json:"tlsClientPrivateKeySecretRef,omitempty"`
+
+	// JWTSigningKeySecretRef references the key used to sign the JWT of the
+	// client JWT grant.
+	// +optional
+	JWTSigningKeySecretRef *xpv1.LocalSecretKeySelector `json:"jwtSigningKeySecretRef,omitempty"`
+
+	// TLSSecretRef references a kubernetes.io/tls Secret, e.g. issued by
+	// cert-manager, whose tls.crt and tls.key are presented to the server as
+	// client certificate. They override tlsClientCertificate and
+	// tlsClientPrivateKeySecretRef, and renewed certificates are picked up
+	// without a restart.
+	// +optional
+	TLSSecretRef *xpv1.LocalSecretReference `json:"tlsSecretRef,omitempty"`
+
+	// RootCAConfigMapRef references a ConfigMap key holding the PEM encoded CA
+	// bundle used to verify the server certificate. It overrides
+	// rootCaCertificate.
+	// +optional
+	RootCAConfigMapRef *LocalConfigMapKeySelector `json:"rootCaConfigMapRef,omitempty"`
+
+	// RateLimit bounds the rate of operations against Keycloak. Operations
+	// are not rate limited if unset.
+	// +optional
+	RateLimit *RateLimit `json:"rateLimit,omitempty"`
+
+	// AllowedRealms are the Keycloak realms that managed resources using this
+	// ProviderConfig may manage. All realms are allowed if empty.
+	// +optional
+	AllowedRealms []string `json:"allowedRealms,omitempty"`
+
+	// CleanupOrphanedSessions makes the provider record the Keycloak
+	// sessions it starts with the credentials of this ProviderConfig, and
+	// log out the ones left behind by a previous provider process, e.g. one
+	// that crashed, when it starts.
+	// +optional
+	CleanupOrphanedSessions bool `json:"cleanupOrphanedSessions,omitempty"`
+
+	// AdoptionPolicy is the default of the managed resources using this
+	// ProviderConfig for what to do when the Keycloak object they would
+	// create already exists. The provider-keycloak.crossplane.io/adoption-policy
+	// annotation of a managed resource overrides it. Defaults to Adopt.
+	// +optional
+	AdoptionPolicy AdoptionPolicy `json:"adoptionPolicy,omitempty"`
+}
+
+// AdoptionPolicy selects what a managed resource does when the Keycloak object
+// it would create already exists, i.e. when a Keycloak object matches its
+// identifying properties, such as the realm and clientId of a client.
+// +kubebuilder:validation:Enum=Adopt;FailIfExists;AdoptIfUnmanaged
+type AdoptionPolicy string
+
+// Adoption policies.
+const (
+	// AdoptionPolicyAdopt adopts the existing object.
+	AdoptionPolicyAdopt AdoptionPolicy = "Adopt"
+	// AdoptionPolicyFailIfExists refuses to adopt the existing object.
+	AdoptionPolicyFailIfExists AdoptionPolicy = "FailIfExists"
+	// AdoptionPolicyAdoptIfUnmanaged adopts the existing object unless the
+	// ownership marker the provider writes on the objects it manages names
+	// another managed resource. Objects of kinds that cannot carry the marker
+	// are never adopted.
+	AdoptionPolicyAdoptIfUnmanaged AdoptionPolicy = "AdoptIfUnmanaged"
+)
+
+// RateLimit configures a token bucket that bounds the rate of operations
+// against Keycloak. Every create, read, update, delete and lookup takes one
+// token. An operation that finds the bucket empty is retried on a later
+// reconciliation instead of waiting for a token.
+type RateLimit struct {
+	// QPS is the sustained number of operations per second.
+	// +kubebuilder:validation:Minimum=1
+	QPS int `json:"qps"`
+
+	// Burst is the number of operations that may run at once when the bucket
+	// is full. Defaults to QPS.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Burst *int `json:"burst,omitempty"`
+}
+
+// ConfigMapKeySelector selects a key of a ConfigMap.
+type ConfigMapKeySelector struct {
+	// Name of the ConfigMap.
+	Name string `json:"name"`
+
+	// Namespace of the ConfigMap.
+	Namespace string `json:"namespace"`
+
+	// Key of the ConfigMap to select.
+	// +kubebuilder:default=ca.crt
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// LocalConfigMapKeySelector selects a key of a ConfigMap in the namespace of
+// the ProviderConfig.
+type LocalConfigMapKeySelector struct {
+	// Name of the ConfigMap.
+	Name string `json:"name"`
+
+	// Key of the ConfigMap to select.
+	// +kubebuilder:default=ca.crt
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// ConnectionConfig holds the non-secret settings used to connect to
+// Keycloak. Every field that is set overrides the matching key of the
+// credentials.
+type ConnectionConfig struct {
+	// URL of the Keycloak server, e.g. https://keycloak.example.com.
+	// +optional
+	URL *string `json:"url,omitempty"`
+
+	// FailoverURLs are further endpoints of the same Keycloak, e.g. in other
+	// sites, used in the order given when url is unreachable. The provider
+	// fails back to an earlier endpoint once it is reachable again.
+	// +optional
+	FailoverURLs []string `json:"failoverUrls,omitempty"`
+
+	// BasePath of the Keycloak server, e.g. /auth for legacy distributions.
+	// +optional
+	BasePath *string `json:"basePath,omitempty"`
+
+	// AdminURL of the Keycloak server, if the admin API is served from a
+	// different URL than the token endpoint.
+	// +optional
+	AdminURL *string `json:"adminUrl,omitempty"`
+
+	// Realm to authenticate against. Defaults to master.
+	// +optional
+	Realm *string `json:"realm,omitempty"`
+
+	// ClientID of the client used to authenticate.
+	// +optional
+	ClientID *string `json:"clientId,omitempty"`
+
+	// Username of the password grant.
+	// +optional
+	Username *string `json:"username,omitempty"`
+
+	// InitialLogin controls whether the provider logs in when the client is
+	// created rather than on its first request.
+	// +optional
+	InitialLogin *bool `json:"initialLogin,omitempty"`
+
+	// ClientTimeout is the timeout of requests to Keycloak in seconds.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	ClientTimeout *int `json:"clientTimeout,omitempty"`
+
+	// TLSInsecureSkipVerify disables the verification of the server
+	// certificate.
+	// +optional
+	TLSInsecureSkipVerify *bool `json:"tlsInsecureSkipVerify,omitempty"`
+
+	// RootCACertificate is a PEM encoded CA certificate used to verify the
+	// server certificate.
+	// +optional
+	RootCACertificate *string `json:"rootCaCertificate,omitempty"`
+
+	// TLSClientCertificate is a PEM encoded client certificate presented to
+	// the server.
+	// +optional
+	TLSClientCertificate *string `json:"tlsClientCertificate,omitempty"`
+
+	// RedHatSSO must be set when the server is a Red Hat Single Sign-On
+	// instance.
+	// +optional
+	RedHatSSO *bool `json:"redHatSso,omitempty"`
+
+	// AdditionalHeaders are sent with every request to Keycloak.
+	// +optional
+	AdditionalHeaders map[string]string `json:"additionalHeaders,omitempty"`
+
+	// JWTSigningAlg is the algorithm used to sign the JWT of the client JWT
+	// grant.
+	// +optional
+	JWTSigningAlg *string `json:"jwtSigningAlg,omitempty"`
+
+	// JWTTokenFile is the path of a file holding the JWT of the client JWT
+	// grant.
+	// +optional
+	JWTTokenFile *string `json:"jwtTokenFile,omitempty"`
 }
 
 // ProviderCredentials required to authenticate.
 type ClusterProviderCredentials struct {
-	// Source of the provider credentials.
-	// +kubebuilder:validation:Enum=None;Secret;Environment;Filesystem
+	// Source of the provider credentials. InjectedIdentity authenticates
+	// with the provider's projected ServiceAccount token as the client
+	// assertion of the client JWT grant. The token is read from fs.path, or
+	// from /var/run/secrets/keycloak.crossplane.io/serviceaccount/token if
+	// unset.
+	// +kubebuilder:validation:Enum=None;Secret;Environment;Filesystem;InjectedIdentity
 	Source xpv1.CredentialsSource `json:"source"`
 
 	xpv1.CommonCredentialSelectors `json:",inline"`
@@ -40,12 +312,44 @@ type ProviderCredentials struct {
 // A ProviderConfigStatus reflects the observed state of a ProviderConfig.
 type ProviderConfigStatus struct {
 	xpv1.ProviderConfigStatus `json:",inline"`
+
+	// ServerVersion is the Keycloak server version detected by the most
+	// recent health check.
+	// +optional
+	ServerVersion string `json:"serverVersion,omitempty"`
+
+	// ActiveEndpoint is the Keycloak endpoint the most recent health check
+	// connected to. It differs from the configured url after a failover.
+	// +optional
+	ActiveEndpoint string `json:"activeEndpoint,omitempty"`
+
+	// Realm is the realm the provider authenticated against in the most
+	// recent health check.
+	// +optional
+	Realm string `json:"realm,omitempty"`
+
+	// TokenExpiry is the expiry time of the access token obtained by the
+	// most recent health check.
+	// +optional
+	TokenExpiry *metav1.Time `json:"tokenExpiry,omitempty"`
+
+	// LastCheckTime is the time of the most recent health check.
+	// +optional
+	LastCheckTime *metav1.Time `json:"lastCheckTime,omitempty"`
+
+	// LastError is the error of the most recent health check. It is empty
+	// when the check succeeded.
+	// +optional
+	LastError string `json:"lastError,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 
 // A ProviderConfig configures a keycloak provider.
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="URL",type="string",JSONPath=".spec.url"
+// +kubebuilder:printcolumn:name="HEALTHY",type="string",JSONPath=".status.conditions[?(@.type=='Healthy')].status"
+// +kubebuilder:printcolumn:name="VERSION",type="string",JSONPath=".status.serverVersion"
 // +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
 // +kubebuilder:printcolumn:name="SECRET-NAME",type="string",JSONPath=".spec.credentials.secretRef.name",priority=1
 // +kubebuilder:resource:scope=Namespaced
@@ -95,6 +399,9 @@ type ProviderConfigUsageList struct {
 
 // A ClusterProviderConfig configures a keycloak provider.
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="URL",type="string",JSONPath=".spec.url"
+// +kubebuilder:printcolumn:name="HEALTHY",type="string",JSONPath=".status.conditions[?(@.type=='Healthy')].status"
+// +kubebuilder:printcolumn:name="VERSION",type="string",JSONPath=".status.serverVersion"
 // +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
 // +kubebuilder:printcolumn:name="SECRET-NAME",type="string",JSONPath=".spec.credentials.secretRef.name",priority=1
 // +kubebuilder:resource:scope=Cluster
@@ -9,6 +9,7 @@ Copyright 2022 Upbound Inc.
 package v1beta1
 
 import (
+	"github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -75,6 +76,52 @@ func (in *ClusterProviderConfigList) DeepCopyObject() runtime.Object {
 func (in *ClusterProviderConfigSpec) DeepCopyInto(out *ClusterProviderConfigSpec) {
 	*out = *in
 	in.Credentials.DeepCopyInto(&out.Credentials)
+	in.ConnectionConfig.DeepCopyInto(&out.ConnectionConfig)
+	if in.PasswordSecretRef != nil {
+		in, out := &in.PasswordSecretRef, &out.PasswordSecretRef
+		*out = new(v1.SecretKeySelector)
+		**out = **in
+	}
+	if in.ClientSecretSecretRef != nil {
+		in, out := &in.ClientSecretSecretRef, &out.ClientSecretSecretRef
+		*out = new(v1.SecretKeySelector)
+		**out = **in
+	}
+	if in.TLSClientPrivateKeySecretRef != nil {
+		in, out := &in.TLSClientPrivateKeySecretRef, &out.TLSClientPrivateKeySecretRef
+		*out = new(v1.SecretKeySelector)
+		**out = **in
+	}
+	if in.JWTSigningKeySecretRef != nil {
+		in, out := &in.JWTSigningKeySecretRef, &out.JWTSigningKeySecretRef
+		*out = new(v1.SecretKeySelector)
+		**out = **in
+	}
+	if in.TLSSecretRef != nil {
+		in, out := &in.TLSSecretRef, &out.TLSSecretRef
+		*out = new(v1.SecretReference)
+		**out = **in
+	}
+	if in.RootCAConfigMapRef != nil {
+		in, out := &in.RootCAConfigMapRef, &out.RootCAConfigMapRef
+		*out = new(ConfigMapKeySelector)
+		**out = **in
+	}
+	if in.RateLimit != nil {
+		in, out := &in.RateLimit, &out.RateLimit
+		*out = new(RateLimit)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AllowedRealms != nil {
+		in, out := &in.AllowedRealms, &out.AllowedRealms
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedNamespaces != nil {
+		in, out := &in.AllowedNamespaces, &out.AllowedNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterProviderConfigSpec.
@@ -103,6 +150,133 @@ func (in *ClusterProviderCredentials) DeepCopy() *ClusterProviderCredentials {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMapKeySelector) DeepCopyInto(out *ConfigMapKeySelector) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMapKeySelector.
+func (in *ConfigMapKeySelector) DeepCopy() *ConfigMapKeySelector {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMapKeySelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConnectionConfig) DeepCopyInto(out *ConnectionConfig) {
+	*out = *in
+	if in.URL != nil {
+		in, out := &in.URL, &out.URL
+		*out = new(string)
+		**out = **in
+	}
+	if in.FailoverURLs != nil {
+		in, out := &in.FailoverURLs, &out.FailoverURLs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.BasePath != nil {
+		in, out := &in.BasePath, &out.BasePath
+		*out = new(string)
+		**out = **in
+	}
+	if in.AdminURL != nil {
+		in, out := &in.AdminURL, &out.AdminURL
+		*out = new(string)
+		**out = **in
+	}
+	if in.Realm != nil {
+		in, out := &in.Realm, &out.Realm
+		*out = new(string)
+		**out = **in
+	}
+	if in.ClientID != nil {
+		in, out := &in.ClientID, &out.ClientID
+		*out = new(string)
+		**out = **in
+	}
+	if in.Username != nil {
+		in, out := &in.Username, &out.Username
+		*out = new(string)
+		**out = **in
+	}
+	if in.InitialLogin != nil {
+		in, out := &in.InitialLogin, &out.InitialLogin
+		*out = new(bool)
+		**out = **in
+	}
+	if in.ClientTimeout != nil {
+		in, out := &in.ClientTimeout, &out.ClientTimeout
+		*out = new(int)
+		**out = **in
+	}
+	if in.TLSInsecureSkipVerify != nil {
+		in, out := &in.TLSInsecureSkipVerify, &out.TLSInsecureSkipVerify
+		*out = new(bool)
+		**out = **in
+	}
+	if in.RootCACertificate != nil {
+		in, out := &in.RootCACertificate, &out.RootCACertificate
+		*out = new(string)
+		**out = **in
+	}
+	if in.TLSClientCertificate != nil {
+		in, out := &in.TLSClientCertificate, &out.TLSClientCertificate
+		*out = new(string)
+		**out = **in
+	}
+	if in.RedHatSSO != nil {
+		in, out := &in.RedHatSSO, &out.RedHatSSO
+		*out = new(bool)
+		**out = **in
+	}
+	if in.AdditionalHeaders != nil {
+		in, out := &in.AdditionalHeaders, &out.AdditionalHeaders
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.JWTSigningAlg != nil {
+		in, out := &in.JWTSigningAlg, &out.JWTSigningAlg
+		*out = new(string)
+		**out = **in
+	}
+	if in.JWTTokenFile != nil {
+		in, out := &in.JWTTokenFile, &out.JWTTokenFile
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConnectionConfig.
+func (in *ConnectionConfig) DeepCopy() *ConnectionConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ConnectionConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocalConfigMapKeySelector) DeepCopyInto(out *LocalConfigMapKeySelector) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalConfigMapKeySelector.
+func (in *LocalConfigMapKeySelector) DeepCopy() *LocalConfigMapKeySelector {
+	if in == nil {
+		return nil
+	}
+	out := new(LocalConfigMapKeySelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ProviderConfig) DeepCopyInto(out *ProviderConfig) {
 	*out = *in
@@ -165,7 +339,52 @@ func (in *ProviderConfigList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ProviderConfigSpec) DeepCopyInto(out *ProviderConfigSpec) {
 	*out = *in
-	in.CredentialsSecretRef.DeepCopyInto(&out.CredentialsSecretRef)
+	if in.CredentialsSecretRef != nil {
+		in, out := &in.CredentialsSecretRef, &out.CredentialsSecretRef
+		*out = new(ProviderCredentials)
+		**out = **in
+	}
+	in.ConnectionConfig.DeepCopyInto(&out.ConnectionConfig)
+	if in.PasswordSecretRef != nil {
+		in, out := &in.PasswordSecretRef, &out.PasswordSecretRef
+		*out = new(v1.LocalSecretKeySelector)
+		**out = **in
+	}
+	if in.ClientSecretSecretRef != nil {
+		in, out := &in.ClientSecretSecretRef, &out.ClientSecretSecretRef
+		*out = new(v1.LocalSecretKeySelector)
+		**out = **in
+	}
+	if in.TLSClientPrivateKeySecretRef != nil {
+		in, out := &in.TLSClientPrivateKeySecretRef, &out.TLSClientPrivateKeySecretRef
+		*out = new(v1.LocalSecretKeySelector)
+		**out = **in
+	}
+	if in.JWTSigningKeySecretRef != nil {
+		in, out := &in.JWTSigningKeySecretRef, &out.JWTSigningKeySecretRef
+		*out = new(v1.LocalSecretKeySelector)
+		**out = **in
+	}
+	if in.TLSSecretRef != nil {
+		in, out := &in.TLSSecretRef, &out.TLSSecretRef
+		*out = new(v1.LocalSecretReference)
+		**out = **in
+	}
+	if in.RootCAConfigMapRef != nil {
+		in, out := &in.RootCAConfigMapRef, &out.RootCAConfigMapRef
+		*out = new(LocalConfigMapKeySelector)
+		**out = **in
+	}
+	if in.RateLimit != nil {
+		in, out := &in.RateLimit, &out.RateLimit
+		*out = new(RateLimit)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AllowedRealms != nil {
+		in, out := &in.AllowedRealms, &out.AllowedRealms
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProviderConfigSpec.
@@ -182,6 +401,14 @@ func (in *ProviderConfigSpec) DeepCopy() *ProviderConfigSpec {
 func (in *ProviderConfigStatus) DeepCopyInto(out *ProviderConfigStatus) {
 	*out = *in
 	in.ProviderConfigStatus.DeepCopyInto(&out.ProviderConfigStatus)
+	if in.TokenExpiry != nil {
+		in, out := &in.TokenExpiry, &out.TokenExpiry
+		*out = (*in).DeepCopy()
+	}
+	if in.LastCheckTime != nil {
+		in, out := &in.LastCheckTime, &out.LastCheckTime
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProviderConfigStatus.
@@ -267,3 +494,23 @@ func (in *ProviderCredentials) DeepCopy() *ProviderCredentials {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RateLimit) DeepCopyInto(out *RateLimit) {
+	*out = *in
+	if in.Burst != nil {
+		in, out := &in.Burst, &out.Burst
+		*out = new(int)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RateLimit.
+func (in *RateLimit) DeepCopy() *RateLimit {
+	if in == nil {
+		return nil
+	}
+	out := new(RateLimit)
+	in.DeepCopyInto(out)
+	return out
+}
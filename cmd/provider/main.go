@@ -10,11 +10,13 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/alecthomas/kingpin/v2"
 	xpcontroller "github.com/crossplane/crossplane-runtime/v2/pkg/controller"
 	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/event"
 	"github.com/crossplane/crossplane-runtime/v2/pkg/feature"
 	"github.com/crossplane/crossplane-runtime/v2/pkg/gate"
 	"github.com/crossplane/crossplane-runtime/v2/pkg/logging"
@@ -26,10 +28,12 @@ import (
 	"github.com/crossplane/upjet/v2/pkg/controller/conversion"
 	authv1 "k8s.io/api/authorization/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/tools/leaderelection/resourcelock"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	ctrlconfig "sigs.k8s.io/controller-runtime/pkg/config"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -38,15 +42,24 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	apisCluster "github.com/crossplane-contrib/provider-keycloak/apis/cluster"
+	clusterv1beta1 "github.com/crossplane-contrib/provider-keycloak/apis/cluster/v1beta1"
 	apisNamespaced "github.com/crossplane-contrib/provider-keycloak/apis/namespaced"
+	namespacedv1beta1 "github.com/crossplane-contrib/provider-keycloak/apis/namespaced/v1beta1"
 	"github.com/crossplane-contrib/provider-keycloak/config"
 	"github.com/crossplane-contrib/provider-keycloak/config/lookup"
 	resolverapis "github.com/crossplane-contrib/provider-keycloak/internal/apis"
 	"github.com/crossplane-contrib/provider-keycloak/internal/clients"
+	"github.com/crossplane-contrib/provider-keycloak/internal/clients/stalerefs"
+	"github.com/crossplane-contrib/provider-keycloak/internal/conditions"
+	"github.com/crossplane-contrib/provider-keycloak/internal/controller/clientcache"
 	controllerCluster "github.com/crossplane-contrib/provider-keycloak/internal/controller/cluster"
+	"github.com/crossplane-contrib/provider-keycloak/internal/controller/credentials"
+	"github.com/crossplane-contrib/provider-keycloak/internal/controller/dependents"
 	controllerNamespaced "github.com/crossplane-contrib/provider-keycloak/internal/controller/namespaced"
 	"github.com/crossplane-contrib/provider-keycloak/internal/features"
+	"github.com/crossplane-contrib/provider-keycloak/internal/keycloaksession"
 	"github.com/crossplane-contrib/provider-keycloak/internal/resilience"
+	"github.com/crossplane-contrib/provider-keycloak/internal/tfconcurrency"
 )
 
 const (
@@ -76,6 +89,18 @@ func main() {
 		maxConcurrentReconciles = app.Flag("max-concurrent-reconciles", "The maximum number of concurrent reconcile operations per controller.").Default("5").Int()
 		cacheSyncTimeout        = app.Flag("cache-sync-timeout", "The per-controller timeout for waiting for the informer caches to sync, such as 2m or 10m.").Default("10m").Envar("CACHE_SYNC_TIMEOUT").Duration()
 		keycloakClientPoolSize  = app.Flag("keycloak-client-pool-size", "The maximum number of Keycloak client connections created per provider configuration to serve concurrent operations safely.").Default("5").Int()
+		clientCacheTTL          = app.Flag("client-cache-ttl", "The maximum time a cached Keycloak client is reused before it is logged out and recreated, such as 12h. 0, the default, disables it.").Default("0").Duration()
+		clientIdleTimeout       = app.Flag("client-idle-timeout", "The time after which an unused cached Keycloak client is logged out and evicted, and unused pooled clients are closed, such as 1h. 0, the default, disables it.").Default("0").Duration()
+		breakerThreshold        = app.Flag("circuit-breaker-threshold", "The number of consecutive connection or server errors after which operations against a Keycloak are short-circuited. 0 disables the circuit breaker.").Default("5").Int()
+		breakerBackoff          = app.Flag("circuit-breaker-backoff", "The time an open circuit breaker waits before it lets a single probe through. It doubles with every failed probe.").Default("5s").Duration()
+		breakerMaxBackoff       = app.Flag("circuit-breaker-max-backoff", "The maximum time an open circuit breaker waits before it lets a probe through.").Default("5m").Duration()
+		controllerRetryBackoff  = app.Flag("controller-retry-backoff", "The time to wait before a managed resource controller that failed to start is set up again. It doubles with every failed retry.").Default("30s").Duration()
+		controllerRetryMax      = app.Flag("controller-retry-max-backoff", "The maximum time to wait before a managed resource controller that failed to start is set up again.").Default("10m").Duration()
+		enabledKinds            = app.Flag("enabled-kinds", "The managed resource kinds whose controllers are set up, as comma separated API groups such as role.keycloak.m.crossplane.io, first labels of API groups such as role, or kinds such as Role.role or Realm. All kinds are enabled if none are given.").Envar("ENABLED_KINDS").Strings()
+		lazyControllers         = app.Flag("lazy-controllers", "Set up the controller of a managed resource kind only once there is at least one resource of it.").Default("false").Envar("LAZY_CONTROLLERS").Bool()
+		lazyPollInterval        = app.Flag("lazy-controller-poll-interval", "How often controllers that are set up lazily check for resources of their kinds.").Default("1m").Duration()
+		lookupCacheTTL          = app.Flag("lookup-cache-ttl", "The time the listings of the clients, roles, groups, client scopes and components of a realm are reused by the lookups of existing resources, such as 30s. 0 disables it.").Default("30s").Duration()
+		sessionLedgerNamespace  = app.Flag("session-ledger-namespace", "The namespace of the ConfigMap that records the Keycloak sessions of ProviderConfigs with cleanupOrphanedSessions enabled.").Default("crossplane-system").Envar("POD_NAMESPACE").String()
 		webhookPort             = app.Flag("webhook-port", "The port the webhook listens on").Default("9443").Envar("WEBHOOK_PORT").Int()
 		metricsBindAddress      = app.Flag("metrics-bind-address", "The address the metrics server listens on").Default(":8080").Envar("METRICS_BIND_ADDRESS").String()
 
@@ -182,6 +207,18 @@ func main() {
 
 	metrics.Registry.MustRegister(metricRecorder)
 	metrics.Registry.MustRegister(stateMetrics)
+	tfconcurrency.SetBreakerOptions(tfconcurrency.BreakerOptions{FailureThreshold: *breakerThreshold, InitialBackoff: *breakerBackoff, MaxBackoff: *breakerMaxBackoff})
+	metrics.Registry.MustRegister(tfconcurrency.Collectors()...)
+	metrics.Registry.MustRegister(clients.Collectors()...)
+	metrics.Registry.MustRegister(keycloaksession.Collectors()...)
+	metrics.Registry.MustRegister(stalerefs.Collectors()...)
+	metrics.Registry.MustRegister(resilience.Collectors()...)
+	lookup.SetListingCacheTTL(*lookupCacheTTL)
+	metrics.Registry.MustRegister(lookup.Collectors()...)
+
+	// Stale-reference recoveries happen during the Terraform setup of every
+	// managed resource controller, so they share a single event recorder.
+	staleRefRecorder := event.NewAPIRecorder(mgr.GetEventRecorderFor("stale-reference-recovery")) //nolint:staticcheck // event.NewAPIRecorder only accepts the deprecated record.EventRecorder
 
 	providerCluster, err := config.GetProvider(false)
 	kingpin.FatalIfError(err, "Cannot initialize the cluster provider configuration")
@@ -202,7 +239,7 @@ func main() {
 			},
 		},
 		Provider:              providerCluster,
-		SetupFn:               clients.TerraformSetupBuilder(*keycloakClientPoolSize),
+		SetupFn:               clients.TerraformSetupBuilder(*keycloakClientPoolSize, staleRefRecorder),
 		PollJitter:            pollJitter,
 		OperationTrackerStore: tjcontroller.NewOperationStore(log),
 	}
@@ -220,7 +257,7 @@ func main() {
 			},
 		},
 		Provider:              providerNamespaced,
-		SetupFn:               clients.TerraformSetupBuilder(*keycloakClientPoolSize),
+		SetupFn:               clients.TerraformSetupBuilder(*keycloakClientPoolSize, staleRefRecorder),
 		PollJitter:            pollJitter,
 		OperationTrackerStore: tjcontroller.NewOperationStore(log),
 	}
@@ -233,22 +270,49 @@ func main() {
 
 	// The managed resource controllers are set up through a wrapper that keeps
 	// a single failing controller (e.g. an informer that cannot list its kind)
-	// from tearing down the manager and the conversion webhook it serves.
-	cmgr := resilience.WrapManager(mgr, log)
+	// from tearing down the manager and the conversion webhook it serves, and
+	// that reports the provider's own Synced reasons instead of ReconcileError.
+	// They are always set up through its gate, which sets up the controllers
+	// that failed to start again and reports their state.
+	rmgr := resilience.WrapManager(mgr, log, resilience.RetryOptions{InitialBackoff: *controllerRetryBackoff, MaxBackoff: *controllerRetryMax})
+	kingpin.FatalIfError(mgr.AddMetricsServerExtraHandler("/controllers", rmgr), "Cannot add the controller state endpoint")
+	cmgr := conditions.WrapManager(rmgr)
 	canSafeStart, err := canWatchCRD(mgr)
 	kingpin.FatalIfError(err, "SafeStart precheck failed")
+	var crdGate xpcontroller.Gate
 	if canSafeStart {
-		crdGate := new(gate.Gate[schema.GroupVersionKind])
+		crdGate = new(gate.Gate[schema.GroupVersionKind])
 		optsCluster.Gate = crdGate
-		optsNamespaced.Gate = crdGate
-		kingpin.FatalIfError(customresourcesgate.Setup(mgr, optsNamespaced.Options), "Cannot setup CRD gate")
-		kingpin.FatalIfError(controllerCluster.SetupGated(cmgr, optsCluster), "Cannot setup Keycloak controllers")
-		kingpin.FatalIfError(controllerNamespaced.SetupGated(cmgr, optsNamespaced), "Cannot setup Keycloak controllers")
+		kingpin.FatalIfError(customresourcesgate.Setup(mgr, optsCluster.Options), "Cannot setup CRD gate")
 	} else {
 		log.Info("Provider has missing RBAC permissions for watching CRDs, controller SafeStart capability will be disabled")
-		kingpin.FatalIfError(controllerCluster.Setup(cmgr, optsCluster), "Cannot setup Keycloak controllers")
-		kingpin.FatalIfError(controllerNamespaced.Setup(cmgr, optsNamespaced), "Cannot setup Keycloak controllers")
 	}
+	// Only the controllers of the enabled kinds are set up, optionally once
+	// their kinds are in use. The ProviderConfig controllers are always set up.
+	kinds := resilience.ParseKindSelector(*enabledKinds)
+	if unmatched := kinds.Unmatched(knownKinds(mgr.GetScheme())); len(unmatched) > 0 {
+		log.Info("Enabled kinds match no managed resource kind", "kinds", unmatched)
+	}
+	gateOpts := resilience.GateOptions{
+		Kinds:            kinds,
+		Lazy:             *lazyControllers,
+		LazyPollInterval: *lazyPollInterval,
+		AlwaysEnabled:    []string{clusterv1beta1.Group, namespacedv1beta1.Group},
+	}
+	optsCluster.Gate = rmgr.Gate(crdGate, gateOpts)
+	optsNamespaced.Gate = optsCluster.Gate
+	kingpin.FatalIfError(controllerCluster.SetupGated(cmgr, optsCluster), "Cannot setup Keycloak controllers")
+	kingpin.FatalIfError(controllerNamespaced.SetupGated(cmgr, optsNamespaced), "Cannot setup Keycloak controllers")
+	kingpin.FatalIfError(credentials.Setup(cmgr, optsNamespaced), "Cannot setup Keycloak credentials rotation controller")
+	// The dependents controllers are reported apart from the managed resource
+	// controllers of the kinds they watch.
+	gateOpts.Name = "dependents"
+	dependentsCluster, dependentsNamespaced := optsCluster, optsNamespaced
+	dependentsCluster.Gate = rmgr.Gate(crdGate, gateOpts)
+	dependentsNamespaced.Gate = dependentsCluster.Gate
+	kingpin.FatalIfError(dependents.Setup(cmgr, dependentsCluster, kinds), "Cannot setup Keycloak cluster-scoped dependents controllers")
+	kingpin.FatalIfError(dependents.Setup(cmgr, dependentsNamespaced, kinds), "Cannot setup Keycloak namespaced dependents controllers")
+	kingpin.FatalIfError(clientcache.Setup(cmgr, optsNamespaced, clients.EvictionOptions{TTL: *clientCacheTTL, IdleTimeout: *clientIdleTimeout}), "Cannot setup Keycloak client cache eviction")
 
 	// The CRD conversion webhooks are served by every replica, not only by the
 	// leader, so they are registered independently of the controllers. They are
@@ -262,9 +326,22 @@ func main() {
 
 	kingpin.FatalIfError(conversion.RegisterConversions(optsCluster.Provider, optsNamespaced.Provider, mgr.GetScheme()), "Cannot initialize the webhook conversion registry")
 	kingpin.FatalIfError(mgr.Add(sessionCleanupRunnable{}), "Cannot register session cleanup runnable")
+	clients.EnableSessionLedger(mgr.GetAPIReader(), mgr.GetClient(), *sessionLedgerNamespace)
+	kingpin.FatalIfError(mgr.Add(orphanedSessionCleanupRunnable{kube: mgr.GetClient(), log: log}), "Cannot register orphaned session cleanup runnable")
 	kingpin.FatalIfError(mgr.Start(ctrl.SetupSignalHandler()), "Cannot start controller manager")
 }
 
+// knownKinds returns the kinds of the Keycloak API groups known to s.
+func knownKinds(s *runtime.Scheme) []schema.GroupKind {
+	var out []schema.GroupKind
+	for gvk := range s.AllKnownTypes() {
+		if strings.HasSuffix(gvk.Group, clusterv1beta1.Group) || strings.HasSuffix(gvk.Group, namespacedv1beta1.Group) {
+			out = append(out, gvk.GroupKind())
+		}
+	}
+	return out
+}
+
 func canWatchCRD(mgr manager.Manager) (bool, error) {
 	ctx := context.Background()
 	if err := authv1.AddToScheme(mgr.GetScheme()); err != nil {
@@ -293,8 +370,9 @@ func canWatchCRD(mgr manager.Manager) (bool, error) {
 
 // sessionCleanupRunnable implements manager.Runnable. It waits for the
 // manager context to be cancelled (i.e. graceful shutdown) and then
-// logs out all cached Keycloak sessions so that password-grant sessions
-// don't linger on the Keycloak server until they expire.
+// logs out all cached Keycloak sessions and revokes their tokens so that
+// neither sessions nor tokens stay valid on the Keycloak server until they
+// expire.
 type sessionCleanupRunnable struct{}
 
 func (sessionCleanupRunnable) Start(ctx context.Context) error {
@@ -302,7 +380,26 @@ func (sessionCleanupRunnable) Start(ctx context.Context) error {
 	// Use a fresh context because the manager context is already cancelled.
 	cleanupCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
-	clients.CleanupSessions(cleanupCtx)      //nolint:contextcheck // ctx is already cancelled; cleanupCtx is intentionally a fresh context
-	lookup.CleanupLookupSessions(cleanupCtx) //nolint:contextcheck // ctx is already cancelled; cleanupCtx is intentionally a fresh context
+	clients.CleanupSessions(cleanupCtx) //nolint:contextcheck // ctx is already cancelled; cleanupCtx is intentionally a fresh context
+	return nil
+}
+
+// orphanedSessionCleanupRunnable implements manager.Runnable. Once the
+// provider is elected leader it logs out the Keycloak sessions that previous
+// provider processes left behind, for the ProviderConfigs that opted in to
+// it. Failures are logged and do not stop the provider.
+type orphanedSessionCleanupRunnable struct {
+	kube client.Client
+	log  logging.Logger
+}
+
+func (r orphanedSessionCleanupRunnable) Start(ctx context.Context) error {
+	n, err := clients.CleanupOrphanedSessions(ctx, r.kube)
+	if err != nil {
+		r.log.Info("Cannot log out all orphaned Keycloak sessions", "error", err)
+	}
+	if n > 0 {
+		r.log.Info("Logged out orphaned Keycloak sessions", "count", n)
+	}
 	return nil
 }
@@ -184,17 +184,20 @@ func getSubFlowIDByIdentifyingProperties(ctx context.Context, parameters map[str
 		return execution.AuthenticationFlow && execution.Level == 0
 	})
 
+	var found []*keycloak.AuthenticationSubFlow
 	for _, flow := range filtered {
 		subFlow, err := kcClient.GetAuthenticationSubFlow(ctx, parameters["realm_id"].(string), parameters["parent_flow_alias"].(string), flow.FlowId)
 		if err != nil {
 			return "", err
 		}
 		if subFlow != nil && subFlow.Alias == parameters["alias"].(string) {
-			return subFlow.Id, nil
+			found = append(found, subFlow)
 		}
 	}
 
-	return "", nil
+	return lookup.SingleOrEmpty(found, func(subFlow *keycloak.AuthenticationSubFlow) string {
+		return subFlow.Id
+	})
 }
 
 var executionIdentifyingPropertiesLookup = lookup.IdentifyingPropertiesLookupConfig{
@@ -262,3 +265,15 @@ func getExecutionConfigIDByIdentifyingProperties(_ context.Context, _ map[string
 	// We can simply create it
 	return "", nil
 }
+
+// BindingsIdentifierFromIdentifyingProperties is used to find the existing
+// flow bindings of a realm, which exist as long as the realm does. Its ID is
+// {realm}.
+var BindingsIdentifierFromIdentifyingProperties = lookup.BuildCompositeKeyLookup(lookup.CompositeKeyLookupConfig{
+	RequiredParameters: []string{"realm_id"},
+	Key: func(parameters map[string]any) string {
+		return lookup.Param(parameters, "realm_id")
+	},
+	Exists:    lookup.RealmExists,
+	Singleton: true,
+})
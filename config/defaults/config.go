@@ -1,6 +1,13 @@
 package defaults
 
-import "github.com/crossplane/upjet/v2/pkg/config"
+import (
+	"context"
+
+	"github.com/crossplane/upjet/v2/pkg/config"
+	"github.com/keycloak/terraform-provider-keycloak/keycloak"
+
+	"github.com/crossplane-contrib/provider-keycloak/config/lookup"
+)
 
 // Configure configures individual resources by adding custom ResourceConfigurators.
 func Configure(p *config.Provider) {
@@ -25,3 +32,37 @@ func Configure(p *config.Provider) {
 
 	})
 }
+
+var defaultRolesIdentifyingPropertiesLookup = lookup.IdentifyingPropertiesLookupConfig{
+	RequiredParameters:           []string{"realm_id"},
+	GetIDByExternalName:          lookup.KeyByExternalName(getDefaultRolesIDByIdentifyingProperties),
+	GetIDByIdentifyingProperties: getDefaultRolesIDByIdentifyingProperties,
+	Singleton:                    true,
+}
+
+// RolesIdentifierFromIdentifyingProperties is used to find the default roles
+// of a realm. Its ID is the ID of the composite default role of the realm.
+var RolesIdentifierFromIdentifyingProperties = lookup.BuildIdentifyingPropertiesLookup(defaultRolesIdentifyingPropertiesLookup)
+
+func getDefaultRolesIDByIdentifyingProperties(ctx context.Context, parameters map[string]any, kcClient *keycloak.KeycloakClient) (string, error) {
+	realm, err := lookup.GetRealm(ctx, kcClient, lookup.Param(parameters, "realm_id"))
+	if err != nil {
+		return "", err
+	}
+	if realm.DefaultRole == nil {
+		return "", nil
+	}
+	return realm.DefaultRole.ID, nil
+}
+
+// GroupsIdentifierFromIdentifyingProperties is used to find the existing
+// default groups of a realm. Its ID is {realm}/default-groups.
+var GroupsIdentifierFromIdentifyingProperties = lookup.BuildCompositeKeyLookup(lookup.CompositeKeyLookupConfig{
+	RequiredParameters: []string{"realm_id"},
+	Key: func(parameters map[string]any) string {
+		return lookup.Key(lookup.Param(parameters, "realm_id"), "default-groups")
+	},
+	Exists: func(ctx context.Context, parameters map[string]any, kcClient *keycloak.KeycloakClient) (bool, error) {
+		return lookup.HasEntries(ctx, kcClient, "/realms/"+lookup.Param(parameters, "realm_id")+"/default-groups", nil)
+	},
+})
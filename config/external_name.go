@@ -8,13 +8,16 @@ import (
 	"github.com/crossplane/upjet/v2/pkg/config"
 
 	"github.com/crossplane-contrib/provider-keycloak/config/authentication"
+	"github.com/crossplane-contrib/provider-keycloak/config/defaults"
 	"github.com/crossplane-contrib/provider-keycloak/config/group"
 	"github.com/crossplane-contrib/provider-keycloak/config/identityprovider"
 	"github.com/crossplane-contrib/provider-keycloak/config/ldap"
+	"github.com/crossplane-contrib/provider-keycloak/config/lookup"
 	"github.com/crossplane-contrib/provider-keycloak/config/mapper"
 	"github.com/crossplane-contrib/provider-keycloak/config/oidc"
 	"github.com/crossplane-contrib/provider-keycloak/config/openidclient"
 	"github.com/crossplane-contrib/provider-keycloak/config/openidgroup"
+	"github.com/crossplane-contrib/provider-keycloak/config/organization"
 	"github.com/crossplane-contrib/provider-keycloak/config/realm"
 	"github.com/crossplane-contrib/provider-keycloak/config/role"
 	"github.com/crossplane-contrib/provider-keycloak/config/saml"
@@ -27,115 +30,115 @@ import (
 // provider.
 var ExternalNameConfigs = map[string]config.ExternalName{
 	// Import requires using a randomly generated ID from provider: nl-2e21sda
-	"keycloak_generic_client_authorization_policy":               openidclient.AuthzGenericPoliciesIdentifierFromIdentifyingProperties,      // {UUid}
-	"keycloak_generic_client_protocol_mapper":                    mapper.GenericClientProtocolMapperIdentifierFromIdentifyingProperties,     // {UUid}
-	"keycloak_generic_client_role_mapper":                        config.IdentifierFromProvider,                                             // {realm}/client|client-scope/{Client.UUid}/scope-mappings/{Client.UUid}/{Group.UUid}
-	"keycloak_generic_protocol_mapper":                           mapper.ProtocolMapperIdentifierFromIdentifyingProperties,                  // {UUid}
-	"keycloak_generic_role_mapper":                               config.IdentifierFromProvider,                                             // {realm}/client|client-scope/{Client.UUid}/scope-mappings/{Client.UUid}/{Group.UUid}
-	"keycloak_group_admin_permissions":                           config.IdentifierFromProvider,                                             // {realm}/{Permission.UUid}
-	"keycloak_group_memberships":                                 config.IdentifierFromProvider,                                             // {realm}/group-memberships/{Group.UUid}
-	"keycloak_group_permissions":                                 config.IdentifierFromProvider,                                             // {realm}/{Group.UUid}
-	"keycloak_group_roles":                                       config.IdentifierFromProvider,                                             // {realm}/{Group.UUid}
-	"keycloak_group":                                             group.GroupIdentifierFromIdentifyingProperties,                            // {UUid}
-	"keycloak_openid_client_admin_permissions":                   config.IdentifierFromProvider,                                             // {realm}/{Permission.UUid}
-	"keycloak_openid_client_client_policy":                       openidclient.AuthzClientPoliciesIdentifierFromIdentifyingProperties,       // {UUid}
-	"keycloak_openid_client_group_policy":                        openidclient.AuthzGroupPoliciesIdentifierFromIdentifyingProperties,        // {UUid}
-	"keycloak_openid_client_js_policy":                           openidclient.AuthzJSPoliciesIdentifierFromIdentifyingProperties,           // {UUid}
-	"keycloak_openid_client_permissions":                         config.IdentifierFromProvider,                                             // {realm}/{Client.UUid}
-	"keycloak_openid_client_role_policy":                         openidclient.AuthzRolePoliciesIdentifierFromIdentifyingProperties,         // {UUid}
-	"keycloak_openid_client_user_policy":                         openidclient.AuthzUserPoliciesIdentifierFromIdentifyingProperties,         // {UUid}
-	"keycloak_openid_client_regex_policy":                        openidclient.AuthzRegexPoliciesIdentifierFromIdentifyingProperties,        // {UUid}
-	"keycloak_openid_client_aggregate_policy":                    openidclient.AuthzAggregatePoliciesIdentifierFromIdentifyingProperties,    // {UUid}
-	"keycloak_openid_client_time_policy":                         openidclient.AuthzTimePoliciesIdentifierFromIdentifyingProperties,         // {UUid}
-	"keycloak_openid_client_authorization_client_scope_policy":   openidclient.AuthzClientScopePoliciesIdentifierFromIdentifyingProperties,  // {UUid}
-	"keycloak_openid_client_authorization_scope":                 openidclient.AuthzScopeIdentifierFromIdentifyingProperties,                // {UUid}
-	"keycloak_openid_client_default_scopes":                      config.IdentifierFromProvider,                                             // {realm}/{Client.UUid}
-	"keycloak_openid_client_optional_scopes":                     config.IdentifierFromProvider,                                             // {realm}/{Client.UUid}
-	"keycloak_openid_client_scope":                               openidclient.ClientScopeIdentifierFromIdentifyingProperties,               // {UUid}
-	"keycloak_openid_client":                                     openidclient.ClientIdentifierFromIdentifyingProperties,                    // {UUid}
-	"keycloak_openid_client_authorization_resource":              openidclient.AuthzResourceIdentifierFromIdentifyingProperties,             // {realm}/{Client.UUid}
-	"keycloak_openid_client_authorization_permission":            openidclient.AuthzPermissionIdentifierFromIdentifyingProperties,           // {realm}/{Client.UUid}
-	"keycloak_openid_audience_protocol_mapper":                   openidgroup.OpenidProtocolMapperIdentifierFromIdentifyingProperties,       // {UUid}
-	"keycloak_openid_audience_resolve_protocol_mapper":           openidgroup.OpenidProtocolMapperIdentifierFromIdentifyingProperties,       // {UUid}
-	"keycloak_openid_full_name_protocol_mapper":                  openidgroup.OpenidProtocolMapperIdentifierFromIdentifyingProperties,       // {UUid}
-	"keycloak_openid_group_membership_protocol_mapper":           openidgroup.IdentifierFromIdentifyingProperties,                           // {UUid}
-	"keycloak_openid_hardcoded_claim_protocol_mapper":            openidgroup.OpenidProtocolMapperIdentifierFromIdentifyingProperties,       // {UUid}
-	"keycloak_openid_hardcoded_role_protocol_mapper":             openidgroup.OpenidProtocolMapperIdentifierFromIdentifyingProperties,       // {UUid}
-	"keycloak_openid_sub_protocol_mapper":                        openidgroup.OpenidProtocolMapperIdentifierFromIdentifyingProperties,       // {UUid}
-	"keycloak_openid_user_attribute_protocol_mapper":             openidgroup.OpenidProtocolMapperIdentifierFromIdentifyingProperties,       // {UUid}
-	"keycloak_openid_user_client_role_protocol_mapper":           openidgroup.OpenidProtocolMapperIdentifierFromIdentifyingProperties,       // {UUid}
-	"keycloak_openid_user_property_protocol_mapper":              openidgroup.OpenidProtocolMapperIdentifierFromIdentifyingProperties,       // {UUid}
-	"keycloak_openid_user_realm_role_protocol_mapper":            openidgroup.OpenidProtocolMapperIdentifierFromIdentifyingProperties,       // {UUid}
-	"keycloak_openid_user_session_note_protocol_mapper":          openidgroup.OpenidProtocolMapperIdentifierFromIdentifyingProperties,       // {UUid}
-	"keycloak_openid_client_service_account_realm_role":          config.IdentifierFromProvider,                                             // {serviceAccountUserId.UUid}/{role.UUid}
-	"keycloak_openid_client_service_account_role":                config.IdentifierFromProvider,                                             // {serviceAccountUserId.UUid}/{role.UUid}
-	"keycloak_organization":                                      config.IdentifierFromProvider,                                             // {UUid}
-	"keycloak_realm":                                             realm.RealmIdentifierFromIdentifyingProperties,                            // {realm}
-	"keycloak_required_action":                                   config.IdentifierFromProvider,                                             // {realm}/{alias}
-	"keycloak_role":                                              role.IdentifierFromIdentifyingProperties,                                  // {UUid}
-	"keycloak_role_admin_permissions":                            config.IdentifierFromProvider,                                             // {realm}/{Permission.UUid}
-	"keycloak_user_groups":                                       config.IdentifierFromProvider,                                             // {realm}/{User.UUid}
-	"keycloak_user_roles":                                        config.IdentifierFromProvider,                                             // {realm}/{User.UUid}
-	"keycloak_users_permissions":                                 config.IdentifierFromProvider,                                             // {realm}
-	"keycloak_users_admin_permissions":                           config.IdentifierFromProvider,                                             // {realm}/{Permission.UUid}
-	"keycloak_user":                                              user.UserIdentifierFromIdentifyingProperties,                              // {UUid}
-	"keycloak_custom_user_federation":                            config.IdentifierFromProvider,                                             // ???
-	"keycloak_oidc_identity_provider":                            oidc.IdentifierFromIdentifyingProperties,                                  // {alias}
-	"keycloak_oidc_facebook_identity_provider":                   oidc.IdentifierFromIdentifyingProperties,                                  // {alias}
-	"keycloak_oidc_github_identity_provider":                     oidc.IdentifierFromIdentifyingProperties,                                  // {alias}
-	"keycloak_oidc_google_identity_provider":                     oidc.IdentifierFromIdentifyingProperties,                                  // {alias}
-	"keycloak_oidc_microsoft_identity_provider":                  oidc.IdentifierFromIdentifyingProperties,                                  // {alias}
-	"keycloak_kubernetes_identity_provider":                      oidc.IdentifierFromIdentifyingProperties,                                  // {alias}
-	"keycloak_oidc_openshift_v4_identity_provider":               oidc.IdentifierFromIdentifyingProperties,                                  // {alias}
-	"keycloak_spiffe_identity_provider":                          oidc.IdentifierFromIdentifyingProperties,                                  // {alias}
-	"keycloak_saml_identity_provider":                            saml.IdentifierFromIdentifyingProperties,                                  // {alias}
-	"keycloak_custom_identity_provider_mapper":                   identityprovider.IdentifierFromIdentifyingProperties,                      // {UUid}
-	"keycloak_attribute_importer_identity_provider_mapper":       identityprovider.IdentifierFromIdentifyingProperties,                      // {UUid}
-	"keycloak_attribute_to_role_identity_provider_mapper":        identityprovider.IdentifierFromIdentifyingProperties,                      // {UUid}
-	"keycloak_hardcoded_attribute_identity_provider_mapper":      identityprovider.IdentifierFromIdentifyingProperties,                      // {UUid}
-	"keycloak_hardcoded_group_identity_provider_mapper":          identityprovider.IdentifierFromIdentifyingProperties,                      // {UUid}
-	"keycloak_hardcoded_role_identity_provider_mapper":           identityprovider.IdentifierFromIdentifyingProperties,                      // {UUid}
-	"keycloak_user_template_importer_identity_provider_mapper":   identityprovider.IdentifierFromIdentifyingProperties,                      // {UUid}
-	"keycloak_identity_provider_token_exchange_scope_permission": config.IdentifierFromProvider,                                             // {realm}/{provider_alias}
-	"keycloak_saml_client":                                       samlclient.ClientIdentifierFromIdentifyingProperties,                      // {UUid}
-	"keycloak_saml_client_default_scopes":                        config.IdentifierFromProvider,                                             // {realm}/{Client.UUid}
-	"keycloak_saml_client_scope":                                 samlclient.ClientScopeIdentifierFromIdentifyingProperties,                 // {UUid}
-	"keycloak_saml_user_attribute_protocol_mapper":               samlclient.SamlProtocolMapperIdentifierFromIdentifyingProperties,          // {UUid}
-	"keycloak_saml_user_property_protocol_mapper":                samlclient.SamlProtocolMapperIdentifierFromIdentifyingProperties,          // {UUid}
-	"keycloak_realm_keystore_rsa":                                realm.KeystoreRsaIdentifierFromIdentifyingProperties,                      // {UUid}
-	"keycloak_realm_keystore_aes_generated":                      config.IdentifierFromProvider,                                             // {UUid}
-	"keycloak_realm_keystore_ecdsa_generated":                    config.IdentifierFromProvider,                                             // {UUid}
-	"keycloak_realm_keystore_hmac_generated":                     config.IdentifierFromProvider,                                             // {UUid}
-	"keycloak_realm_keystore_rsa_generated":                      config.IdentifierFromProvider,                                             // {UUid}
-	"keycloak_realm_keystore_java_keystore":                      config.IdentifierFromProvider,                                             // {UUid}
-	"keycloak_realm_user_profile":                                config.IdentifierFromProvider,                                             // {realm}
-	"keycloak_realm_localization":                                config.IdentifierFromProvider,                                             // {realm}/{locale}
-	"keycloak_realm_default_client_scopes":                       config.IdentifierFromProvider,                                             // {realm}
-	"keycloak_realm_optional_client_scopes":                      config.IdentifierFromProvider,                                             // {realm}
-	"keycloak_realm_events":                                      realm.EventsRealmIdentifierFromIdentifyingProperties,                      // {realm}
-	"keycloak_realm_client_policy_profile":                       config.IdentifierFromProvider,                                             // {realm}/realm-client-policy-profiles/{name}
-	"keycloak_realm_client_policy_profile_policy":                config.IdentifierFromProvider,                                             // {realm}/realm-client-policy-profile-policies/{name}
-	"keycloak_realm_client_registration_policy":                  realm.ClientRegistrationPolicyIdentifierFromIdentifyingProperties,         // {UUid}
-	"keycloak_authentication_flow":                               authentication.FlowIdentifierFromIdentifyingProperties,                    // {UUid}
-	"keycloak_authentication_subflow":                            authentication.SubFlowIdentifierFromIdentifyingProperties,                 // {UUid}
-	"keycloak_authentication_execution":                          authentication.ExecutionIdentifierFromIdentifyingProperties,               // {UUid}
-	"keycloak_authentication_execution_config":                   authentication.ExecutionConfigIdentifierFromIdentifyingProperties,         // {UUid}
-	"keycloak_authentication_bindings":                           config.IdentifierFromProvider,                                             // {realm}
-	"keycloak_default_roles":                                     config.IdentifierFromProvider,                                             // {UUid}
-	"keycloak_default_groups":                                    config.IdentifierFromProvider,                                             // {realm}/default-groups
-	"keycloak_ldap_user_federation":                              ldap.UserFederationIdentifierFromIdentifyingProperties,                    // {UUid}
-	"keycloak_ldap_user_attribute_mapper":                        ldap.UserAttributeMapperIdentifierFromIdentifyingProperties,               // {UUid}
-	"keycloak_ldap_role_mapper":                                  ldap.RoleMapperIdentifierFromIdentifyingProperties,                        // {UUid}
-	"keycloak_ldap_group_mapper":                                 ldap.GroupMapperIdentifierFromIdentifyingProperties,                       // {UUid}
-	"keycloak_ldap_hardcoded_role_mapper":                        ldap.HardcodedRoleMapperIdentifierFromIdentifyingProperties,               // {UUid}
-	"keycloak_ldap_hardcoded_group_mapper":                       ldap.HardcodedGroupMapperIdentifierFromIdentifyingProperties,              // {UUid}
-	"keycloak_ldap_msad_user_account_control_mapper":             ldap.MsadUserAccountControlMapperIdentifierFromIdentifyingProperties,      // {UUid}
-	"keycloak_ldap_msad_lds_user_account_control_mapper":         ldap.MsadLdsUserAccountControlMapperIdentifierFromIdentifyingProperties,   // {UUid}
-	"keycloak_ldap_hardcoded_attribute_mapper":                   ldap.HardcodedAttributeMapperIdentifierFromIdentifyingProperties,          // {UUid}
-	"keycloak_hardcoded_attribute_mapper":                        ldap.UserModelHardcodedAttributeMapperIdentifierFromIdentifyingProperties, // {UUid}
-	"keycloak_ldap_full_name_mapper":                             ldap.FullNameMapperIdentifierFromIdentifyingProperties,                    // {UUid}
-	"keycloak_ldap_custom_mapper":                                ldap.CustomMapperIdentifierFromIdentifyingProperties,                      // {UUid}
-	"keycloak_workflow":                                          workflow.IdentifierFromIdentifyingProperties,                              // {UUid}
+	"keycloak_generic_client_authorization_policy":               openidclient.AuthzGenericPoliciesIdentifierFromIdentifyingProperties,             // {UUid}
+	"keycloak_generic_client_protocol_mapper":                    mapper.GenericClientProtocolMapperIdentifierFromIdentifyingProperties,            // {UUid}
+	"keycloak_generic_client_role_mapper":                        mapper.RoleMapperIdentifierFromIdentifyingProperties,                             // {realm}/client|client-scope/{Client.UUid}/scope-mappings/{Client.UUid}/{Group.UUid}
+	"keycloak_generic_protocol_mapper":                           mapper.ProtocolMapperIdentifierFromIdentifyingProperties,                         // {UUid}
+	"keycloak_generic_role_mapper":                               mapper.RoleMapperIdentifierFromIdentifyingProperties,                             // {realm}/client|client-scope/{Client.UUid}/scope-mappings/{Client.UUid}/{Group.UUid}
+	"keycloak_group_admin_permissions":                           lookup.AdminPermissionsIdentifierFromIdentifyingProperties,                       // {realm}/{Permission.UUid}
+	"keycloak_group_memberships":                                 group.MembershipsIdentifierFromIdentifyingProperties,                             // {realm}/group-memberships/{Group.UUid}
+	"keycloak_group_permissions":                                 group.PermissionsIdentifierFromIdentifyingProperties,                             // {realm}/{Group.UUid}
+	"keycloak_group_roles":                                       group.RolesIdentifierFromIdentifyingProperties,                                   // {realm}/{Group.UUid}
+	"keycloak_group":                                             group.GroupIdentifierFromIdentifyingProperties,                                   // {UUid}
+	"keycloak_openid_client_admin_permissions":                   lookup.AdminPermissionsIdentifierFromIdentifyingProperties,                       // {realm}/{Permission.UUid}
+	"keycloak_openid_client_client_policy":                       openidclient.AuthzClientPoliciesIdentifierFromIdentifyingProperties,              // {UUid}
+	"keycloak_openid_client_group_policy":                        openidclient.AuthzGroupPoliciesIdentifierFromIdentifyingProperties,               // {UUid}
+	"keycloak_openid_client_js_policy":                           openidclient.AuthzJSPoliciesIdentifierFromIdentifyingProperties,                  // {UUid}
+	"keycloak_openid_client_permissions":                         openidclient.PermissionsIdentifierFromIdentifyingProperties,                      // {realm}/{Client.UUid}
+	"keycloak_openid_client_role_policy":                         openidclient.AuthzRolePoliciesIdentifierFromIdentifyingProperties,                // {UUid}
+	"keycloak_openid_client_user_policy":                         openidclient.AuthzUserPoliciesIdentifierFromIdentifyingProperties,                // {UUid}
+	"keycloak_openid_client_regex_policy":                        openidclient.AuthzRegexPoliciesIdentifierFromIdentifyingProperties,               // {UUid}
+	"keycloak_openid_client_aggregate_policy":                    openidclient.AuthzAggregatePoliciesIdentifierFromIdentifyingProperties,           // {UUid}
+	"keycloak_openid_client_time_policy":                         openidclient.AuthzTimePoliciesIdentifierFromIdentifyingProperties,                // {UUid}
+	"keycloak_openid_client_authorization_client_scope_policy":   openidclient.AuthzClientScopePoliciesIdentifierFromIdentifyingProperties,         // {UUid}
+	"keycloak_openid_client_authorization_scope":                 openidclient.AuthzScopeIdentifierFromIdentifyingProperties,                       // {UUid}
+	"keycloak_openid_client_default_scopes":                      openidclient.DefaultScopesIdentifierFromIdentifyingProperties,                    // {realm}/{Client.UUid}
+	"keycloak_openid_client_optional_scopes":                     openidclient.OptionalScopesIdentifierFromIdentifyingProperties,                   // {realm}/{Client.UUid}
+	"keycloak_openid_client_scope":                               openidclient.ClientScopeIdentifierFromIdentifyingProperties,                      // {UUid}
+	"keycloak_openid_client":                                     openidclient.ClientIdentifierFromIdentifyingProperties,                           // {UUid}
+	"keycloak_openid_client_authorization_resource":              openidclient.AuthzResourceIdentifierFromIdentifyingProperties,                    // {realm}/{Client.UUid}
+	"keycloak_openid_client_authorization_permission":            openidclient.AuthzPermissionIdentifierFromIdentifyingProperties,                  // {realm}/{Client.UUid}
+	"keycloak_openid_audience_protocol_mapper":                   openidgroup.OpenidProtocolMapperIdentifierFromIdentifyingProperties,              // {UUid}
+	"keycloak_openid_audience_resolve_protocol_mapper":           openidgroup.OpenidProtocolMapperIdentifierFromIdentifyingProperties,              // {UUid}
+	"keycloak_openid_full_name_protocol_mapper":                  openidgroup.OpenidProtocolMapperIdentifierFromIdentifyingProperties,              // {UUid}
+	"keycloak_openid_group_membership_protocol_mapper":           openidgroup.IdentifierFromIdentifyingProperties,                                  // {UUid}
+	"keycloak_openid_hardcoded_claim_protocol_mapper":            openidgroup.OpenidProtocolMapperIdentifierFromIdentifyingProperties,              // {UUid}
+	"keycloak_openid_hardcoded_role_protocol_mapper":             openidgroup.OpenidProtocolMapperIdentifierFromIdentifyingProperties,              // {UUid}
+	"keycloak_openid_sub_protocol_mapper":                        openidgroup.OpenidProtocolMapperIdentifierFromIdentifyingProperties,              // {UUid}
+	"keycloak_openid_user_attribute_protocol_mapper":             openidgroup.OpenidProtocolMapperIdentifierFromIdentifyingProperties,              // {UUid}
+	"keycloak_openid_user_client_role_protocol_mapper":           openidgroup.OpenidProtocolMapperIdentifierFromIdentifyingProperties,              // {UUid}
+	"keycloak_openid_user_property_protocol_mapper":              openidgroup.OpenidProtocolMapperIdentifierFromIdentifyingProperties,              // {UUid}
+	"keycloak_openid_user_realm_role_protocol_mapper":            openidgroup.OpenidProtocolMapperIdentifierFromIdentifyingProperties,              // {UUid}
+	"keycloak_openid_user_session_note_protocol_mapper":          openidgroup.OpenidProtocolMapperIdentifierFromIdentifyingProperties,              // {UUid}
+	"keycloak_openid_client_service_account_realm_role":          openidclient.ServiceAccountRealmRoleIdentifierFromIdentifyingProperties,          // {serviceAccountUserId.UUid}/{role.UUid}
+	"keycloak_openid_client_service_account_role":                openidclient.ServiceAccountRoleIdentifierFromIdentifyingProperties,               // {serviceAccountUserId.UUid}/{role.UUid}
+	"keycloak_organization":                                      organization.OrganizationIdentifierFromIdentifyingProperties,                     // {UUid}
+	"keycloak_realm":                                             realm.RealmIdentifierFromIdentifyingProperties,                                   // {realm}
+	"keycloak_required_action":                                   realm.RequiredActionIdentifierFromIdentifyingProperties,                          // {realm}/{alias}
+	"keycloak_role":                                              role.IdentifierFromIdentifyingProperties,                                         // {UUid}
+	"keycloak_role_admin_permissions":                            lookup.AdminPermissionsIdentifierFromIdentifyingProperties,                       // {realm}/{Permission.UUid}
+	"keycloak_user_groups":                                       user.GroupsIdentifierFromIdentifyingProperties,                                   // {realm}/{User.UUid}
+	"keycloak_user_roles":                                        user.RolesIdentifierFromIdentifyingProperties,                                    // {realm}/{User.UUid}
+	"keycloak_users_permissions":                                 user.PermissionsIdentifierFromIdentifyingProperties,                              // {realm}
+	"keycloak_users_admin_permissions":                           lookup.AdminPermissionsIdentifierFromIdentifyingProperties,                       // {realm}/{Permission.UUid}
+	"keycloak_user":                                              user.UserIdentifierFromIdentifyingProperties,                                     // {UUid}
+	"keycloak_custom_user_federation":                            user.CustomUserFederationIdentifierFromIdentifyingProperties,                     // {UUid}
+	"keycloak_oidc_identity_provider":                            oidc.IdentifierFromIdentifyingProperties,                                         // {alias}
+	"keycloak_oidc_facebook_identity_provider":                   oidc.IdentifierFromIdentifyingProperties,                                         // {alias}
+	"keycloak_oidc_github_identity_provider":                     oidc.IdentifierFromIdentifyingProperties,                                         // {alias}
+	"keycloak_oidc_google_identity_provider":                     oidc.IdentifierFromIdentifyingProperties,                                         // {alias}
+	"keycloak_oidc_microsoft_identity_provider":                  oidc.IdentifierFromIdentifyingProperties,                                         // {alias}
+	"keycloak_kubernetes_identity_provider":                      oidc.IdentifierFromIdentifyingProperties,                                         // {alias}
+	"keycloak_oidc_openshift_v4_identity_provider":               oidc.IdentifierFromIdentifyingProperties,                                         // {alias}
+	"keycloak_spiffe_identity_provider":                          oidc.IdentifierFromIdentifyingProperties,                                         // {alias}
+	"keycloak_saml_identity_provider":                            saml.IdentifierFromIdentifyingProperties,                                         // {alias}
+	"keycloak_custom_identity_provider_mapper":                   identityprovider.IdentifierFromIdentifyingProperties,                             // {UUid}
+	"keycloak_attribute_importer_identity_provider_mapper":       identityprovider.IdentifierFromIdentifyingProperties,                             // {UUid}
+	"keycloak_attribute_to_role_identity_provider_mapper":        identityprovider.IdentifierFromIdentifyingProperties,                             // {UUid}
+	"keycloak_hardcoded_attribute_identity_provider_mapper":      identityprovider.IdentifierFromIdentifyingProperties,                             // {UUid}
+	"keycloak_hardcoded_group_identity_provider_mapper":          identityprovider.IdentifierFromIdentifyingProperties,                             // {UUid}
+	"keycloak_hardcoded_role_identity_provider_mapper":           identityprovider.IdentifierFromIdentifyingProperties,                             // {UUid}
+	"keycloak_user_template_importer_identity_provider_mapper":   identityprovider.IdentifierFromIdentifyingProperties,                             // {UUid}
+	"keycloak_identity_provider_token_exchange_scope_permission": identityprovider.TokenExchangeScopePermissionIdentifierFromIdentifyingProperties, // {realm}/{provider_alias}
+	"keycloak_saml_client":                                       samlclient.ClientIdentifierFromIdentifyingProperties,                             // {UUid}
+	"keycloak_saml_client_default_scopes":                        openidclient.DefaultScopesIdentifierFromIdentifyingProperties,                    // {realm}/{Client.UUid}
+	"keycloak_saml_client_scope":                                 samlclient.ClientScopeIdentifierFromIdentifyingProperties,                        // {UUid}
+	"keycloak_saml_user_attribute_protocol_mapper":               samlclient.SamlProtocolMapperIdentifierFromIdentifyingProperties,                 // {UUid}
+	"keycloak_saml_user_property_protocol_mapper":                samlclient.SamlProtocolMapperIdentifierFromIdentifyingProperties,                 // {UUid}
+	"keycloak_realm_keystore_rsa":                                realm.KeystoreRsaIdentifierFromIdentifyingProperties,                             // {UUid}
+	"keycloak_realm_keystore_aes_generated":                      realm.GeneratedKeystoreIdentifierFromIdentifyingProperties("aes-generated"),      // {UUid}
+	"keycloak_realm_keystore_ecdsa_generated":                    realm.GeneratedKeystoreIdentifierFromIdentifyingProperties("ecdsa-generated"),    // {UUid}
+	"keycloak_realm_keystore_hmac_generated":                     realm.GeneratedKeystoreIdentifierFromIdentifyingProperties("hmac-generated"),     // {UUid}
+	"keycloak_realm_keystore_rsa_generated":                      realm.GeneratedKeystoreIdentifierFromIdentifyingProperties("rsa-generated"),      // {UUid}
+	"keycloak_realm_keystore_java_keystore":                      realm.GeneratedKeystoreIdentifierFromIdentifyingProperties("java-keystore"),      // {UUid}
+	"keycloak_realm_user_profile":                                realm.UserProfileIdentifierFromIdentifyingProperties,                             // {realm}
+	"keycloak_realm_localization":                                realm.LocalizationIdentifierFromIdentifyingProperties,                            // {realm}/{locale}
+	"keycloak_realm_default_client_scopes":                       realm.DefaultClientScopesIdentifierFromIdentifyingProperties,                     // {realm}
+	"keycloak_realm_optional_client_scopes":                      realm.OptionalClientScopesIdentifierFromIdentifyingProperties,                    // {realm}
+	"keycloak_realm_events":                                      realm.EventsRealmIdentifierFromIdentifyingProperties,                             // {realm}
+	"keycloak_realm_client_policy_profile":                       realm.ClientPolicyProfileIdentifierFromIdentifyingProperties,                     // {realm}/realm-client-policy-profiles/{name}
+	"keycloak_realm_client_policy_profile_policy":                realm.ClientPolicyProfilePolicyIdentifierFromIdentifyingProperties,               // {realm}/realm-client-policy-profile-policies/{name}
+	"keycloak_realm_client_registration_policy":                  realm.ClientRegistrationPolicyIdentifierFromIdentifyingProperties,                // {UUid}
+	"keycloak_authentication_flow":                               authentication.FlowIdentifierFromIdentifyingProperties,                           // {UUid}
+	"keycloak_authentication_subflow":                            authentication.SubFlowIdentifierFromIdentifyingProperties,                        // {UUid}
+	"keycloak_authentication_execution":                          authentication.ExecutionIdentifierFromIdentifyingProperties,                      // {UUid}
+	"keycloak_authentication_execution_config":                   authentication.ExecutionConfigIdentifierFromIdentifyingProperties,                // {UUid}
+	"keycloak_authentication_bindings":                           authentication.BindingsIdentifierFromIdentifyingProperties,                       // {realm}
+	"keycloak_default_roles":                                     defaults.RolesIdentifierFromIdentifyingProperties,                                // {UUid}
+	"keycloak_default_groups":                                    defaults.GroupsIdentifierFromIdentifyingProperties,                               // {realm}/default-groups
+	"keycloak_ldap_user_federation":                              ldap.UserFederationIdentifierFromIdentifyingProperties,                           // {UUid}
+	"keycloak_ldap_user_attribute_mapper":                        ldap.UserAttributeMapperIdentifierFromIdentifyingProperties,                      // {UUid}
+	"keycloak_ldap_role_mapper":                                  ldap.RoleMapperIdentifierFromIdentifyingProperties,                               // {UUid}
+	"keycloak_ldap_group_mapper":                                 ldap.GroupMapperIdentifierFromIdentifyingProperties,                              // {UUid}
+	"keycloak_ldap_hardcoded_role_mapper":                        ldap.HardcodedRoleMapperIdentifierFromIdentifyingProperties,                      // {UUid}
+	"keycloak_ldap_hardcoded_group_mapper":                       ldap.HardcodedGroupMapperIdentifierFromIdentifyingProperties,                     // {UUid}
+	"keycloak_ldap_msad_user_account_control_mapper":             ldap.MsadUserAccountControlMapperIdentifierFromIdentifyingProperties,             // {UUid}
+	"keycloak_ldap_msad_lds_user_account_control_mapper":         ldap.MsadLdsUserAccountControlMapperIdentifierFromIdentifyingProperties,          // {UUid}
+	"keycloak_ldap_hardcoded_attribute_mapper":                   ldap.HardcodedAttributeMapperIdentifierFromIdentifyingProperties,                 // {UUid}
+	"keycloak_hardcoded_attribute_mapper":                        ldap.UserModelHardcodedAttributeMapperIdentifierFromIdentifyingProperties,        // {UUid}
+	"keycloak_ldap_full_name_mapper":                             ldap.FullNameMapperIdentifierFromIdentifyingProperties,                           // {UUid}
+	"keycloak_ldap_custom_mapper":                                ldap.CustomMapperIdentifierFromIdentifyingProperties,                             // {UUid}
+	"keycloak_workflow":                                          workflow.IdentifierFromIdentifyingProperties,                                     // {UUid}
 }
 
 // ExternalNameConfigurations applies all external name configs listed in the
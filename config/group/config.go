@@ -152,32 +152,88 @@ func getGroupIDByIdentifyingProperties(ctx context.Context, parameters map[strin
 	name := parameters["name"].(string)
 	parentID, _ := parameters["parent_id"].(string)
 
+	if id := lookup.FindGroupID(ctx, kcClient, realmID, parentID, name); id != "" {
+		return id, nil
+	}
+
 	groups, err := kcClient.ListGroupsWithName(ctx, realmID, name)
 	if err != nil {
 		return "", err
 	}
 
-	group := findGroupByNameAndParent(name, parentID, groups, "")
-	if group == nil {
-		return "", nil
-	}
-
-	return group.Id, nil
+	return lookup.SingleOrEmpty(findGroupsByNameAndParent(name, parentID, groups, ""), func(group *keycloak.Group) string {
+		return group.Id
+	})
 }
 
-// findGroupByNameAndParent walks the group tree returned by the Keycloak search API
-// and finds the group that matches both the given name and parent ID.
+// findGroupsByNameAndParent walks the group tree returned by the Keycloak search API
+// and finds the groups that match both the given name and parent ID.
 // For top-level groups, parentID and currentParentID are both empty strings.
 // For child groups, parentID is the expected parent's UUID.
-func findGroupByNameAndParent(name, parentID string, groups []*keycloak.Group, currentParentID string) *keycloak.Group {
+// More than one group matches if the realm holds duplicates; a group the
+// search API returns more than once is only counted once.
+func findGroupsByNameAndParent(name, parentID string, groups []*keycloak.Group, currentParentID string) []*keycloak.Group {
+	var found []*keycloak.Group
 	for _, group := range groups {
-		if group.Name == name && currentParentID == parentID {
-			return group
+		if group.Name == name && currentParentID == parentID && !containsGroup(found, group.Id) {
+			found = append(found, group)
 		}
-		found := findGroupByNameAndParent(name, parentID, group.SubGroups, group.Id)
-		if found != nil {
-			return found
+		for _, g := range findGroupsByNameAndParent(name, parentID, group.SubGroups, group.Id) {
+			if !containsGroup(found, g.Id) {
+				found = append(found, g)
+			}
 		}
 	}
-	return nil
+	return found
+}
+
+func containsGroup(groups []*keycloak.Group, id string) bool {
+	for _, g := range groups {
+		if g.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// MembershipsIdentifierFromIdentifyingProperties is used to find the existing
+// members of a group. Its ID is {realm}/group-memberships/{group}.
+var MembershipsIdentifierFromIdentifyingProperties = lookup.BuildCompositeKeyLookup(lookup.CompositeKeyLookupConfig{
+	RequiredParameters: []string{"realm_id", "group_id"},
+	Key:                membershipsKey,
+	Exists: func(ctx context.Context, parameters map[string]any, kcClient *keycloak.KeycloakClient) (bool, error) {
+		return lookup.HasEntries(ctx, kcClient, groupPath(parameters)+"/members", map[string]string{"max": "1"})
+	},
+})
+
+// RolesIdentifierFromIdentifyingProperties is used to find the existing role
+// mappings of a group. Its ID is {realm}/{group}.
+var RolesIdentifierFromIdentifyingProperties = lookup.BuildCompositeKeyLookup(lookup.CompositeKeyLookupConfig{
+	RequiredParameters: []string{"realm_id", "group_id"},
+	Key:                groupKey,
+	Exists: func(ctx context.Context, parameters map[string]any, kcClient *keycloak.KeycloakClient) (bool, error) {
+		return lookup.HasRoleMappings(ctx, kcClient, groupPath(parameters)+"/role-mappings")
+	},
+})
+
+// PermissionsIdentifierFromIdentifyingProperties is used to find the existing
+// permissions of a group. Its ID is {realm}/{group}.
+var PermissionsIdentifierFromIdentifyingProperties = lookup.BuildCompositeKeyLookup(lookup.CompositeKeyLookupConfig{
+	RequiredParameters: []string{"realm_id", "group_id"},
+	Key:                groupKey,
+	Exists: func(ctx context.Context, parameters map[string]any, kcClient *keycloak.KeycloakClient) (bool, error) {
+		return lookup.PermissionsEnabled(ctx, kcClient, groupPath(parameters)+"/management/permissions")
+	},
+})
+
+func groupKey(parameters map[string]any) string {
+	return lookup.Key(lookup.Param(parameters, "realm_id"), lookup.Param(parameters, "group_id"))
+}
+
+func membershipsKey(parameters map[string]any) string {
+	return lookup.Key(lookup.Param(parameters, "realm_id"), "group-memberships", lookup.Param(parameters, "group_id"))
+}
+
+func groupPath(parameters map[string]any) string {
+	return "/realms/" + lookup.Param(parameters, "realm_id") + "/groups/" + lookup.Param(parameters, "group_id")
 }
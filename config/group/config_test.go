@@ -6,33 +6,33 @@ import (
 	"github.com/keycloak/terraform-provider-keycloak/keycloak"
 )
 
-func TestFindGroupByNameAndParent_TopLevelGroup(t *testing.T) {
+func TestFindGroupsByNameAndParent_TopLevelGroup(t *testing.T) {
 	groups := []*keycloak.Group{
 		{Id: "g1", Name: "group-a"},
 		{Id: "g2", Name: "group-b"},
 	}
 
-	found := findGroupByNameAndParent("group-a", "", groups, "")
-	if found == nil {
-		t.Fatal("expected to find group-a, got nil")
+	found := findGroupsByNameAndParent("group-a", "", groups, "")
+	if len(found) != 1 {
+		t.Fatalf("expected to find group-a once, got %+v", found)
 	}
-	if found.Id != "g1" {
-		t.Errorf("expected id g1, got %s", found.Id)
+	if found[0].Id != "g1" {
+		t.Errorf("expected id g1, got %s", found[0].Id)
 	}
 }
 
-func TestFindGroupByNameAndParent_TopLevelGroupNotFound(t *testing.T) {
+func TestFindGroupsByNameAndParent_TopLevelGroupNotFound(t *testing.T) {
 	groups := []*keycloak.Group{
 		{Id: "g1", Name: "group-a"},
 	}
 
-	found := findGroupByNameAndParent("nonexistent", "", groups, "")
-	if found != nil {
-		t.Errorf("expected nil, got %+v", found)
+	found := findGroupsByNameAndParent("nonexistent", "", groups, "")
+	if len(found) != 0 {
+		t.Errorf("expected no groups, got %+v", found)
 	}
 }
 
-func TestFindGroupByNameAndParent_ChildGroup(t *testing.T) {
+func TestFindGroupsByNameAndParent_ChildGroup(t *testing.T) {
 	groups := []*keycloak.Group{
 		{
 			Id:   "parent-1",
@@ -51,25 +51,25 @@ func TestFindGroupByNameAndParent_ChildGroup(t *testing.T) {
 	}
 
 	// Find test-child under parent-1
-	found := findGroupByNameAndParent("test-child", "parent-1", groups, "")
-	if found == nil {
-		t.Fatal("expected to find test-child under parent-1, got nil")
+	found := findGroupsByNameAndParent("test-child", "parent-1", groups, "")
+	if len(found) != 1 {
+		t.Fatalf("expected to find test-child under parent-1 once, got %+v", found)
 	}
-	if found.Id != "child-1" {
-		t.Errorf("expected id child-1, got %s", found.Id)
+	if found[0].Id != "child-1" {
+		t.Errorf("expected id child-1, got %s", found[0].Id)
 	}
 
 	// Find test-child under parent-2
-	found = findGroupByNameAndParent("test-child", "parent-2", groups, "")
-	if found == nil {
-		t.Fatal("expected to find test-child under parent-2, got nil")
+	found = findGroupsByNameAndParent("test-child", "parent-2", groups, "")
+	if len(found) != 1 {
+		t.Fatalf("expected to find test-child under parent-2 once, got %+v", found)
 	}
-	if found.Id != "child-2" {
-		t.Errorf("expected id child-2, got %s", found.Id)
+	if found[0].Id != "child-2" {
+		t.Errorf("expected id child-2, got %s", found[0].Id)
 	}
 }
 
-func TestFindGroupByNameAndParent_ChildGroupNotFoundWrongParent(t *testing.T) {
+func TestFindGroupsByNameAndParent_ChildGroupNotFoundWrongParent(t *testing.T) {
 	groups := []*keycloak.Group{
 		{
 			Id:   "parent-1",
@@ -80,13 +80,13 @@ func TestFindGroupByNameAndParent_ChildGroupNotFoundWrongParent(t *testing.T) {
 		},
 	}
 
-	found := findGroupByNameAndParent("test-child", "nonexistent-parent", groups, "")
-	if found != nil {
-		t.Errorf("expected nil when parent doesn't match, got %+v", found)
+	found := findGroupsByNameAndParent("test-child", "nonexistent-parent", groups, "")
+	if len(found) != 0 {
+		t.Errorf("expected no groups when parent doesn't match, got %+v", found)
 	}
 }
 
-func TestFindGroupByNameAndParent_DoesNotReturnChildAsTopLevel(t *testing.T) {
+func TestFindGroupsByNameAndParent_DoesNotReturnChildAsTopLevel(t *testing.T) {
 	groups := []*keycloak.Group{
 		{
 			Id:   "parent-1",
@@ -99,13 +99,13 @@ func TestFindGroupByNameAndParent_DoesNotReturnChildAsTopLevel(t *testing.T) {
 
 	// Looking for a top-level group named "test-child" (parentID="")
 	// Should NOT find the child group under parent-1
-	found := findGroupByNameAndParent("test-child", "", groups, "")
-	if found != nil {
-		t.Errorf("expected nil for top-level search when group only exists as child, got %+v", found)
+	found := findGroupsByNameAndParent("test-child", "", groups, "")
+	if len(found) != 0 {
+		t.Errorf("expected no groups for top-level search when group only exists as child, got %+v", found)
 	}
 }
 
-func TestFindGroupByNameAndParent_DeeplyNestedGroup(t *testing.T) {
+func TestFindGroupsByNameAndParent_DeeplyNestedGroup(t *testing.T) {
 	groups := []*keycloak.Group{
 		{
 			Id:   "grandparent",
@@ -122,23 +122,23 @@ func TestFindGroupByNameAndParent_DeeplyNestedGroup(t *testing.T) {
 		},
 	}
 
-	found := findGroupByNameAndParent("deep-child", "parent", groups, "")
-	if found == nil {
-		t.Fatal("expected to find deep-child under parent, got nil")
+	found := findGroupsByNameAndParent("deep-child", "parent", groups, "")
+	if len(found) != 1 {
+		t.Fatalf("expected to find deep-child under parent once, got %+v", found)
 	}
-	if found.Id != "child" {
-		t.Errorf("expected id child, got %s", found.Id)
+	if found[0].Id != "child" {
+		t.Errorf("expected id child, got %s", found[0].Id)
 	}
 }
 
-func TestFindGroupByNameAndParent_EmptyGroups(t *testing.T) {
-	found := findGroupByNameAndParent("any", "", []*keycloak.Group{}, "")
-	if found != nil {
-		t.Errorf("expected nil for empty groups, got %+v", found)
+func TestFindGroupsByNameAndParent_EmptyGroups(t *testing.T) {
+	found := findGroupsByNameAndParent("any", "", []*keycloak.Group{}, "")
+	if len(found) != 0 {
+		t.Errorf("expected no groups for empty groups, got %+v", found)
 	}
 }
 
-func TestFindGroupByNameAndParent_SameNameDifferentLevels(t *testing.T) {
+func TestFindGroupsByNameAndParent_SameNameDifferentLevels(t *testing.T) {
 	// Group "test" exists both as top-level and as child of parent-1
 	groups := []*keycloak.Group{
 		{Id: "top-test", Name: "test"},
@@ -152,20 +152,56 @@ func TestFindGroupByNameAndParent_SameNameDifferentLevels(t *testing.T) {
 	}
 
 	// Looking for top-level "test"
-	found := findGroupByNameAndParent("test", "", groups, "")
-	if found == nil {
-		t.Fatal("expected to find top-level test, got nil")
+	found := findGroupsByNameAndParent("test", "", groups, "")
+	if len(found) != 1 {
+		t.Fatalf("expected to find top-level test once, got %+v", found)
 	}
-	if found.Id != "top-test" {
-		t.Errorf("expected id top-test, got %s", found.Id)
+	if found[0].Id != "top-test" {
+		t.Errorf("expected id top-test, got %s", found[0].Id)
 	}
 
 	// Looking for "test" under parent-1
-	found = findGroupByNameAndParent("test", "parent-1", groups, "")
-	if found == nil {
-		t.Fatal("expected to find test under parent-1, got nil")
+	found = findGroupsByNameAndParent("test", "parent-1", groups, "")
+	if len(found) != 1 {
+		t.Fatalf("expected to find test under parent-1 once, got %+v", found)
 	}
-	if found.Id != "child-test" {
-		t.Errorf("expected id child-test, got %s", found.Id)
+	if found[0].Id != "child-test" {
+		t.Errorf("expected id child-test, got %s", found[0].Id)
+	}
+}
+
+func TestFindGroupsByNameAndParent_Duplicates(t *testing.T) {
+	// The search API returns the same child once under each matching branch,
+	// and the realm holds two top-level groups named "test".
+	child := &keycloak.Group{Id: "child-test", Name: "test"}
+	groups := []*keycloak.Group{
+		{Id: "top-test-1", Name: "test"},
+		{Id: "top-test-2", Name: "test"},
+		{Id: "parent-1", Name: "parent-1", SubGroups: []*keycloak.Group{child}},
+		{Id: "parent-1", Name: "parent-1", SubGroups: []*keycloak.Group{child}},
+	}
+
+	found := findGroupsByNameAndParent("test", "", groups, "")
+	if len(found) != 2 || found[0].Id != "top-test-1" || found[1].Id != "top-test-2" {
+		t.Errorf("expected top-test-1 and top-test-2, got %+v", found)
+	}
+
+	found = findGroupsByNameAndParent("test", "parent-1", groups, "")
+	if len(found) != 1 || found[0].Id != "child-test" {
+		t.Errorf("expected only child-test, got %+v", found)
+	}
+}
+
+func TestCompositeKeys(t *testing.T) {
+	parameters := map[string]any{"realm_id": "test", "group_id": "g1"}
+
+	if got, want := groupKey(parameters), "test/g1"; got != want {
+		t.Errorf("groupKey(...): want %q, got %q", want, got)
+	}
+	if got, want := membershipsKey(parameters), "test/group-memberships/g1"; got != want {
+		t.Errorf("membershipsKey(...): want %q, got %q", want, got)
+	}
+	if got, want := groupPath(parameters), "/realms/test/groups/g1"; got != want {
+		t.Errorf("groupPath(...): want %q, got %q", want, got)
 	}
 }
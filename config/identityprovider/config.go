@@ -218,3 +218,16 @@ func getIDByIdentifyingProperties(ctx context.Context, parameters map[string]any
 		return mapper.Id
 	})
 }
+
+// TokenExchangeScopePermissionIdentifierFromIdentifyingProperties is used to
+// find the existing token exchange permission of an identity provider. Its ID
+// is {realm}/{alias}.
+var TokenExchangeScopePermissionIdentifierFromIdentifyingProperties = lookup.BuildCompositeKeyLookup(lookup.CompositeKeyLookupConfig{
+	RequiredParameters: []string{"realm_id", "provider_alias"},
+	Key: func(parameters map[string]any) string {
+		return lookup.Key(lookup.Param(parameters, "realm_id"), lookup.Param(parameters, "provider_alias"))
+	},
+	Exists: func(ctx context.Context, parameters map[string]any, kcClient *keycloak.KeycloakClient) (bool, error) {
+		return lookup.PermissionsEnabled(ctx, kcClient, "/realms/"+lookup.Param(parameters, "realm_id")+"/identity-provider/instances/"+lookup.Param(parameters, "provider_alias")+"/management/permissions")
+	},
+})
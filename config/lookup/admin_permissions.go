@@ -0,0 +1,84 @@
+package lookup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/keycloak/terraform-provider-keycloak/keycloak"
+
+	"github.com/crossplane-contrib/provider-keycloak/internal/keycloaksession"
+)
+
+// adminPermission is a permission of the admin-permissions client of a realm.
+type adminPermission struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+var adminPermissionsLookup = IdentifyingPropertiesLookupConfig{
+	RequiredParameters:           []string{"realm_id", "name"},
+	GetIDByExternalName:          getAdminPermissionIDByExternalName,
+	GetIDByIdentifyingProperties: getAdminPermissionIDByIdentifyingProperties,
+}
+
+// AdminPermissionsIdentifierFromIdentifyingProperties is used to find the
+// existing permission of the group, role, user or client admin permissions
+// kinds by its name in the admin-permissions client of its realm. Its ID is
+// {realm}/{permission}.
+var AdminPermissionsIdentifierFromIdentifyingProperties = BuildIdentifyingPropertiesLookup(adminPermissionsLookup)
+
+func getAdminPermissionIDByExternalName(ctx context.Context, id string, parameters map[string]any, kcClient *keycloak.KeycloakClient) (string, error) {
+	realmID := Param(parameters, "realm_id")
+	permissionID, ok := strings.CutPrefix(id, realmID+"/")
+	if !ok {
+		return "", NotFound("external-name " + id + " is not a permission of realm " + realmID)
+	}
+	resourceServerID, err := adminPermissionsClientID(ctx, kcClient, realmID)
+	if err != nil {
+		return "", err
+	}
+	if resourceServerID == "" {
+		return "", NotFound("admin permissions are not enabled for realm " + realmID)
+	}
+	var permission adminPermission
+	if err := keycloaksession.AdminGet(ctx, kcClient, fmt.Sprintf("/realms/%s/clients/%s/authz/resource-server/permission/%s", realmID, resourceServerID, permissionID), nil, &permission); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func getAdminPermissionIDByIdentifyingProperties(ctx context.Context, parameters map[string]any, kcClient *keycloak.KeycloakClient) (string, error) {
+	realmID := Param(parameters, "realm_id")
+	name := Param(parameters, "name")
+	resourceServerID, err := adminPermissionsClientID(ctx, kcClient, realmID)
+	if err != nil || resourceServerID == "" {
+		return "", err
+	}
+
+	var permissions []*adminPermission
+	// The name query parameter matches substrings, so the exact name is
+	// filtered below.
+	if err := keycloaksession.AdminGet(ctx, kcClient, fmt.Sprintf("/realms/%s/clients/%s/authz/resource-server/permission", realmID, resourceServerID), map[string]string{"name": name}, &permissions); err != nil {
+		return "", err
+	}
+	found := Filter(permissions, func(p *adminPermission) bool {
+		return p.Name == name
+	})
+	return SingleOrEmpty(found, func(p *adminPermission) string {
+		return Key(realmID, p.ID)
+	})
+}
+
+// adminPermissionsClientID returns the ID of the admin-permissions client of
+// the realm, or an empty string if admin permissions are not enabled for it.
+func adminPermissionsClientID(ctx context.Context, kcClient *keycloak.KeycloakClient, realmID string) (string, error) {
+	realm, err := GetRealm(ctx, kcClient, realmID)
+	if err != nil {
+		return "", err
+	}
+	if realm.AdminPermissionsClient == nil {
+		return "", nil
+	}
+	return realm.AdminPermissionsClient.ID, nil
+}
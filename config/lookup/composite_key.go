@@ -0,0 +1,208 @@
+package lookup
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/upjet/v2/pkg/config"
+	"github.com/keycloak/terraform-provider-keycloak/keycloak"
+
+	"github.com/crossplane-contrib/provider-keycloak/internal/keycloaksession"
+)
+
+// KeyFunc returns the composite key of a Keycloak object that has no ID of
+// its own, such as the role mappings of a group, from the parameters of its
+// managed resource. It is the ID the Terraform provider gives the object, so
+// that the same parameters always yield the same ID.
+type KeyFunc func(parameters map[string]any) string
+
+// ExistsFunc reports whether the Keycloak object with the given parameters
+// exists.
+type ExistsFunc func(ctx context.Context, parameters map[string]any, kcClient *keycloak.KeycloakClient) (bool, error)
+
+// CompositeKeyLookupConfig configures the lookup of a kind whose objects are
+// identified by a composite key rather than an ID assigned by Keycloak.
+type CompositeKeyLookupConfig struct {
+	RequiredParameters []string
+	OptionalParameters []string
+	Key                KeyFunc
+	Exists             ExistsFunc
+	// Singleton is set for kinds with a single object per realm that exists
+	// with it, see IdentifyingPropertiesLookupConfig.
+	Singleton bool
+}
+
+// BuildCompositeKeyLookup creates the ExternalName of a kind identified by a
+// composite key. An external-name is kept as long as it is the key of the
+// current parameters, e.g. until the group of a GroupRoles is recreated with
+// a new ID. Otherwise the key is used if the object exists, subject to the
+// adoption policy like any object found by its identifying properties.
+func BuildCompositeKeyLookup(c CompositeKeyLookupConfig) config.ExternalName {
+	return BuildIdentifyingPropertiesLookup(IdentifyingPropertiesLookupConfig{
+		RequiredParameters: c.RequiredParameters,
+		OptionalParameters: c.OptionalParameters,
+		Singleton:          c.Singleton,
+		GetIDByExternalName: func(_ context.Context, id string, parameters map[string]any, _ *keycloak.KeycloakClient) (string, error) {
+			if key := c.Key(parameters); id != key {
+				return "", NotFound("external-name " + id + " is not the key " + key + " of the identifying properties")
+			}
+			return id, nil
+		},
+		GetIDByIdentifyingProperties: func(ctx context.Context, parameters map[string]any, kcClient *keycloak.KeycloakClient) (string, error) {
+			ok, err := c.Exists(ctx, parameters, kcClient)
+			if err != nil || !ok {
+				return "", err
+			}
+			return c.Key(parameters), nil
+		},
+	})
+}
+
+// Key joins the given parts into a composite key.
+func Key(parts ...string) string {
+	return strings.Join(parts, "/")
+}
+
+// NotFound returns an error that makes a lookup by external-name fall back to
+// the identifying properties.
+func NotFound(msg string) error {
+	return &keycloak.ApiError{Code: 404, Message: msg}
+}
+
+// Param returns the string parameter with the given name, or an empty string.
+func Param(parameters map[string]any, name string) string {
+	s, _ := parameters[name].(string)
+	return s
+}
+
+// HasEntries reports whether the list at path of the Keycloak admin API has
+// at least one entry. A missing list has none.
+func HasEntries(ctx context.Context, kcClient *keycloak.KeycloakClient, path string, params map[string]string) (bool, error) {
+	return cachedCheck(ctx, path, func() (bool, error) {
+		var entries []json.RawMessage
+		if err := keycloaksession.AdminGet(ctx, kcClient, path, params, &entries); err != nil {
+			return false, ignoreNotFound(err)
+		}
+		return len(entries) > 0, nil
+	}, paramsKey(params))
+}
+
+// PermissionsEnabled reports whether the fine-grained admin permissions at
+// path of the Keycloak admin API, e.g. /realms/{realm}/groups/{id}/management/permissions,
+// are enabled.
+func PermissionsEnabled(ctx context.Context, kcClient *keycloak.KeycloakClient, path string) (bool, error) {
+	return cachedCheck(ctx, path, func() (bool, error) {
+		var permissions struct {
+			Enabled bool `json:"enabled"`
+		}
+		if err := keycloaksession.AdminGet(ctx, kcClient, path, nil, &permissions); err != nil {
+			return false, ignoreNotFound(err)
+		}
+		return permissions.Enabled, nil
+	})
+}
+
+func ignoreNotFound(err error) error {
+	var apiErr *keycloak.ApiError
+	if errors.As(err, &apiErr) && apiErr.Code == 404 {
+		return nil
+	}
+	return err
+}
+
+// HasRoleMappings reports whether the role mappings at path of the Keycloak
+// admin API, e.g. /realms/{realm}/groups/{id}/role-mappings, map any realm or
+// client role.
+func HasRoleMappings(ctx context.Context, kcClient *keycloak.KeycloakClient, path string) (bool, error) {
+	return cachedCheck(ctx, path, func() (bool, error) {
+		var mappings struct {
+			RealmMappings  []json.RawMessage          `json:"realmMappings"`
+			ClientMappings map[string]json.RawMessage `json:"clientMappings"`
+		}
+		if err := keycloaksession.AdminGet(ctx, kcClient, path, nil, &mappings); err != nil {
+			return false, ignoreNotFound(err)
+		}
+		return len(mappings.RealmMappings) > 0 || len(mappings.ClientMappings) > 0, nil
+	})
+}
+
+// KeyByExternalName returns the GetIDByExternalName of a kind whose composite
+// key is only known after a lookup, e.g. because it contains the ID of a role
+// given by name. It keeps an external-name as long as getID yields it for the
+// current parameters.
+func KeyByExternalName(getID GetIDByIdentifyingProperties) GetIDByExternalName {
+	return func(ctx context.Context, id string, parameters map[string]any, kcClient *keycloak.KeycloakClient) (string, error) {
+		key, err := getID(ctx, parameters, kcClient)
+		if err != nil {
+			return "", err
+		}
+		if key != id {
+			return "", NotFound("external-name " + id + " is not the key " + key + " of the identifying properties")
+		}
+		return id, nil
+	}
+}
+
+// Role is a realm or client role as returned by the Keycloak admin API.
+type Role struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	ClientRole  bool   `json:"clientRole"`
+	ContainerID string `json:"containerId"`
+}
+
+// GetRoleByID returns the role with the given ID of the realm.
+func GetRoleByID(ctx context.Context, kcClient *keycloak.KeycloakClient, realmID, id string) (*Role, error) {
+	return cachedGet(ctx, realmID, collectionRoles, []string{"by-id", id}, func() (*Role, error) {
+		role := &Role{}
+		if err := keycloaksession.AdminGet(ctx, kcClient, "/realms/"+realmID+"/roles-by-id/"+id, nil, role); err != nil {
+			return nil, err
+		}
+		return role, nil
+	})
+}
+
+// MapsRole reports whether the list of roles at path of the Keycloak admin
+// API, e.g. /realms/{realm}/users/{id}/role-mappings/realm, contains the role
+// with the given ID.
+func MapsRole(ctx context.Context, kcClient *keycloak.KeycloakClient, path, roleID string) (bool, error) {
+	return cachedCheck(ctx, path, func() (bool, error) {
+		var roles []*Role
+		if err := keycloaksession.AdminGet(ctx, kcClient, path, nil, &roles); err != nil {
+			return false, ignoreNotFound(err)
+		}
+		return len(Filter(roles, func(r *Role) bool { return r.ID == roleID })) > 0, nil
+	}, roleID)
+}
+
+// Realm is the part of a realm, as returned by the Keycloak admin API, that
+// lookups use.
+type Realm struct {
+	Realm                  string `json:"realm"`
+	DefaultRole            *Role  `json:"defaultRole"`
+	AdminPermissionsClient *struct {
+		ID string `json:"id"`
+	} `json:"adminPermissionsClient"`
+}
+
+// GetRealm returns the realm with the given name.
+func GetRealm(ctx context.Context, kcClient *keycloak.KeycloakClient, realmID string) (*Realm, error) {
+	return cachedGet(ctx, realmID, collectionRealm, nil, func() (*Realm, error) {
+		realm := &Realm{}
+		if err := keycloaksession.AdminGet(ctx, kcClient, "/realms/"+realmID, nil, realm); err != nil {
+			return nil, err
+		}
+		return realm, nil
+	})
+}
+
+// RealmExists reports whether the realm of the realm_id parameter exists. It
+// is the ExistsFunc of the settings of a realm, which exist with it.
+func RealmExists(ctx context.Context, parameters map[string]any, kcClient *keycloak.KeycloakClient) (bool, error) {
+	if _, err := GetRealm(ctx, kcClient, Param(parameters, "realm_id")); err != nil {
+		return false, ignoreNotFound(err)
+	}
+	return true, nil
+}
@@ -3,10 +3,23 @@ package lookup
 import (
 	"context"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
 	"github.com/crossplane/upjet/v2/pkg/config"
 	"github.com/keycloak/terraform-provider-keycloak/keycloak"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplane-contrib/provider-keycloak/internal/adoption"
+	"github.com/crossplane-contrib/provider-keycloak/internal/tenancy"
+	"github.com/crossplane-contrib/provider-keycloak/internal/tfconcurrency"
+)
+
+const (
+	errReadOwner  = "cannot read the ownership marker of the Keycloak object"
+	errWriteOwner = "cannot write the ownership marker of the Keycloak object"
 )
 
 type IdentifyingPropertiesLookupConfig struct {
@@ -14,8 +27,35 @@ type IdentifyingPropertiesLookupConfig struct {
 	GetIDByIdentifyingProperties GetIDByIdentifyingProperties
 	RequiredParameters           []string
 	OptionalParameters           []string
+	// Ownership reads and writes the ownership marker of the Keycloak objects
+	// of the kind. Kinds without one are never adopted under the adoption
+	// policy AdoptIfUnmanaged.
+	Ownership *Ownership
+	// Singleton is set for kinds with a single object per realm that exists
+	// with it, such as its user profile. Such an object cannot be created, so
+	// it is adopted whatever the adoption policy.
+	Singleton bool
 }
 
+// Ownership reads and writes the ownership marker, see
+// adoption.OwnerAttribute, of the Keycloak objects of a kind.
+type Ownership struct {
+	GetOwner func(ctx context.Context, id string, parameters map[string]any, kcClient *keycloak.KeycloakClient) (string, error)
+	SetOwner func(ctx context.Context, id string, parameters map[string]any, kcClient *keycloak.KeycloakClient, owner string) error
+}
+
+// markTTL is how long a managed resource that found no existing object waits
+// for the object it creates to be marked as owned by it.
+const markTTL = time.Hour
+
+// unmarked holds the managed resources, by UID, that found no existing
+// object, and when, so that the object they create next is marked as owned by
+// them once its external name is known.
+var unmarked = struct {
+	sync.Mutex
+	since map[types.UID]time.Time
+}{since: map[types.UID]time.Time{}}
+
 func BuildIdentifyingPropertiesLookupIDFn(lookupConfig IdentifyingPropertiesLookupConfig) config.GetIDFn {
 	return func(ctx context.Context, externalName string, parameters map[string]any, terraformProviderConfig map[string]any) (string, error) {
 		return GetIDFromIdentifyingProperties(ctx, externalName, parameters, terraformProviderConfig, lookupConfig)
@@ -41,14 +81,27 @@ type GetIDByIdentifyingProperties func(ctx context.Context, parameters map[strin
 // Check if external-name is set and try to resolve the resource by external-name (using GetIDByExternalName)
 // If resource can NOT be resolved by external-name or external-name is NOT set
 // then try to resolve resource by identifying properties like realmId, clientId, etc. (using GetIDByIdentifyingProperties)
-func GetIDFromIdentifyingProperties(ctx context.Context, externalName string, parameters map[string]any, terraformProviderConfig map[string]any, lookupConfig IdentifyingPropertiesLookupConfig) (string, error) {
-	kcClient, err := newKeycloakClient(ctx, terraformProviderConfig)
+// An object resolved by identifying properties is only adopted if the adoption policy of the managed resource allows it.
+// If the kind supports it, the object a managed resource adopts or creates is marked as owned by it, provided its
+// management policies allow it to write.
+func GetIDFromIdentifyingProperties(ctx context.Context, externalName string, parameters map[string]any, terraformProviderConfig map[string]any, lookupConfig IdentifyingPropertiesLookupConfig) (id string, err error) {
+	// A lookup is subject to the realm restrictions of its ProviderConfig.
+	if err := tenancy.CheckRealm(tenancy.AllowedRealms(terraformProviderConfig), tenancy.Realm(parameters)); err != nil {
+		return "", err
+	}
+
+	// A lookup borrows a client from the pool of the managed resource
+	// operations of the same configuration, and shares their rate limit.
+	configKey := lookupConfigKey(terraformProviderConfig)
+	kcClient, release, err := tfconcurrency.BorrowByKey(ctx, configKey)
 	if err != nil {
 		return "", err
 	}
+	defer func() { release(err) }()
 
-	unlock := lockForLookupConfig(terraformProviderConfig)
-	defer unlock()
+	// Lookups of the same configuration share the cached listings of the
+	// collections of a realm.
+	ctx = withListingScope(ctx, configKey)
 
 	processedParameters := make(map[string]any)
 
@@ -68,14 +121,30 @@ func GetIDFromIdentifyingProperties(ctx context.Context, externalName string, pa
 		processedParameters[optParamName] = optParam
 	}
 
+	req := adoption.RequestFromSetup(terraformProviderConfig)
 	if externalName != "" {
 		foundID, err := lookupConfig.GetIDByExternalName(ctx, externalName, processedParameters, kcClient)
 		if err != nil {
+			var ambiguous *AmbiguousMatchError
+			if errors.As(err, &ambiguous) {
+				return "", req.Ambiguous(ambiguous.IDs)
+			}
 			var apiErr *keycloak.ApiError
 			if !errors.As(err, &apiErr) || apiErr.Code != 404 {
 				return "", err
 			}
+			// The object is gone, so the cached listings of its realm may
+			// still hold it.
+			InvalidateListings(configKey, tenancy.Realm(parameters))
 		} else {
+			if takeUnmarked(req, lookupConfig.Ownership) {
+				if err := markOwned(ctx, lookupConfig.Ownership, req, foundID, processedParameters, kcClient); err != nil {
+					return "", err
+				}
+				unmarked.Lock()
+				delete(unmarked.since, req.UID)
+				unmarked.Unlock()
+			}
 			return foundID, nil
 		}
 	}
@@ -84,22 +153,119 @@ func GetIDFromIdentifyingProperties(ctx context.Context, externalName string, pa
 	if err != nil {
 		var apiErr *keycloak.ApiError
 		if errors.As(err, &apiErr) && apiErr.Code == 404 {
+			notFound(req, lookupConfig.Ownership)
 			return "", nil
 		}
+		var ambiguous *AmbiguousMatchError
+		if errors.As(err, &ambiguous) {
+			return "", req.Ambiguous(ambiguous.IDs)
+		}
 
 		return "", err
 	}
+	if foundID == "" {
+		notFound(req, lookupConfig.Ownership)
+		return "", nil
+	}
+	if lookupConfig.Singleton {
+		req.AdoptSingleton(foundID)
+		return foundID, nil
+	}
+
+	var owner adoption.OwnerFunc
+	if o := lookupConfig.Ownership; o != nil {
+		owner = func() (string, error) {
+			return o.GetOwner(ctx, foundID, processedParameters, kcClient)
+		}
+	}
+	if err := req.Adopt(foundID, owner); err != nil {
+		return "", err
+	}
+	if err := markOwned(ctx, lookupConfig.Ownership, req, foundID, processedParameters, kcClient); err != nil {
+		return "", err
+	}
 
 	return foundID, nil
 }
 
+// notFound reports that no existing object matched the identifying properties
+// of the managed resource of req, and remembers to mark the object it creates
+// as owned by it.
+func notFound(req adoption.Request, o *Ownership) {
+	req.NotFound()
+	if o == nil || !req.MayWrite || req.Owner == "" || req.UID == "" {
+		return
+	}
+	unmarked.Lock()
+	defer unmarked.Unlock()
+	now := time.Now()
+	for uid, since := range unmarked.since {
+		if now.Sub(since) >= markTTL {
+			delete(unmarked.since, uid)
+		}
+	}
+	unmarked.since[req.UID] = now
+}
+
+// takeUnmarked returns whether the managed resource of req found no existing
+// object recently, so that the object it resolves by external name is the one
+// it created.
+func takeUnmarked(req adoption.Request, o *Ownership) bool {
+	if o == nil || req.UID == "" {
+		return false
+	}
+	unmarked.Lock()
+	defer unmarked.Unlock()
+	since, ok := unmarked.since[req.UID]
+	return ok && time.Since(since) < markTTL
+}
+
+// markOwned writes the ownership marker of the managed resource of req on the
+// object with the given ID, unless it already has one. Nothing is written if
+// the management policies of the managed resource do not allow writes.
+func markOwned(ctx context.Context, o *Ownership, req adoption.Request, id string, parameters map[string]any, kcClient *keycloak.KeycloakClient) error {
+	if o == nil || !req.MayWrite || req.Owner == "" {
+		return nil
+	}
+	current, err := o.GetOwner(ctx, id, parameters, kcClient)
+	if err != nil {
+		return errors.Wrap(err, errReadOwner)
+	}
+	if current != "" {
+		return nil
+	}
+	return errors.Wrap(o.SetOwner(ctx, id, parameters, kcClient, req.Owner), errWriteOwner)
+}
+
+// AmbiguousMatchError is returned by a lookup that finds several Keycloak
+// objects matching the identifying properties. None of them is adopted, so
+// that the external-name can be set to the ID of the one to adopt instead.
+type AmbiguousMatchError struct {
+	IDs []string
+}
+
+func (e *AmbiguousMatchError) Error() string {
+	return strconv.Itoa(len(e.IDs)) + " Keycloak objects match the identifying properties: " + strings.Join(e.IDs, ", ")
+}
+
+// Ambiguous returns an AmbiguousMatchError for the given objects.
+func Ambiguous[T any](list []*T, idFunc func(obj *T) string) error {
+	ids := make([]string, len(list))
+	for i, obj := range list {
+		ids[i] = idFunc(obj)
+	}
+	return &AmbiguousMatchError{IDs: ids}
+}
+
+// SingleOrEmpty returns the ID of the only object of list, an empty string if
+// there is none, or an AmbiguousMatchError if there are several.
 func SingleOrEmpty[T any](list []*T, idFunc func(obj *T) string) (string, error) {
 	if len(list) == 0 {
 		return "", nil
 	}
 
 	if len(list) > 1 {
-		return "", errors.New("Too many resources found, which match the identifying parameters. Expected 0 or 1, but was " + strconv.Itoa(len(list)))
+		return "", Ambiguous(list, idFunc)
 	}
 
 	return idFunc(list[0]), nil
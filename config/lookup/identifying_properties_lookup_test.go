@@ -0,0 +1,164 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/crossplane/upjet/v2/pkg/terraform"
+	terraformSDK "github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/keycloak/terraform-provider-keycloak/keycloak"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplane-contrib/provider-keycloak/apis/namespaced/v1beta1"
+	"github.com/crossplane-contrib/provider-keycloak/internal/adoption"
+	"github.com/crossplane-contrib/provider-keycloak/internal/keycloaksession"
+	"github.com/crossplane-contrib/provider-keycloak/internal/tfconcurrency"
+)
+
+func TestSingleOrEmpty(t *testing.T) {
+	id := func(r *Role) string { return r.ID }
+
+	if got, err := SingleOrEmpty([]*Role{}, id); got != "" || err != nil {
+		t.Errorf("SingleOrEmpty(none) = %q, %v, want no ID and no error", got, err)
+	}
+	if got, err := SingleOrEmpty([]*Role{{ID: "r1"}}, id); got != "r1" || err != nil {
+		t.Errorf("SingleOrEmpty(one) = %q, %v, want r1", got, err)
+	}
+
+	_, err := SingleOrEmpty([]*Role{{ID: "r1"}, {ID: "r2"}}, id)
+	var ambiguous *AmbiguousMatchError
+	if !errors.As(err, &ambiguous) || !slices.Equal(ambiguous.IDs, []string{"r1", "r2"}) {
+		t.Errorf("SingleOrEmpty(two) = %v, want an AmbiguousMatchError listing r1 and r2", err)
+	}
+}
+
+func TestOwnerMarker(t *testing.T) {
+	type call struct {
+		externalName string
+		found        string
+	}
+	cases := map[string]struct {
+		mayWrite bool
+		calls    []call
+		wantPuts int
+	}{
+		"ObserveOnlyAdopts":          {calls: []call{{found: "c1"}}},
+		"ObserveOnlyObservesCreated": {calls: []call{{}, {externalName: "c1"}}},
+		"AdoptMarks":                 {mayWrite: true, calls: []call{{found: "c1"}}, wantPuts: 1},
+		"CreateMarksOnce":            {mayWrite: true, calls: []call{{}, {externalName: "c1"}, {externalName: "c1"}}, wantPuts: 1},
+		"ObserveDoesNotMark":         {mayWrite: true, calls: []call{{externalName: "c1"}}},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var puts int
+			owners := map[string]string{}
+			var found string
+			lookupConfig := IdentifyingPropertiesLookupConfig{
+				RequiredParameters: []string{"realm_id"},
+				GetIDByExternalName: func(_ context.Context, id string, _ map[string]any, _ *keycloak.KeycloakClient) (string, error) {
+					return id, nil
+				},
+				GetIDByIdentifyingProperties: func(context.Context, map[string]any, *keycloak.KeycloakClient) (string, error) {
+					return found, nil
+				},
+				Ownership: &Ownership{
+					GetOwner: func(_ context.Context, id string, _ map[string]any, _ *keycloak.KeycloakClient) (string, error) {
+						return owners[id], nil
+					},
+					SetOwner: func(_ context.Context, id string, _ map[string]any, _ *keycloak.KeycloakClient, owner string) error {
+						puts++
+						owners[id] = owner
+						return nil
+					},
+				},
+			}
+			setup := testSetup(t, name, v1beta1.AdoptionPolicyAdopt, tc.mayWrite)
+			for _, c := range tc.calls {
+				found = c.found
+				if _, err := GetIDFromIdentifyingProperties(context.Background(), c.externalName, map[string]any{"realm_id": "test"}, setup, lookupConfig); err != nil {
+					t.Fatalf("GetIDFromIdentifyingProperties(%q): %v", c.externalName, err)
+				}
+			}
+			if puts != tc.wantPuts {
+				t.Errorf("ownership marker writes: got %d, want %d", puts, tc.wantPuts)
+			}
+		})
+	}
+}
+
+func TestIgnoreClientOwnerDiff(t *testing.T) {
+	diff := &terraformSDK.InstanceDiff{Attributes: map[string]*terraformSDK.ResourceAttrDiff{
+		"extra_config.%": {Old: "2", New: "1"},
+		"extra_config." + adoption.OwnerAttribute: {Old: "Client/app", NewRemoved: true},
+	}}
+	got, err := IgnoreClientOwnerDiff(diff, nil, nil)
+	if err != nil || !got.Empty() {
+		t.Errorf("IgnoreClientOwnerDiff(removal of the marker) = %+v, %v, want an empty diff", got.Attributes, err)
+	}
+
+	diff = &terraformSDK.InstanceDiff{Attributes: map[string]*terraformSDK.ResourceAttrDiff{
+		"extra_config.%":   {Old: "2", New: "3"},
+		"extra_config.foo": {New: "bar"},
+	}}
+	if got, _ := IgnoreClientOwnerDiff(diff, nil, nil); len(got.Attributes) != 2 {
+		t.Errorf("IgnoreClientOwnerDiff(other changes) = %+v, want them kept", got.Attributes)
+	}
+}
+
+// testSetup returns the Terraform setup of a managed resource with the given
+// name and adoption policy, whose lookups borrow offline clients.
+func testSetup(t *testing.T, name string, policy v1beta1.AdoptionPolicy, mayWrite bool) map[string]any {
+	t.Helper()
+	cfg := terraform.ProviderConfiguration{"url": "https://keycloak.test/" + name}
+	key := keycloaksession.ConfigCacheKey(cfg)
+	pool := tfconcurrency.NewPool(1, func(context.Context) (*keycloak.KeycloakClient, error) {
+		return &keycloak.KeycloakClient{}, nil
+	})
+	tfconcurrency.RegisterKey(key, pool)
+	t.Cleanup(func() { tfconcurrency.UnregisterKey(key, pool) })
+	return map[string]any{
+		"configuration":   cfg,
+		"client_metadata": adoption.ClientMetadata(policy, "Kind/"+name, types.UID("uid-"+name), mayWrite),
+	}
+}
+
+func TestSingletonIgnoresAdoptionPolicy(t *testing.T) {
+	exists := func(context.Context, map[string]any, *keycloak.KeycloakClient) (bool, error) { return true, nil }
+	for _, singleton := range []bool{true, false} {
+		ext := BuildCompositeKeyLookup(CompositeKeyLookupConfig{
+			RequiredParameters: []string{"realm_id"},
+			Key:                func(parameters map[string]any) string { return Param(parameters, "realm_id") },
+			Exists:             exists,
+			Singleton:          singleton,
+		})
+		setup := testSetup(t, "singleton-"+strconv.FormatBool(singleton), v1beta1.AdoptionPolicyFailIfExists, true)
+		id, err := ext.GetIDFn(context.Background(), "", map[string]any{"realm_id": "test"}, setup)
+		if singleton && (id != "test" || err != nil) {
+			t.Errorf("GetIDFn(singleton) under FailIfExists = %q, %v, want it adopted", id, err)
+		}
+		if !singleton && err == nil {
+			t.Errorf("GetIDFn(existing object) under FailIfExists = %q, want an error", id)
+		}
+	}
+}
+
+func TestAmbiguousExternalName(t *testing.T) {
+	lookupConfig := IdentifyingPropertiesLookupConfig{
+		RequiredParameters: []string{"realm_id"},
+		GetIDByExternalName: KeyByExternalName(func(context.Context, map[string]any, *keycloak.KeycloakClient) (string, error) {
+			return "", &AmbiguousMatchError{IDs: []string{"r1", "r2"}}
+		}),
+		GetIDByIdentifyingProperties: func(context.Context, map[string]any, *keycloak.KeycloakClient) (string, error) {
+			return "", nil
+		},
+	}
+	setup := testSetup(t, "ambiguous", v1beta1.AdoptionPolicyAdopt, true)
+	_, err := GetIDFromIdentifyingProperties(context.Background(), "r1", map[string]any{"realm_id": "test"}, setup, lookupConfig)
+	if err == nil || !strings.HasPrefix(err.Error(), string(adoption.ReasonAmbiguousMatch)+": ") {
+		t.Errorf("GetIDFromIdentifyingProperties(ambiguous external-name) = %v, want an %s error", err, adoption.ReasonAmbiguousMatch)
+	}
+}
@@ -4,12 +4,13 @@ import (
 	"context"
 	"fmt"
 	"strconv"
-	"sync"
 
 	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
 	"github.com/crossplane/upjet/v2/pkg/terraform"
+	terraformSDK "github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 	"github.com/keycloak/terraform-provider-keycloak/keycloak"
 
+	"github.com/crossplane-contrib/provider-keycloak/internal/adoption"
 	"github.com/crossplane-contrib/provider-keycloak/internal/keycloaksession"
 )
 
@@ -24,155 +25,11 @@ type Component struct {
 	Config       map[string][]string `json:"config"`
 }
 
-// cachedKeycloakClient holds a cached *keycloak.KeycloakClient alongside
-// the configuration that produced it, for logout on shutdown.
-type cachedKeycloakClient struct {
-	client *keycloak.KeycloakClient
-	config map[string]any
-}
-
-// keycloakClientCache caches *keycloak.KeycloakClient instances keyed
-// by a SHA-256 hash of the provider configuration to avoid creating a
-// new login session on every lookup call.
-var (
-	keycloakClientCache   sync.Map // map[string]*cachedKeycloakClient
-	keycloakClientCacheMu sync.Mutex
-)
-
-// lookupClientMu holds a per-configuration mutex that serializes access to the
-// shared lookup client, which is not safe for concurrent use.
-var lookupClientMu sync.Map // map[string]*sync.Mutex
-
-// lockForLookupConfig locks the per-configuration lookup mutex and returns its
-// unlock function.
-func lockForLookupConfig(terraformProviderConfig map[string]any) func() {
+// lookupConfigKey returns the cache key of the provider configuration passed
+// to a lookup.
+func lookupConfigKey(terraformProviderConfig map[string]any) string {
 	c := terraformProviderConfig["configuration"].(terraform.ProviderConfiguration)
-	key := keycloaksession.ConfigCacheKey(c)
-	v, _ := lookupClientMu.LoadOrStore(key, &sync.Mutex{})
-	m := v.(*sync.Mutex)
-	m.Lock()
-	return m.Unlock
-}
-
-// newKeycloakClient creates a new keycloak client based on the settings in the provider configuration
-// (This can be removed once this issue is resolved: https://github.com/crossplane/upjet/issues/464)
-func newKeycloakClient(ctx context.Context, terraformProviderConfig map[string]any) (*keycloak.KeycloakClient, error) {
-	c := terraformProviderConfig["configuration"].(terraform.ProviderConfiguration)
-
-	cacheKey := keycloaksession.ConfigCacheKey(c)
-	if cached, ok := keycloakClientCache.Load(cacheKey); ok {
-		return cached.(*cachedKeycloakClient).client, nil
-	}
-
-	// Not cached yet – create under a mutex so concurrent lookup calls
-	// for the same configuration only log in once.
-	keycloakClientCacheMu.Lock()
-	defer keycloakClientCacheMu.Unlock()
-	if cached, ok := keycloakClientCache.Load(cacheKey); ok {
-		return cached.(*cachedKeycloakClient).client, nil
-	}
-
-	url := tryGetString(c, "url", "")
-	basePath := tryGetString(c, "base_path", "")
-	adminUrl := tryGetString(c, "admin_url", "")
-	clientID := tryGetString(c, "client_id", "")
-	clientSecret := tryGetString(c, "client_secret", "")
-	username := tryGetString(c, "username", "")
-	password := tryGetString(c, "password", "")
-	accessToken := tryGetString(c, "access_token", "")
-	realm := tryGetString(c, "realm", "master")
-	jwtSigningAlg := tryGetString(c, "jwt_signing_alg", "RS256")
-	jwtSigningKey := tryGetString(c, "jwt_signing_key", "")
-	jwtToken := tryGetString(c, "jwt_token", "")
-	jwtTokenFile := tryGetString(c, "jwt_token_file", "")
-	initialLogin := tryGetBool(c, "initial_login", true)
-	clientTimeout := tryGetInt(c, "client_timeout", 15)
-	tlsInsecureSkipVerify := tryGetBool(c, "tls_insecure_skip_verify", false)
-	rootCaCertificate := tryGetString(c, "root_ca_certificate", "")
-	tlsClientCert := tryGetString(c, "tls_client_certificate", "")
-	tlsClientPrivateKey := tryGetString(c, "tls_client_private_key", "")
-	redHatSSO := tryGetBool(c, "red_hat_sso", false)
-	additionalHeaders := tryGetMap(c, "additional_headers")
-	keycloakVersion := tryGetString(c, "keycloak_version", "")
-	userAgent := "Crossplane Keycloak Provider"
-
-	keycloakClient, err := keycloak.NewKeycloakClient(
-		ctx,
-		url,
-		basePath,
-		adminUrl,
-		clientID,
-		clientSecret,
-		realm,
-		username,
-		password,
-		accessToken,
-		jwtSigningAlg,
-		jwtSigningKey,
-		jwtToken,
-		jwtTokenFile,
-		initialLogin,
-		clientTimeout,
-		rootCaCertificate,
-		tlsInsecureSkipVerify,
-		tlsClientCert,
-		tlsClientPrivateKey,
-		userAgent,
-		redHatSSO,
-		additionalHeaders,
-		keycloakVersion)
-	if err != nil {
-		return nil, err
-	}
-
-	// Store only the fields needed for logout to reduce sensitive
-	// credential exposure in process memory.
-	keycloakClientCache.Store(cacheKey, &cachedKeycloakClient{
-		client: keycloakClient,
-		config: keycloaksession.LogoutConfig(c),
-	})
-	return keycloakClient, nil
-}
-
-func tryGetString(m map[string]any, key string, defaultValue string) string {
-	value, ok := m[key]
-	if ok {
-		return value.(string)
-	}
-	return defaultValue
-}
-
-func tryGetBool(m map[string]any, key string, defaultValue bool) bool {
-	if value, ok := m[key]; ok {
-		switch v := value.(type) {
-		case bool:
-			return v
-		case string:
-			if parsed, err := strconv.ParseBool(v); err == nil {
-				return parsed
-			}
-		}
-	}
-	return defaultValue
-}
-
-func tryGetInt(m map[string]any, key string, defaultValue int) int {
-	value, ok := m[key]
-	if ok {
-		return value.(int)
-	}
-	return defaultValue
-}
-
-func tryGetMap(m map[string]any, key string) map[string]string {
-	value, ok := m[key]
-	result := make(map[string]string)
-	if ok {
-		for k, v := range value.(map[string]interface{}) {
-			result[k] = v.(string)
-		}
-	}
-	return result
+	return keycloaksession.ConfigCacheKey(c)
 }
 
 // GetComponentId returns the components id of the specified realm, type, parent, providerId and name
@@ -211,18 +68,37 @@ func GetComponent(kcClient *keycloak.KeycloakClient, ctx context.Context, realmI
 	// Currently the Keycloak API allows to add multiple Components with the SAME name
 	// If this is the case an error would be thrown here
 	if len(filtered) > 1 {
-		return nil, errors.New("Too many resources found, which match the identifying parameters. Expected 0 or 1, but was " + strconv.Itoa(len(filtered)))
+		return nil, Ambiguous(filtered, func(component *Component) string {
+			return component.Id
+		})
 	}
 
 	return filtered[0], nil
 }
 
+// GetComponentByID returns the component with the specified id of the specified realm
+func GetComponentByID(kcClient *keycloak.KeycloakClient, ctx context.Context, realmId, id string) (*Component, error) {
+	var component Component
+	if err := keycloaksession.AdminGet(ctx, kcClient, fmt.Sprintf("/realms/%s/components/%s", realmId, id), nil, &component); err != nil {
+		return nil, err
+	}
+	return &component, nil
+}
+
 // GetComponents returns the components of the specified realm, type and name
 // This needs to be removed in the future.
 // We need to clarify with terraform-provider-keycloak maintainers if we could add a GetComponents method
 // Currently we need this i.e. because there is no method to list all RealmKeystoreRsa
 // or to get the RealmKeystoreRsa by name
+// Components of a type are served from the cached listing of the components of
+// the realm with that type if they are found there.
 func GetComponents(kcClient *keycloak.KeycloakClient, ctx context.Context, realmId string, typ, parent, name *string) ([]*Component, error) {
+	if typ != nil {
+		if found := findListedComponents(ctx, kcClient, realmId, *typ, parent, name); len(found) > 0 {
+			return found, nil
+		}
+	}
+
 	params := make(map[string]string)
 	if typ != nil {
 		params["type"] = *typ
@@ -238,7 +114,7 @@ func GetComponents(kcClient *keycloak.KeycloakClient, ctx context.Context, realm
 
 	var components []*Component
 
-	err := keycloakClientGet(kcClient, ctx, fmt.Sprintf("/realms/%s/components", realmId), &components, params)
+	err := keycloaksession.AdminGet(ctx, kcClient, fmt.Sprintf("/realms/%s/components", realmId), params, &components)
 	if err != nil {
 		return nil, err
 	}
@@ -246,6 +122,81 @@ func GetComponents(kcClient *keycloak.KeycloakClient, ctx context.Context, realm
 	return components, nil
 }
 
+// ClientOwnership reads and writes the ownership marker of OpenID and SAML
+// clients, which is kept in their attributes.
+var ClientOwnership = &Ownership{GetOwner: getClientOwner, SetOwner: setClientOwner}
+
+func getClientOwner(ctx context.Context, id string, parameters map[string]any, kcClient *keycloak.KeycloakClient) (string, error) {
+	var client map[string]any
+	if err := keycloaksession.AdminGet(ctx, kcClient, clientPath(parameters, id), nil, &client); err != nil {
+		return "", err
+	}
+	attributes, _ := client["attributes"].(map[string]any)
+	owner, _ := attributes[adoption.OwnerAttribute].(string)
+	return owner, nil
+}
+
+// setClientOwner writes only the ownership marker. Keycloak updates only the
+// fields present in the representation of a client, and adds to its
+// attributes rather than replacing them, so the rest of the client, which its
+// managed resource may be updating concurrently, is left alone.
+func setClientOwner(ctx context.Context, id string, parameters map[string]any, kcClient *keycloak.KeycloakClient, owner string) error {
+	client := map[string]any{
+		"attributes": map[string]string{adoption.OwnerAttribute: owner},
+	}
+	return keycloaksession.AdminPut(ctx, kcClient, clientPath(parameters, id), client)
+}
+
+// IgnoreClientOwnerDiff is the Terraform custom diff of OpenID and SAML
+// clients. It dismisses the removal of the ownership marker from their
+// extra_config, which Terraform would otherwise plan on every reconciliation
+// of a client whose extra_config does not carry it.
+func IgnoreClientOwnerDiff(diff *terraformSDK.InstanceDiff, _ *terraformSDK.InstanceState, _ *terraformSDK.ResourceConfig) (*terraformSDK.InstanceDiff, error) {
+	if diff == nil {
+		return diff, nil
+	}
+	owner, ok := diff.Attributes["extra_config."+adoption.OwnerAttribute]
+	if !ok || !owner.NewRemoved {
+		return diff, nil
+	}
+	delete(diff.Attributes, "extra_config."+adoption.OwnerAttribute)
+	if count, ok := diff.Attributes["extra_config.%"]; ok {
+		o, errOld := strconv.Atoi(count.Old)
+		n, errNew := strconv.Atoi(count.New)
+		if errOld == nil && errNew == nil && o == n+1 {
+			delete(diff.Attributes, "extra_config.%")
+		}
+	}
+	return diff, nil
+}
+
+// clientPath returns the admin API path of the client with the given ID in
+// the realm of the parameters.
+func clientPath(parameters map[string]any, id string) string {
+	return fmt.Sprintf("/realms/%s/clients/%s", parameters["realm_id"].(string), id)
+}
+
+// GetProtocolMapperID returns the id of the protocol mapper with the specified name of the specified clientId or clientScopeId
+// or an empty string if there is none
+func GetProtocolMapperID(kcClient *keycloak.KeycloakClient, ctx context.Context, realmId, clientId, clientScopeId, name string) (string, error) {
+	if id := FindProtocolMapperID(ctx, kcClient, realmId, clientId, clientScopeId, name); id != "" {
+		return id, nil
+	}
+
+	found, err := GetGenericProtocolMappers(kcClient, ctx, realmId, clientId, clientScopeId)
+	if err != nil {
+		return "", err
+	}
+
+	filtered := Filter(found.ProtocolMappers, func(mapper *keycloak.GenericProtocolMapper) bool {
+		return mapper.Name == name
+	})
+
+	return SingleOrEmpty(filtered, func(mapper *keycloak.GenericProtocolMapper) string {
+		return mapper.Id
+	})
+}
+
 type GenericProtocolMappers struct {
 	ProtocolMappers []*keycloak.GenericProtocolMapper
 }
@@ -275,7 +226,7 @@ func GetGenericProtocolMappers(kcClient *keycloak.KeycloakClient, ctx context.Co
 		id = clientScopeId
 	}
 
-	err := keycloakClientGet(kcClient, ctx, fmt.Sprintf("/realms/%s/%s/%s", realmId, typ, id), &genericProtocolMappers, nil)
+	err := keycloaksession.AdminGet(ctx, kcClient, fmt.Sprintf("/realms/%s/%s/%s", realmId, typ, id), nil, &genericProtocolMappers)
 	if err != nil {
 		return nil, err
 	}
@@ -292,14 +243,3 @@ func GetGenericProtocolMappers(kcClient *keycloak.KeycloakClient, ctx context.Co
 
 	return &genericProtocolMappers, nil
 }
-
-// CleanupLookupSessions logs out all cached lookup Keycloak sessions.
-// It should be called during graceful shutdown.
-func CleanupLookupSessions(ctx context.Context) {
-	keycloakClientCache.Range(func(key, value any) bool {
-		entry := value.(*cachedKeycloakClient)
-		keycloaksession.LogoutSession(ctx, entry.config, entry.client)
-		keycloakClientCache.Delete(key)
-		return true
-	})
-}
@@ -0,0 +1,420 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/keycloak/terraform-provider-keycloak/keycloak"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/crossplane-contrib/provider-keycloak/internal/keycloaksession"
+)
+
+// The collections of a realm whose listings are cached.
+const (
+	collectionClients      = "clients"
+	collectionClientScopes = "client_scopes"
+	collectionRoles        = "roles"
+	collectionGroups       = "groups"
+	collectionComponents   = "components"
+	// The realm itself, and the checks whether a realm object has entries,
+	// e.g. the role mappings of a group, are cached like listings.
+	collectionRealm   = "realm"
+	collectionEntries = "entries"
+)
+
+const (
+	resultHit  = "hit"
+	resultMiss = "miss"
+
+	// listingPageSize is the number of objects requested per page of the
+	// collections that are paged.
+	listingPageSize = 500
+)
+
+var cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "keycloak",
+	Subsystem: "lookup_cache",
+	Name:      "requests_total",
+	Help:      "Number of lookups by identifying properties that were answered from a cached listing of a realm collection (hit), or not (miss), by collection.",
+}, []string{"collection", "result"})
+
+// Collectors returns the Prometheus collectors of this package. They must be
+// registered once, e.g. with the controller-runtime metrics registry.
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{cacheRequests}
+}
+
+// listings caches the listings of the collections of the realms, so that
+// importing thousands of objects of a realm, e.g. its roles, lists them once
+// instead of searching for each of them. Only objects found in a listing are
+// served from it: a lookup that does not find its object in a listing falls
+// back to a search, so that an object created after the listing is never
+// missed and thus never created twice.
+var listings = &listingCache{ttl: 30 * time.Second, entries: map[string]*listing{}}
+
+// SetListingCacheTTL sets the time the listings of the collections of a realm
+// are served from the cache. 0 disables the cache.
+func SetListingCacheTTL(ttl time.Duration) {
+	listings.mu.Lock()
+	defer listings.mu.Unlock()
+	listings.ttl = ttl
+	listings.entries = map[string]*listing{}
+}
+
+// InvalidateListings drops the cached listings of the given realm for the
+// provider configuration with the given cache key.
+func InvalidateListings(configKey, realm string) {
+	listings.invalidate(listingKey(configKey, realm, ""))
+}
+
+type listingCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*listing
+}
+
+// A listing is the listing of a collection. It is being fetched until done is
+// closed.
+type listing struct {
+	done    chan struct{}
+	items   any
+	err     error
+	expires time.Time
+}
+
+// get returns the listing with the given key, fetching it if it is not cached
+// or expired. Concurrent lookups of the same listing share a single fetch.
+// cached reports whether the listing was cached or being fetched already.
+func (c *listingCache) get(key string, fetch func() (any, error)) (items any, cached bool, err error) {
+	c.mu.Lock()
+	if c.ttl <= 0 {
+		c.mu.Unlock()
+		items, err = fetch()
+		return items, false, err
+	}
+	now := time.Now()
+	if l, ok := c.entries[key]; ok && (l.expires.IsZero() || now.Before(l.expires)) {
+		c.mu.Unlock()
+		<-l.done
+		return l.items, true, l.err
+	}
+	for k, l := range c.entries {
+		if !l.expires.IsZero() && !now.Before(l.expires) {
+			delete(c.entries, k)
+		}
+	}
+	l := &listing{done: make(chan struct{})}
+	c.entries[key] = l
+	ttl := c.ttl
+	c.mu.Unlock()
+
+	l.items, l.err = fetch()
+	c.mu.Lock()
+	if l.err != nil && c.entries[key] == l {
+		delete(c.entries, key)
+	}
+	l.expires = time.Now().Add(ttl)
+	c.mu.Unlock()
+	close(l.done)
+	return l.items, false, l.err
+}
+
+func (c *listingCache) invalidate(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k := range c.entries {
+		if strings.HasPrefix(k, prefix) {
+			delete(c.entries, k)
+		}
+	}
+}
+
+func listingKey(configKey, realm, collection string, sub ...string) string {
+	return strings.Join(append([]string{configKey, realm, collection}, sub...), "\x00")
+}
+
+type listingScopeKey struct{}
+
+// withListingScope returns a context whose lookups share the cached listings
+// of the provider configuration with the given cache key.
+func withListingScope(ctx context.Context, configKey string) context.Context {
+	return context.WithValue(ctx, listingScopeKey{}, configKey)
+}
+
+// findListed returns the single object of the listing of a collection of the
+// realm that matches, or nil if there is none, there are several, or the
+// listing cannot be served. Lookups outside of GetIDFromIdentifyingProperties
+// have no listing scope and are never served from the cache.
+func findListed[T any](ctx context.Context, realm, collection, sub string, list func() ([]*T, error), match func(*T) bool) *T {
+	configKey, ok := ctx.Value(listingScopeKey{}).(string)
+	if !ok {
+		return nil
+	}
+	items, cached, err := listings.get(listingKey(configKey, realm, collection, sub), func() (any, error) {
+		return list()
+	})
+	if err != nil {
+		cacheRequests.WithLabelValues(collection, resultMiss).Inc()
+		return nil
+	}
+	found := Filter(items.([]*T), match)
+	if len(found) != 1 {
+		cacheRequests.WithLabelValues(collection, resultMiss).Inc()
+		return nil
+	}
+	if cached {
+		cacheRequests.WithLabelValues(collection, resultHit).Inc()
+	} else {
+		cacheRequests.WithLabelValues(collection, resultMiss).Inc()
+	}
+	return found[0]
+}
+
+// errNoEntries is the error a check that finds no entries is cached with, so
+// that it is not cached.
+var errNoEntries = errors.New("no entries")
+
+// cachedGet returns the result of fetch, from the cache if a lookup of the
+// same configuration fetched it within the TTL. A failed fetch, e.g. of an
+// object that does not exist, is not cached. Lookups outside of
+// GetIDFromIdentifyingProperties have no listing scope and always fetch.
+func cachedGet[T any](ctx context.Context, realm, collection string, sub []string, fetch func() (T, error)) (T, error) {
+	configKey, ok := ctx.Value(listingScopeKey{}).(string)
+	if !ok {
+		return fetch()
+	}
+	v, cached, err := listings.get(listingKey(configKey, realm, collection, sub...), func() (any, error) {
+		return fetch()
+	})
+	if err != nil {
+		cacheRequests.WithLabelValues(collection, resultMiss).Inc()
+		var zero T
+		return zero, err
+	}
+	if cached {
+		cacheRequests.WithLabelValues(collection, resultHit).Inc()
+	} else {
+		cacheRequests.WithLabelValues(collection, resultMiss).Inc()
+	}
+	return v.(T), nil
+}
+
+// cachedCheck returns the result of check, which reports whether the realm
+// object at path has entries, from the cache if it had some within the TTL.
+// A check that finds none is not cached, so that entries added since are
+// never missed.
+func cachedCheck(ctx context.Context, path string, check func() (bool, error), sub ...string) (bool, error) {
+	found, err := cachedGet(ctx, realmOfPath(path), collectionEntries, append([]string{path}, sub...), func() (bool, error) {
+		found, err := check()
+		if err == nil && !found {
+			return false, errNoEntries
+		}
+		return found, err
+	})
+	if errors.Is(err, errNoEntries) {
+		return false, nil
+	}
+	return found, err
+}
+
+// realmOfPath returns the realm of path of the Keycloak admin API, e.g. test
+// for /realms/test/groups.
+func realmOfPath(path string) string {
+	parts := strings.SplitN(path, "/", 4)
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[2]
+}
+
+// paramsKey returns the key of the query parameters of a cached check.
+func paramsKey(params map[string]string) string {
+	return fmt.Sprint(params)
+}
+
+// listAll returns all objects at path of the Keycloak admin API, page by page
+// if the collection is paged.
+func listAll[T any](ctx context.Context, kcClient *keycloak.KeycloakClient, path string, params map[string]string, paged bool) ([]*T, error) {
+	if !paged {
+		var items []*T
+		err := keycloaksession.AdminGet(ctx, kcClient, path, params, &items)
+		return items, err
+	}
+	var all []*T
+	for first := 0; ; first += listingPageSize {
+		p := map[string]string{"first": strconv.Itoa(first), "max": strconv.Itoa(listingPageSize)}
+		for k, v := range params {
+			p[k] = v
+		}
+		var page []*T
+		if err := keycloaksession.AdminGet(ctx, kcClient, path, p, &page); err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if len(page) < listingPageSize {
+			return all, nil
+		}
+	}
+}
+
+type listedClient struct {
+	ID              string                            `json:"id"`
+	ClientID        string                            `json:"clientId"`
+	ProtocolMappers []*keycloak.GenericProtocolMapper `json:"protocolMappers"`
+}
+
+type listedClientScope struct {
+	ID              string                            `json:"id"`
+	Name            string                            `json:"name"`
+	Protocol        string                            `json:"protocol"`
+	ProtocolMappers []*keycloak.GenericProtocolMapper `json:"protocolMappers"`
+}
+
+type listedGroup struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// FindClientID returns the ID of the client of the realm with the given
+// clientId from the cached listing of the clients of the realm, or an empty
+// string if it is not listed there. Callers search for the client then.
+func FindClientID(ctx context.Context, kcClient *keycloak.KeycloakClient, realmID, clientID string) string {
+	c := findListed(ctx, realmID, collectionClients, "", func() ([]*listedClient, error) {
+		return listAll[listedClient](ctx, kcClient, "/realms/"+realmID+"/clients", nil, true)
+	}, func(c *listedClient) bool {
+		return c.ClientID == clientID
+	})
+	if c == nil {
+		return ""
+	}
+	return c.ID
+}
+
+// FindClientScopeID returns the ID of the client scope of the realm with the
+// given name and protocol from the cached listing of the client scopes of
+// the realm, or an empty string if it is not listed there.
+func FindClientScopeID(ctx context.Context, kcClient *keycloak.KeycloakClient, realmID, name, protocol string) string {
+	s := findListed(ctx, realmID, collectionClientScopes, "", func() ([]*listedClientScope, error) {
+		return listAll[listedClientScope](ctx, kcClient, "/realms/"+realmID+"/client-scopes", nil, false)
+	}, func(s *listedClientScope) bool {
+		return s.Name == name && s.Protocol == protocol
+	})
+	if s == nil {
+		return ""
+	}
+	return s.ID
+}
+
+// FindRoleID returns the ID of the role with the given name of the client
+// with the given ID, or of the realm if it is empty, from the cached listing
+// of its roles, or an empty string if it is not listed there.
+func FindRoleID(ctx context.Context, kcClient *keycloak.KeycloakClient, realmID, clientID, name string) string {
+	path := "/realms/" + realmID + "/roles"
+	if clientID != "" {
+		path = "/realms/" + realmID + "/clients/" + clientID + "/roles"
+	}
+	r := findListed(ctx, realmID, collectionRoles, clientID, func() ([]*Role, error) {
+		return listAll[Role](ctx, kcClient, path, map[string]string{"briefRepresentation": "true"}, true)
+	}, func(r *Role) bool {
+		return r.Name == name
+	})
+	if r == nil {
+		return ""
+	}
+	return r.ID
+}
+
+// FindGroupID returns the ID of the group with the given name below the
+// group with the given ID, or at the top level if it is empty, from the
+// cached listing of its groups, or an empty string if it is not listed there.
+func FindGroupID(ctx context.Context, kcClient *keycloak.KeycloakClient, realmID, parentID, name string) string {
+	path := "/realms/" + realmID + "/groups"
+	if parentID != "" {
+		path = "/realms/" + realmID + "/groups/" + parentID + "/children"
+	}
+	g := findListed(ctx, realmID, collectionGroups, parentID, func() ([]*listedGroup, error) {
+		return listAll[listedGroup](ctx, kcClient, path, map[string]string{"briefRepresentation": "true"}, true)
+	}, func(g *listedGroup) bool {
+		return g.Name == name
+	})
+	if g == nil {
+		return ""
+	}
+	return g.ID
+}
+
+// FindProtocolMapperID returns the ID of the protocol mapper with the given
+// name of the client or client scope with the given ID from the cached
+// listing of the clients or client scopes of the realm, which include their
+// protocol mappers, or an empty string if it is not listed there.
+func FindProtocolMapperID(ctx context.Context, kcClient *keycloak.KeycloakClient, realmID, clientID, clientScopeID, name string) string {
+	var mappers []*keycloak.GenericProtocolMapper
+	switch {
+	case clientID != "":
+		c := findListed(ctx, realmID, collectionClients, "", func() ([]*listedClient, error) {
+			return listAll[listedClient](ctx, kcClient, "/realms/"+realmID+"/clients", nil, true)
+		}, func(c *listedClient) bool {
+			return c.ID == clientID && hasProtocolMapper(c.ProtocolMappers, name)
+		})
+		if c != nil {
+			mappers = c.ProtocolMappers
+		}
+	case clientScopeID != "":
+		s := findListed(ctx, realmID, collectionClientScopes, "", func() ([]*listedClientScope, error) {
+			return listAll[listedClientScope](ctx, kcClient, "/realms/"+realmID+"/client-scopes", nil, false)
+		}, func(s *listedClientScope) bool {
+			return s.ID == clientScopeID && hasProtocolMapper(s.ProtocolMappers, name)
+		})
+		if s != nil {
+			mappers = s.ProtocolMappers
+		}
+	}
+	found := Filter(mappers, func(m *keycloak.GenericProtocolMapper) bool {
+		return m.Name == name
+	})
+	if len(found) != 1 {
+		return ""
+	}
+	return found[0].Id
+}
+
+func hasProtocolMapper(mappers []*keycloak.GenericProtocolMapper, name string) bool {
+	for _, m := range mappers {
+		if m.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// findListedComponents returns the components of the realm with the given
+// type, parent and name from the cached listing of the components of the
+// type, or nil if there are none or the listing cannot be served.
+func findListedComponents(ctx context.Context, kcClient *keycloak.KeycloakClient, realmID, typ string, parent, name *string) []*Component {
+	configKey, ok := ctx.Value(listingScopeKey{}).(string)
+	if !ok {
+		return nil
+	}
+	items, cached, err := listings.get(listingKey(configKey, realmID, collectionComponents, typ), func() (any, error) {
+		return listAll[Component](ctx, kcClient, "/realms/"+realmID+"/components", map[string]string{"type": typ}, false)
+	})
+	if err != nil {
+		cacheRequests.WithLabelValues(collectionComponents, resultMiss).Inc()
+		return nil
+	}
+	found := Filter(items.([]*Component), func(c *Component) bool {
+		return (parent == nil || c.ParentId == *parent) && (name == nil || c.Name == *name)
+	})
+	if len(found) == 0 || !cached {
+		cacheRequests.WithLabelValues(collectionComponents, resultMiss).Inc()
+		return found
+	}
+	cacheRequests.WithLabelValues(collectionComponents, resultHit).Inc()
+	return found
+}
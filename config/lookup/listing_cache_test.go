@@ -0,0 +1,106 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestListingCache(t *testing.T) {
+	c := &listingCache{ttl: time.Minute, entries: map[string]*listing{}}
+	var fetches atomic.Int32
+	fetch := func() (any, error) {
+		fetches.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return []*Role{{ID: "r1", Name: "admin"}}, nil
+	}
+
+	// Concurrent lookups of the same listing share a single fetch.
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := c.get(listingKey("cfg", "test", collectionRoles), fetch); err != nil {
+				t.Errorf("get(...): unexpected error %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("concurrent get(...): want 1 fetch, got %d", got)
+	}
+
+	if _, cached, _ := c.get(listingKey("cfg", "test", collectionRoles), fetch); !cached {
+		t.Error("get(...) of a fetched listing: want cached")
+	}
+	if _, cached, _ := c.get(listingKey("cfg", "other", collectionRoles), fetch); cached {
+		t.Error("get(...) of the listing of another realm: want not cached")
+	}
+
+	c.invalidate(listingKey("cfg", "test", ""))
+	if _, cached, _ := c.get(listingKey("cfg", "test", collectionRoles), fetch); cached {
+		t.Error("get(...) after invalidate(...): want not cached")
+	}
+	if _, cached, _ := c.get(listingKey("cfg", "other", collectionRoles), fetch); !cached {
+		t.Error("get(...) of the listing of another realm after invalidate(...): want cached")
+	}
+}
+
+func TestListingCacheErrorsAndExpiry(t *testing.T) {
+	c := &listingCache{ttl: 20 * time.Millisecond, entries: map[string]*listing{}}
+	key := listingKey("cfg", "test", collectionClients)
+
+	failed := errors.New("boom")
+	if _, _, err := c.get(key, func() (any, error) { return nil, failed }); !errors.Is(err, failed) {
+		t.Fatalf("get(...): want error %v, got %v", failed, err)
+	}
+	ok := func() (any, error) { return []*listedClient{}, nil }
+	if _, cached, err := c.get(key, ok); cached || err != nil {
+		t.Errorf("get(...) after a failed fetch: want a new fetch, got cached %v, error %v", cached, err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, cached, _ := c.get(key, ok); cached {
+		t.Error("get(...) of an expired listing: want a new fetch")
+	}
+
+	c.ttl = 0
+	if _, cached, _ := c.get(key, ok); cached {
+		t.Error("get(...) with the cache disabled: want a new fetch")
+	}
+}
+
+func TestCachedCheck(t *testing.T) {
+	ctx := withListingScope(context.Background(), "cfg-checks")
+	var checks int
+	found := false
+	check := func() (bool, error) {
+		checks++
+		return found, nil
+	}
+
+	// A check that finds no entries is never served from the cache.
+	for range 2 {
+		if got, err := cachedCheck(ctx, "/realms/test/groups/g1/members", check); got || err != nil {
+			t.Errorf("cachedCheck(...) = %v, %v, want false", got, err)
+		}
+	}
+	found = true
+	for range 2 {
+		if got, err := cachedCheck(ctx, "/realms/test/groups/g1/members", check); !got || err != nil {
+			t.Errorf("cachedCheck(...) = %v, %v, want true", got, err)
+		}
+	}
+	if checks != 3 {
+		t.Errorf("cachedCheck(...): want 3 checks, got %d", checks)
+	}
+
+	InvalidateListings("cfg-checks", "test")
+	if _, err := cachedCheck(ctx, "/realms/test/groups/g1/members", check); err != nil || checks != 4 {
+		t.Errorf("cachedCheck(...) after InvalidateListings(...): want a new check, got %d checks, error %v", checks, err)
+	}
+}
@@ -221,16 +221,48 @@ func getProtocolMapperIDByIdentifyingProperties(ctx context.Context, parameters
 		return "", errors.New("Either client_id or client_scope_id must be set")
 	}
 
-	found, err := lookup.GetGenericProtocolMappers(kcClient, ctx, parameters["realm_id"].(string), clientID, clientScopeID)
+	return lookup.GetProtocolMapperID(kcClient, ctx, parameters["realm_id"].(string), clientID, clientScopeID, parameters["name"].(string))
+}
+
+var roleMapperIdentifyingPropertiesLookup = lookup.IdentifyingPropertiesLookupConfig{
+	RequiredParameters:           []string{"realm_id", "role_id"},
+	OptionalParameters:           []string{"client_id", "client_scope_id"},
+	GetIDByExternalName:          lookup.KeyByExternalName(getRoleMapperIDByIdentifyingProperties),
+	GetIDByIdentifyingProperties: getRoleMapperIDByIdentifyingProperties,
+}
+
+// RoleMapperIdentifierFromIdentifyingProperties is used to find the existing
+// scope mapping of a role to a client or client scope. Its ID is
+// {realm}/client|client-scope/{id}/scope-mappings/{role}, with the ID of the
+// client of a client role before the role.
+var RoleMapperIdentifierFromIdentifyingProperties = lookup.BuildIdentifyingPropertiesLookup(roleMapperIdentifyingPropertiesLookup)
+
+func getRoleMapperIDByIdentifyingProperties(ctx context.Context, parameters map[string]any, kcClient *keycloak.KeycloakClient) (string, error) {
+	realmID := lookup.Param(parameters, "realm_id")
+	clientID := lookup.Param(parameters, "client_id")
+	clientScopeID := lookup.Param(parameters, "client_scope_id")
+	if clientID == "" && clientScopeID == "" {
+		return "", errors.New("Either client_id or client_scope_id must be set")
+	}
+	parentType, parentID := "client", clientID
+	if clientID == "" {
+		parentType, parentID = "client-scope", clientScopeID
+	}
+
+	role, err := lookup.GetRoleByID(ctx, kcClient, realmID, lookup.Param(parameters, "role_id"))
 	if err != nil {
 		return "", err
 	}
+	mappings := "realm"
+	key := lookup.Key(realmID, parentType, parentID, "scope-mappings", role.ID)
+	if role.ClientRole {
+		mappings = "clients/" + role.ContainerID
+		key = lookup.Key(realmID, parentType, parentID, "scope-mappings", role.ContainerID, role.ID)
+	}
 
-	filtered := lookup.Filter(found.ProtocolMappers, func(mapper *keycloak.GenericProtocolMapper) bool {
-		return mapper.Name == parameters["name"].(string)
-	})
-
-	return lookup.SingleOrEmpty(filtered, func(mapper *keycloak.GenericProtocolMapper) string {
-		return mapper.Id
-	})
+	found, err := lookup.MapsRole(ctx, kcClient, "/realms/"+realmID+"/"+parentType+"s/"+parentID+"/scope-mappings/"+mappings, role.ID)
+	if err != nil || !found {
+		return "", err
+	}
+	return key, nil
 }
@@ -81,6 +81,10 @@ func Configure(p *config.Provider) {
 
 		// Publish the client's credentials as connection details.
 		r.Sensitive.AdditionalConnectionDetailsFn = clientConnectionDetails
+
+		// The ownership marker the provider records in the attributes of an
+		// adopted or created client is not part of its spec.
+		r.TerraformCustomDiff = lookup.IgnoreClientOwnerDiff
 	})
 
 	p.AddResourceConfigurator("keycloak_openid_client_default_scopes", func(r *config.Resource) {
@@ -611,6 +615,7 @@ var clientIdentifyingPropertiesLookup = lookup.IdentifyingPropertiesLookupConfig
 	RequiredParameters:           []string{"realm_id", "client_id"},
 	GetIDByExternalName:          getClientIDByExternalName,
 	GetIDByIdentifyingProperties: getClientIDByIdentifyingProperties,
+	Ownership:                    lookup.ClientOwnership,
 }
 
 // ClientIdentifierFromIdentifyingProperties is used to find the existing resource by it´s identifying properties
@@ -625,6 +630,10 @@ func getClientIDByExternalName(ctx context.Context, id string, parameters map[st
 }
 
 func getClientIDByIdentifyingProperties(ctx context.Context, parameters map[string]any, kcClient *keycloak.KeycloakClient) (string, error) {
+	if id := lookup.FindClientID(ctx, kcClient, parameters["realm_id"].(string), parameters["client_id"].(string)); id != "" {
+		return id, nil
+	}
+
 	found, err := kcClient.GetGenericClientByClientId(ctx, parameters["realm_id"].(string), parameters["client_id"].(string))
 	if err != nil {
 		if strings.Contains(err.Error(), "does not exist") {
@@ -654,6 +663,10 @@ func getClientScopeIDByExternalName(ctx context.Context, id string, parameters m
 }
 
 func getClientScopeIDByIdentifyingProperties(ctx context.Context, parameters map[string]any, kcClient *keycloak.KeycloakClient) (string, error) {
+	if id := lookup.FindClientScopeID(ctx, kcClient, parameters["realm_id"].(string), parameters["name"].(string), "openid-connect"); id != "" {
+		return id, nil
+	}
+
 	found, err := kcClient.ListOpenidClientScopesWithFilter(ctx, parameters["realm_id"].(string), func(scope *keycloak.OpenidClientScope) bool {
 		return scope.Name == parameters["name"].(string)
 	})
@@ -974,3 +987,96 @@ func getAuthzPermissionIDByIdentifyingProperties(ctx context.Context, parameters
 	// be resolved by name through the policy endpoint.
 	return getAuthzPolicyIDByIdentifyingProperties(ctx, parameters, kcClient)
 }
+
+// PermissionsIdentifierFromIdentifyingProperties is used to find the existing
+// permissions of a client. Its ID is {realm}/{client}.
+var PermissionsIdentifierFromIdentifyingProperties = lookup.BuildCompositeKeyLookup(lookup.CompositeKeyLookupConfig{
+	RequiredParameters: []string{"realm_id", "client_id"},
+	Key:                clientKey,
+	Exists: func(ctx context.Context, parameters map[string]any, kcClient *keycloak.KeycloakClient) (bool, error) {
+		return lookup.PermissionsEnabled(ctx, kcClient, clientPath(parameters)+"/management/permissions")
+	},
+})
+
+// DefaultScopesIdentifierFromIdentifyingProperties is used to find the
+// existing default client scopes of an OpenID or SAML client, which are
+// managed by the same API. Its ID is {realm}/{client}.
+var DefaultScopesIdentifierFromIdentifyingProperties = clientScopesIdentifierFromIdentifyingProperties("default-client-scopes")
+
+// OptionalScopesIdentifierFromIdentifyingProperties is used to find the
+// existing optional client scopes of a client. Its ID is {realm}/{client}.
+var OptionalScopesIdentifierFromIdentifyingProperties = clientScopesIdentifierFromIdentifyingProperties("optional-client-scopes")
+
+// clientScopesIdentifierFromIdentifyingProperties returns the lookup of the
+// client scopes of a client at the given path below it.
+func clientScopesIdentifierFromIdentifyingProperties(scopes string) config.ExternalName {
+	return lookup.BuildCompositeKeyLookup(lookup.CompositeKeyLookupConfig{
+		RequiredParameters: []string{"realm_id", "client_id"},
+		Key:                clientKey,
+		Exists: func(ctx context.Context, parameters map[string]any, kcClient *keycloak.KeycloakClient) (bool, error) {
+			return lookup.HasEntries(ctx, kcClient, clientPath(parameters)+"/"+scopes, nil)
+		},
+	})
+}
+
+var serviceAccountRealmRoleIdentifyingPropertiesLookup = lookup.IdentifyingPropertiesLookupConfig{
+	RequiredParameters:           []string{"realm_id", "service_account_user_id", "role"},
+	GetIDByExternalName:          lookup.KeyByExternalName(getServiceAccountRealmRoleIDByIdentifyingProperties),
+	GetIDByIdentifyingProperties: getServiceAccountRealmRoleIDByIdentifyingProperties,
+}
+
+// ServiceAccountRealmRoleIdentifierFromIdentifyingProperties is used to find
+// the existing mapping of a realm role to a service account. Its ID is
+// {serviceAccountUser}/{role}.
+var ServiceAccountRealmRoleIdentifierFromIdentifyingProperties = lookup.BuildIdentifyingPropertiesLookup(serviceAccountRealmRoleIdentifyingPropertiesLookup)
+
+var serviceAccountRoleIdentifyingPropertiesLookup = lookup.IdentifyingPropertiesLookupConfig{
+	RequiredParameters:           []string{"realm_id", "service_account_user_id", "client_id", "role"},
+	GetIDByExternalName:          lookup.KeyByExternalName(getServiceAccountRoleIDByIdentifyingProperties),
+	GetIDByIdentifyingProperties: getServiceAccountRoleIDByIdentifyingProperties,
+}
+
+// ServiceAccountRoleIdentifierFromIdentifyingProperties is used to find the
+// existing mapping of a client role to a service account. Its ID is
+// {serviceAccountUser}/{role}.
+var ServiceAccountRoleIdentifierFromIdentifyingProperties = lookup.BuildIdentifyingPropertiesLookup(serviceAccountRoleIdentifyingPropertiesLookup)
+
+func getServiceAccountRealmRoleIDByIdentifyingProperties(ctx context.Context, parameters map[string]any, kcClient *keycloak.KeycloakClient) (string, error) {
+	return getServiceAccountRoleID(ctx, parameters, kcClient, "", "realm")
+}
+
+func getServiceAccountRoleIDByIdentifyingProperties(ctx context.Context, parameters map[string]any, kcClient *keycloak.KeycloakClient) (string, error) {
+	clientID := lookup.Param(parameters, "client_id")
+	return getServiceAccountRoleID(ctx, parameters, kcClient, clientID, "clients/"+clientID)
+}
+
+// getServiceAccountRoleID returns the key of the mapping of the role named by
+// the parameters, of the client with the given ID or of the realm if it is
+// empty, to the service account user, if the mapping exists.
+func getServiceAccountRoleID(ctx context.Context, parameters map[string]any, kcClient *keycloak.KeycloakClient, clientID, mappings string) (string, error) {
+	realmID := lookup.Param(parameters, "realm_id")
+	userID := lookup.Param(parameters, "service_account_user_id")
+
+	name := lookup.Param(parameters, "role")
+	roleID := lookup.FindRoleID(ctx, kcClient, realmID, clientID, name)
+	if roleID == "" {
+		role, err := kcClient.GetRoleByName(ctx, realmID, clientID, name)
+		if err != nil {
+			return "", err
+		}
+		roleID = role.Id
+	}
+	found, err := lookup.MapsRole(ctx, kcClient, "/realms/"+realmID+"/users/"+userID+"/role-mappings/"+mappings, roleID)
+	if err != nil || !found {
+		return "", err
+	}
+	return lookup.Key(userID, roleID), nil
+}
+
+func clientKey(parameters map[string]any) string {
+	return lookup.Key(lookup.Param(parameters, "realm_id"), lookup.Param(parameters, "client_id"))
+}
+
+func clientPath(parameters map[string]any) string {
+	return "/realms/" + lookup.Param(parameters, "realm_id") + "/clients/" + lookup.Param(parameters, "client_id")
+}
@@ -92,18 +92,7 @@ func getIDByIdentifyingProperties(ctx context.Context, parameters map[string]any
 	if err != nil {
 		return "", err
 	}
-	found, err := lookup.GetGenericProtocolMappers(kcClient, ctx, parameters["realm_id"].(string), clientID, clientScopeID)
-	if err != nil {
-		return "", err
-	}
-
-	filtered := lookup.Filter(found.ProtocolMappers, func(mapper *keycloak.GenericProtocolMapper) bool {
-		return mapper.Name == parameters["name"].(string)
-	})
-
-	return lookup.SingleOrEmpty(filtered, func(mapper *keycloak.GenericProtocolMapper) string {
-		return mapper.Id
-	})
+	return lookup.GetProtocolMapperID(kcClient, ctx, parameters["realm_id"].(string), clientID, clientScopeID, parameters["name"].(string))
 }
 
 var openidProtocolMapperIdentifyingPropertiesLookup = lookup.IdentifyingPropertiesLookupConfig{
@@ -133,18 +122,7 @@ func getOpenidProtocolMapperIDByIdentifyingProperties(ctx context.Context, param
 	if err != nil {
 		return "", err
 	}
-	found, err := lookup.GetGenericProtocolMappers(kcClient, ctx, parameters["realm_id"].(string), clientID, clientScopeID)
-	if err != nil {
-		return "", err
-	}
-
-	filtered := lookup.Filter(found.ProtocolMappers, func(mapper *keycloak.GenericProtocolMapper) bool {
-		return mapper.Name == parameters["name"].(string)
-	})
-
-	return lookup.SingleOrEmpty(filtered, func(mapper *keycloak.GenericProtocolMapper) string {
-		return mapper.Id
-	})
+	return lookup.GetProtocolMapperID(kcClient, ctx, parameters["realm_id"].(string), clientID, clientScopeID, parameters["name"].(string))
 }
 
 func getProtocolMapperAttachmentIDs(parameters map[string]any) (string, string, error) {
@@ -22,7 +22,7 @@ func Configure(p *config.Provider) {
 }
 
 var organizationIdentifyingPropertiesLookup = lookup.IdentifyingPropertiesLookupConfig{
-	RequiredParameters:           []string{"realm", "name", "domain"},
+	RequiredParameters:           []string{"realm", "name"},
 	GetIDByExternalName:          getOrganizationIDByExternalName,
 	GetIDByIdentifyingProperties: getOrganizationIDByIdentifyingProperties,
 }
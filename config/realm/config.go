@@ -5,11 +5,13 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
 	"github.com/crossplane/upjet/v2/pkg/config"
 	"github.com/keycloak/terraform-provider-keycloak/keycloak"
 
 	"github.com/crossplane-contrib/provider-keycloak/config/common"
 	"github.com/crossplane-contrib/provider-keycloak/config/lookup"
+	"github.com/crossplane-contrib/provider-keycloak/internal/keycloaksession"
 )
 
 // Group is the short group name for the resources in this package
@@ -228,11 +230,152 @@ func getClientRegistrationPolicyIDByIdentifyingProperties(ctx context.Context, p
 		return "", err
 	}
 
-	for _, policy := range policies {
-		if policy.Name == name && policy.ProviderId == providerId && policy.SubType == subType {
-			return policy.Id, nil
-		}
+	filtered := lookup.Filter(policies, func(policy *keycloak.RealmClientRegistrationPolicy) bool {
+		return policy.Name == name && policy.ProviderId == providerId && policy.SubType == subType
+	})
+
+	return lookup.SingleOrEmpty(filtered, func(policy *keycloak.RealmClientRegistrationPolicy) string {
+		return policy.Id
+	})
+}
+
+// GeneratedKeystoreIdentifierFromIdentifyingProperties returns the lookup of
+// the keystores of a realm with the given provider ID, such as aes-generated,
+// which have no provider_id parameter of their own. The keystores are found
+// by their name.
+func GeneratedKeystoreIdentifierFromIdentifyingProperties(providerID string) config.ExternalName {
+	return lookup.BuildIdentifyingPropertiesLookup(lookup.IdentifyingPropertiesLookupConfig{
+		RequiredParameters:  []string{"realm_id", "name"},
+		GetIDByExternalName: getKeystoreIDByExternalName,
+		GetIDByIdentifyingProperties: func(ctx context.Context, parameters map[string]any, kcClient *keycloak.KeycloakClient) (string, error) {
+			typ := "org.keycloak.keys.KeyProvider"
+			providerID := providerID
+			name := parameters["name"].(string)
+
+			return lookup.GetComponentId(kcClient, ctx, parameters["realm_id"].(string), &typ, nil, &providerID, &name)
+		},
+	})
+}
+
+func getKeystoreIDByExternalName(ctx context.Context, id string, parameters map[string]any, kcClient *keycloak.KeycloakClient) (string, error) {
+	found, err := lookup.GetComponentByID(kcClient, ctx, parameters["realm_id"].(string), id)
+	if err != nil {
+		return "", err
 	}
+	return found.Id, nil
+}
 
-	return "", nil
+// RequiredActionIdentifierFromIdentifyingProperties is used to find the
+// existing required action of a realm. Its ID is {realm}/{alias}.
+var RequiredActionIdentifierFromIdentifyingProperties = lookup.BuildCompositeKeyLookup(lookup.CompositeKeyLookupConfig{
+	RequiredParameters: []string{"realm_id", "alias"},
+	Key: func(parameters map[string]any) string {
+		return lookup.Key(lookup.Param(parameters, "realm_id"), lookup.Param(parameters, "alias"))
+	},
+	Exists: func(ctx context.Context, parameters map[string]any, kcClient *keycloak.KeycloakClient) (bool, error) {
+		var action struct {
+			Alias string `json:"alias"`
+		}
+		err := keycloaksession.AdminGet(ctx, kcClient, realmPath(parameters)+"/authentication/required-actions/"+lookup.Param(parameters, "alias"), nil, &action)
+		return exists(err)
+	},
+})
+
+// UserProfileIdentifierFromIdentifyingProperties is used to find the user
+// profile of a realm, which exists as long as the realm does. Its ID is
+// {realm}.
+var UserProfileIdentifierFromIdentifyingProperties = lookup.BuildCompositeKeyLookup(lookup.CompositeKeyLookupConfig{
+	RequiredParameters: []string{"realm_id"},
+	Key:                realmKey,
+	Exists:             lookup.RealmExists,
+	Singleton:          true,
+})
+
+// LocalizationIdentifierFromIdentifyingProperties is used to find the
+// existing texts of a locale of a realm. Its ID is {realm}/{locale}.
+var LocalizationIdentifierFromIdentifyingProperties = lookup.BuildCompositeKeyLookup(lookup.CompositeKeyLookupConfig{
+	RequiredParameters: []string{"realm_id", "locale"},
+	Key: func(parameters map[string]any) string {
+		return lookup.Key(lookup.Param(parameters, "realm_id"), lookup.Param(parameters, "locale"))
+	},
+	Exists: func(ctx context.Context, parameters map[string]any, kcClient *keycloak.KeycloakClient) (bool, error) {
+		var texts map[string]string
+		if err := keycloaksession.AdminGet(ctx, kcClient, realmPath(parameters)+"/localization/"+lookup.Param(parameters, "locale"), nil, &texts); err != nil {
+			return exists(err)
+		}
+		return len(texts) > 0, nil
+	},
+})
+
+// DefaultClientScopesIdentifierFromIdentifyingProperties is used to find the
+// existing default client scopes of a realm. Its ID is {realm}.
+var DefaultClientScopesIdentifierFromIdentifyingProperties = lookup.BuildCompositeKeyLookup(lookup.CompositeKeyLookupConfig{
+	RequiredParameters: []string{"realm_id"},
+	Key:                realmKey,
+	Exists: func(ctx context.Context, parameters map[string]any, kcClient *keycloak.KeycloakClient) (bool, error) {
+		return lookup.HasEntries(ctx, kcClient, realmPath(parameters)+"/default-default-client-scopes", nil)
+	},
+})
+
+// OptionalClientScopesIdentifierFromIdentifyingProperties is used to find the
+// existing optional client scopes of a realm. Its ID is {realm}.
+var OptionalClientScopesIdentifierFromIdentifyingProperties = lookup.BuildCompositeKeyLookup(lookup.CompositeKeyLookupConfig{
+	RequiredParameters: []string{"realm_id"},
+	Key:                realmKey,
+	Exists: func(ctx context.Context, parameters map[string]any, kcClient *keycloak.KeycloakClient) (bool, error) {
+		return lookup.HasEntries(ctx, kcClient, realmPath(parameters)+"/default-optional-client-scopes", nil)
+	},
+})
+
+// ClientPolicyProfileIdentifierFromIdentifyingProperties is used to find the
+// existing client policy profile of a realm by its name. Its ID is
+// {realm}/realm-client-policy-profiles/{name}.
+var ClientPolicyProfileIdentifierFromIdentifyingProperties = clientPoliciesIdentifierFromIdentifyingProperties("profiles", "realm-client-policy-profiles")
+
+// ClientPolicyProfilePolicyIdentifierFromIdentifyingProperties is used to find
+// the existing client policy of a realm by its name. Its ID is
+// {realm}/realm-client-policy-profile-policies/{name}.
+var ClientPolicyProfilePolicyIdentifierFromIdentifyingProperties = clientPoliciesIdentifierFromIdentifyingProperties("policies", "realm-client-policy-profile-policies")
+
+// clientPoliciesIdentifierFromIdentifyingProperties returns the lookup of the
+// client policy profiles or policies of a realm, which are listed below the
+// given property of /realms/{realm}/client-policies/{list}.
+func clientPoliciesIdentifierFromIdentifyingProperties(list, kind string) config.ExternalName {
+	return lookup.BuildCompositeKeyLookup(lookup.CompositeKeyLookupConfig{
+		RequiredParameters: []string{"realm_id", "name"},
+		Key: func(parameters map[string]any) string {
+			return lookup.Key(lookup.Param(parameters, "realm_id"), kind, lookup.Param(parameters, "name"))
+		},
+		Exists: func(ctx context.Context, parameters map[string]any, kcClient *keycloak.KeycloakClient) (bool, error) {
+			var entries map[string][]*struct {
+				Name string `json:"name"`
+			}
+			if err := keycloaksession.AdminGet(ctx, kcClient, realmPath(parameters)+"/client-policies/"+list, nil, &entries); err != nil {
+				return exists(err)
+			}
+			for _, e := range entries[list] {
+				if e.Name == lookup.Param(parameters, "name") {
+					return true, nil
+				}
+			}
+			return false, nil
+		},
+	})
+}
+
+func realmKey(parameters map[string]any) string {
+	return lookup.Param(parameters, "realm_id")
+}
+
+func realmPath(parameters map[string]any) string {
+	return "/realms/" + lookup.Param(parameters, "realm_id")
+}
+
+// exists returns whether an object exists given the error of reading it.
+func exists(err error) (bool, error) {
+	var apiErr *keycloak.ApiError
+	if errors.As(err, &apiErr) && apiErr.Code == 404 {
+		return false, nil
+	}
+	return err == nil, err
 }
@@ -2,6 +2,8 @@ package realm
 
 import (
 	"testing"
+
+	"github.com/keycloak/terraform-provider-keycloak/keycloak"
 )
 
 func TestValidateDurationString(t *testing.T) {
@@ -36,3 +38,28 @@ func TestValidateDurationString(t *testing.T) {
 		})
 	}
 }
+
+func TestExists(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		want    bool
+		wantErr bool
+	}{
+		{name: "found", err: nil, want: true},
+		{name: "not found", err: &keycloak.ApiError{Code: 404}, want: false},
+		{name: "forbidden", err: &keycloak.ApiError{Code: 403}, want: false, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := exists(tt.err)
+			if got != tt.want {
+				t.Errorf("exists(%v): want %v, got %v", tt.err, tt.want, got)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("exists(%v): unexpected error %v", tt.err, err)
+			}
+		})
+	}
+}
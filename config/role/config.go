@@ -165,6 +165,10 @@ func getIDByIdentifyingProperties(ctx context.Context, parameters map[string]any
 		clientID = ""
 	}
 
+	if id := lookup.FindRoleID(ctx, kcClient, realmID, clientID, name); id != "" {
+		return id, nil
+	}
+
 	found, err := kcClient.GetRoleByName(ctx, realmID, clientID, name)
 	if err != nil {
 		// If client_id is empty and we get a 404 error, this could mean:
@@ -43,6 +43,10 @@ func Configure(p *config.Provider) {
 				"authentication_flow_binding_overrides.direct_grant_id",
 			},
 		}
+
+		// The ownership marker the provider records in the attributes of an
+		// adopted or created client is not part of its spec.
+		r.TerraformCustomDiff = lookup.IgnoreClientOwnerDiff
 	})
 
 	p.AddResourceConfigurator("keycloak_saml_client_default_scopes", func(r *config.Resource) {
@@ -101,6 +105,7 @@ var clientIdentifyingPropertiesLookup = lookup.IdentifyingPropertiesLookupConfig
 	RequiredParameters:           []string{"realm_id", "client_id"},
 	GetIDByExternalName:          getClientIDByExternalName,
 	GetIDByIdentifyingProperties: getClientIDByIdentifyingProperties,
+	Ownership:                    lookup.ClientOwnership,
 }
 
 // ClientIdentifierFromIdentifyingProperties is used to find the existing resource by it´s identifying properties
@@ -115,6 +120,10 @@ func getClientIDByExternalName(ctx context.Context, id string, parameters map[st
 }
 
 func getClientIDByIdentifyingProperties(ctx context.Context, parameters map[string]any, kcClient *keycloak.KeycloakClient) (string, error) {
+	if id := lookup.FindClientID(ctx, kcClient, parameters["realm_id"].(string), parameters["client_id"].(string)); id != "" {
+		return id, nil
+	}
+
 	found, err := kcClient.GetGenericClientByClientId(ctx, parameters["realm_id"].(string), parameters["client_id"].(string))
 	if err != nil {
 		if strings.Contains(err.Error(), "does not exist") {
@@ -148,6 +157,10 @@ func getClientScopeIDByExternalName(ctx context.Context, id string, parameters m
 }
 
 func getClientScopeIDByIdentifyingProperties(ctx context.Context, parameters map[string]any, kcClient *keycloak.KeycloakClient) (string, error) {
+	if id := lookup.FindClientScopeID(ctx, kcClient, parameters["realm_id"].(string), parameters["name"].(string), "saml"); id != "" {
+		return id, nil
+	}
+
 	found, err := kcClient.ListSamlClientScopesWithFilter(ctx, parameters["realm_id"].(string), func(scope *keycloak.SamlClientScope) bool {
 		return scope.Name == parameters["name"].(string)
 	})
@@ -160,3 +160,73 @@ func getUserIDByIdentifyingProperties(ctx context.Context, parameters map[string
 	}
 	return found.Id, nil
 }
+
+// GroupsIdentifierFromIdentifyingProperties is used to find the existing group
+// memberships of a user. Its ID is {realm}/{user}.
+var GroupsIdentifierFromIdentifyingProperties = lookup.BuildCompositeKeyLookup(lookup.CompositeKeyLookupConfig{
+	RequiredParameters: []string{"realm_id", "user_id"},
+	Key:                userKey,
+	Exists: func(ctx context.Context, parameters map[string]any, kcClient *keycloak.KeycloakClient) (bool, error) {
+		return lookup.HasEntries(ctx, kcClient, userPath(parameters)+"/groups", map[string]string{"max": "1"})
+	},
+})
+
+// RolesIdentifierFromIdentifyingProperties is used to find the existing role
+// mappings of a user. Its ID is {realm}/{user}.
+var RolesIdentifierFromIdentifyingProperties = lookup.BuildCompositeKeyLookup(lookup.CompositeKeyLookupConfig{
+	RequiredParameters: []string{"realm_id", "user_id"},
+	Key:                userKey,
+	Exists: func(ctx context.Context, parameters map[string]any, kcClient *keycloak.KeycloakClient) (bool, error) {
+		return lookup.HasRoleMappings(ctx, kcClient, userPath(parameters)+"/role-mappings")
+	},
+})
+
+// PermissionsIdentifierFromIdentifyingProperties is used to find the existing
+// users permissions of a realm. Its ID is {realm}.
+var PermissionsIdentifierFromIdentifyingProperties = lookup.BuildCompositeKeyLookup(lookup.CompositeKeyLookupConfig{
+	RequiredParameters: []string{"realm_id"},
+	Key: func(parameters map[string]any) string {
+		return lookup.Param(parameters, "realm_id")
+	},
+	Exists: func(ctx context.Context, parameters map[string]any, kcClient *keycloak.KeycloakClient) (bool, error) {
+		return lookup.PermissionsEnabled(ctx, kcClient, "/realms/"+lookup.Param(parameters, "realm_id")+"/users-management-permissions")
+	},
+})
+
+func userKey(parameters map[string]any) string {
+	return lookup.Key(lookup.Param(parameters, "realm_id"), lookup.Param(parameters, "user_id"))
+}
+
+func userPath(parameters map[string]any) string {
+	return "/realms/" + lookup.Param(parameters, "realm_id") + "/users/" + lookup.Param(parameters, "user_id")
+}
+
+var customUserFederationIdentifyingPropertiesLookup = lookup.IdentifyingPropertiesLookupConfig{
+	RequiredParameters:           []string{"realm_id", "name", "provider_id"},
+	OptionalParameters:           []string{"parent_id"},
+	GetIDByExternalName:          getCustomUserFederationIDByExternalName,
+	GetIDByIdentifyingProperties: getCustomUserFederationIDByIdentifyingProperties,
+}
+
+// CustomUserFederationIdentifierFromIdentifyingProperties is used to find the existing resource by it´s identifying properties
+var CustomUserFederationIdentifierFromIdentifyingProperties = lookup.BuildIdentifyingPropertiesLookup(customUserFederationIdentifyingPropertiesLookup)
+
+func getCustomUserFederationIDByExternalName(ctx context.Context, id string, parameters map[string]any, kcClient *keycloak.KeycloakClient) (string, error) {
+	found, err := lookup.GetComponentByID(kcClient, ctx, lookup.Param(parameters, "realm_id"), id)
+	if err != nil {
+		return "", err
+	}
+	return found.Id, nil
+}
+
+func getCustomUserFederationIDByIdentifyingProperties(ctx context.Context, parameters map[string]any, kcClient *keycloak.KeycloakClient) (string, error) {
+	typ := "org.keycloak.storage.UserStorageProvider"
+	providerID := lookup.Param(parameters, "provider_id")
+	name := lookup.Param(parameters, "name")
+	var parent *string
+	if p := lookup.Param(parameters, "parent_id"); p != "" {
+		parent = &p
+	}
+
+	return lookup.GetComponentId(kcClient, ctx, lookup.Param(parameters, "realm_id"), &typ, parent, &providerID, &name)
+}
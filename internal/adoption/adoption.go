@@ -0,0 +1,217 @@
+/*
+Copyright 2024 Upbound Inc.
+*/
+
+// Package adoption decides whether a managed resource may adopt an existing
+// Keycloak object that matches its identifying properties, e.g. a client
+// with the same realm and clientId, instead of creating a new one.
+package adoption
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+
+	"github.com/crossplane-contrib/provider-keycloak/apis/namespaced/v1beta1"
+	"github.com/crossplane-contrib/provider-keycloak/internal/conditions"
+)
+
+const (
+	// PolicyAnnotation selects the adoption policy of a managed resource,
+	// overriding the adoptionPolicy of its ProviderConfig.
+	PolicyAnnotation = "provider-keycloak.crossplane.io/adoption-policy"
+
+	// OwnerAttribute is the attribute of a Keycloak object in which the
+	// provider records the managed resource that manages it.
+	OwnerAttribute = "provider-keycloak.crossplane.io/owner"
+
+	// TypeAdoption is the condition that records whether a managed resource
+	// adopted an existing Keycloak object.
+	TypeAdoption xpv1.ConditionType = "Adoption"
+
+	// ReasonAdopted means the managed resource adopted an existing object.
+	ReasonAdopted xpv1.ConditionReason = "Adopted"
+	// ReasonNoExistingObject means no existing object matched, so a new one
+	// is created.
+	ReasonNoExistingObject xpv1.ConditionReason = "NoExistingObject"
+	// ReasonAdoptionRefused means the adoption policy forbids adopting the
+	// existing object.
+	ReasonAdoptionRefused xpv1.ConditionReason = "AdoptionRefused"
+	// ReasonAmbiguousMatch means several existing objects match, so none of
+	// them is adopted.
+	ReasonAmbiguousMatch xpv1.ConditionReason = "AmbiguousMatch"
+
+	metadataPolicy   = "adoption_policy"
+	metadataOwner    = "adoption_owner"
+	metadataUID      = "adoption_uid"
+	metadataMayWrite = "adoption_may_write"
+
+	errFmtInvalidPolicy  = "invalid adoption policy %q in the %s annotation, must be one of Adopt, FailIfExists or AdoptIfUnmanaged"
+	errFmtFailIfExists   = "Keycloak object %s already exists, and the adoption policy FailIfExists forbids adopting it"
+	errFmtNoMarker       = "Keycloak object %s already exists, and the adoption policy AdoptIfUnmanaged forbids adopting it because objects of this kind carry no ownership marker"
+	errFmtManagedByOther = "Keycloak object %s already exists and is managed by %s, so the adoption policy AdoptIfUnmanaged forbids adopting it"
+	errFmtAmbiguousMatch = "%d Keycloak objects match the identifying properties: %s. None of them is adopted; set the crossplane.io/external-name annotation to the ID of the one to adopt"
+)
+
+// Policy returns the adoption policy of mg: the one of its annotation, if
+// set, else def, else Adopt.
+func Policy(mg resource.Managed, def v1beta1.AdoptionPolicy) (v1beta1.AdoptionPolicy, error) {
+	p, ok := mg.GetAnnotations()[PolicyAnnotation]
+	if !ok {
+		if def == "" {
+			return v1beta1.AdoptionPolicyAdopt, nil
+		}
+		return def, nil
+	}
+	switch v1beta1.AdoptionPolicy(p) {
+	case v1beta1.AdoptionPolicyAdopt, v1beta1.AdoptionPolicyFailIfExists, v1beta1.AdoptionPolicyAdoptIfUnmanaged:
+		return v1beta1.AdoptionPolicy(p), nil
+	default:
+		return "", errors.Errorf(errFmtInvalidPolicy, p, PolicyAnnotation)
+	}
+}
+
+// Owner returns the value of the ownership marker of the Keycloak objects
+// managed by the managed resource of the given kind, namespace and name.
+func Owner(gk schema.GroupKind, nn types.NamespacedName) string {
+	if nn.Namespace == "" {
+		return gk.String() + "/" + nn.Name
+	}
+	return gk.String() + "/" + nn.String()
+}
+
+// MayWrite returns whether the management policies of mg allow it to write
+// to its Keycloak object, e.g. to record itself in its ownership marker.
+func MayWrite(mg resource.Managed) bool {
+	policies := mg.GetManagementPolicies()
+	return len(policies) == 0 ||
+		slices.Contains(policies, xpv1.ManagementActionAll) ||
+		slices.Contains(policies, xpv1.ManagementActionUpdate)
+}
+
+// ClientMetadata returns the client metadata of the Terraform setup that
+// carries the adoption policy of a managed resource to the lookups of its
+// external name, which have no access to the managed resource.
+func ClientMetadata(policy v1beta1.AdoptionPolicy, owner string, uid types.UID, mayWrite bool) map[string]string {
+	return map[string]string{
+		metadataPolicy:   string(policy),
+		metadataOwner:    owner,
+		metadataUID:      string(uid),
+		metadataMayWrite: strconv.FormatBool(mayWrite),
+	}
+}
+
+// A Request is the adoption request of the managed resource whose external
+// name is looked up.
+type Request struct {
+	Policy v1beta1.AdoptionPolicy
+	Owner  string
+	UID    types.UID
+	// MayWrite is whether the management policies of the managed resource
+	// allow it to write to the Keycloak object, see MayWrite.
+	MayWrite bool
+}
+
+// RequestFromSetup returns the adoption request carried by the client
+// metadata of a Terraform setup, as passed to a GetIDFn. Without one the
+// existing object is adopted.
+func RequestFromSetup(terraformProviderConfig map[string]any) Request {
+	md, _ := terraformProviderConfig["client_metadata"].(map[string]string)
+	mayWrite, _ := strconv.ParseBool(md[metadataMayWrite])
+	r := Request{Policy: v1beta1.AdoptionPolicy(md[metadataPolicy]), Owner: md[metadataOwner], UID: types.UID(md[metadataUID]), MayWrite: mayWrite}
+	if r.Policy == "" {
+		r.Policy = v1beta1.AdoptionPolicyAdopt
+	}
+	return r
+}
+
+// OwnerFunc returns the ownership marker of an existing Keycloak object, or
+// an empty string if it has none.
+type OwnerFunc func() (string, error)
+
+// Adopt returns nil if the managed resource of r may adopt the existing
+// Keycloak object with the given ID. owner reads the ownership marker of the
+// object; it is nil if objects of its kind carry none. The outcome is
+// reported as the Adoption condition of the managed resource.
+func (r Request) Adopt(id string, owner OwnerFunc) error {
+	switch r.Policy {
+	case v1beta1.AdoptionPolicyFailIfExists:
+		return r.refuse(fmt.Sprintf(errFmtFailIfExists, id))
+	case v1beta1.AdoptionPolicyAdoptIfUnmanaged:
+		if owner == nil {
+			return r.refuse(fmt.Sprintf(errFmtNoMarker, id))
+		}
+		o, err := owner()
+		if err != nil {
+			return err
+		}
+		if o != "" && o != r.Owner {
+			return r.refuse(fmt.Sprintf(errFmtManagedByOther, id, o))
+		}
+	}
+	conditions.Report(r.UID, xpv1.Condition{
+		Type:               TypeAdoption,
+		Status:             corev1.ConditionTrue,
+		Reason:             ReasonAdopted,
+		Message:            fmt.Sprintf("Adopted the existing Keycloak object %s under the adoption policy %s", id, r.Policy),
+		LastTransitionTime: metav1.Now(),
+	})
+	return nil
+}
+
+// AdoptSingleton reports that the managed resource adopted the Keycloak
+// object with the given ID, which exists with its realm, e.g. its user
+// profile. Such an object cannot be created, so it is adopted whatever the
+// adoption policy.
+func (r Request) AdoptSingleton(id string) {
+	conditions.Report(r.UID, xpv1.Condition{
+		Type:               TypeAdoption,
+		Status:             corev1.ConditionTrue,
+		Reason:             ReasonAdopted,
+		Message:            fmt.Sprintf("Adopted the Keycloak object %s, which exists with its realm, whatever the adoption policy", id),
+		LastTransitionTime: metav1.Now(),
+	})
+}
+
+// NotFound reports that no existing Keycloak object matched, so that a new
+// one is created.
+func (r Request) NotFound() {
+	conditions.Report(r.UID, xpv1.Condition{
+		Type:               TypeAdoption,
+		Status:             corev1.ConditionFalse,
+		Reason:             ReasonNoExistingObject,
+		Message:            "No existing Keycloak object matches the identifying properties, a new one is created",
+		LastTransitionTime: metav1.Now(),
+	})
+}
+
+// Ambiguous refuses to adopt any of the existing Keycloak objects with the
+// given IDs, which all match the identifying properties, whatever the policy.
+func (r Request) Ambiguous(ids []string) error {
+	return r.refuseWithReason(ReasonAmbiguousMatch, fmt.Sprintf(errFmtAmbiguousMatch, len(ids), strings.Join(ids, ", ")))
+}
+
+func (r Request) refuse(msg string) error {
+	return r.refuseWithReason(ReasonAdoptionRefused, msg)
+}
+
+func (r Request) refuseWithReason(reason xpv1.ConditionReason, msg string) error {
+	conditions.Report(r.UID, xpv1.Condition{
+		Type:               TypeAdoption,
+		Status:             corev1.ConditionFalse,
+		Reason:             reason,
+		Message:            msg,
+		LastTransitionTime: metav1.Now(),
+	})
+	return conditions.WithReason(reason, errors.New(msg))
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2024 Upbound Inc.
+*/
+
+package adoption
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/test"
+
+	"github.com/crossplane-contrib/provider-keycloak/apis/namespaced/v1beta1"
+	"github.com/crossplane-contrib/provider-keycloak/internal/conditions"
+)
+
+func TestPolicy(t *testing.T) {
+	cases := map[string]struct {
+		annotation *string
+		def        v1beta1.AdoptionPolicy
+		want       v1beta1.AdoptionPolicy
+		wantErr    bool
+	}{
+		"DefaultsToAdopt":          {want: v1beta1.AdoptionPolicyAdopt},
+		"ProviderConfigDefault":    {def: v1beta1.AdoptionPolicyFailIfExists, want: v1beta1.AdoptionPolicyFailIfExists},
+		"AnnotationOverrides":      {annotation: ptr("AdoptIfUnmanaged"), def: v1beta1.AdoptionPolicyFailIfExists, want: v1beta1.AdoptionPolicyAdoptIfUnmanaged},
+		"InvalidAnnotationRefused": {annotation: ptr("adopt"), wantErr: true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			mg := &fake.Managed{}
+			if tc.annotation != nil {
+				mg.SetAnnotations(map[string]string{PolicyAnnotation: *tc.annotation})
+			}
+			got, err := Policy(mg, tc.def)
+			if (err != nil) != tc.wantErr || got != tc.want {
+				t.Errorf("Policy() = %q, %v, want %q, error %v", got, err, tc.want, tc.wantErr)
+			}
+		})
+	}
+}
+
+func ptr(s string) *string { return &s }
+
+func TestAdopt(t *testing.T) {
+	const self = "Client.openidclient.keycloak.m.crossplane.io/team-a/app"
+	owner := func(o string) OwnerFunc { return func() (string, error) { return o, nil } }
+
+	cases := map[string]struct {
+		policy     v1beta1.AdoptionPolicy
+		owner      OwnerFunc
+		wantReason xpv1.ConditionReason
+		wantErr    string
+	}{
+		"Adopt":                    {policy: v1beta1.AdoptionPolicyAdopt, owner: owner("someone-else"), wantReason: ReasonAdopted},
+		"FailIfExists":             {policy: v1beta1.AdoptionPolicyFailIfExists, owner: owner(""), wantReason: ReasonAdoptionRefused, wantErr: "FailIfExists forbids"},
+		"AdoptIfUnmanagedNoOwner":  {policy: v1beta1.AdoptionPolicyAdoptIfUnmanaged, owner: owner(""), wantReason: ReasonAdopted},
+		"AdoptIfUnmanagedSelf":     {policy: v1beta1.AdoptionPolicyAdoptIfUnmanaged, owner: owner(self), wantReason: ReasonAdopted},
+		"AdoptIfUnmanagedOther":    {policy: v1beta1.AdoptionPolicyAdoptIfUnmanaged, owner: owner("Client.openidclient.keycloak.m.crossplane.io/prod/app"), wantReason: ReasonAdoptionRefused, wantErr: "is managed by Client.openidclient.keycloak.m.crossplane.io/prod/app"},
+		"AdoptIfUnmanagedNoMarker": {policy: v1beta1.AdoptionPolicyAdoptIfUnmanaged, wantReason: ReasonAdoptionRefused, wantErr: "carry no ownership marker"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			uid := types.UID("uid-" + name)
+			r := RequestFromSetup(map[string]any{"client_metadata": ClientMetadata(tc.policy, self, uid, true)})
+			err := r.Adopt("c1", tc.owner)
+			if tc.wantErr == "" && err != nil || tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+				t.Errorf("Adopt() = %v, want error containing %q", err, tc.wantErr)
+			}
+			if got := writtenCondition(t, uid); got.Reason != tc.wantReason {
+				t.Errorf("Adoption reason = %q, want %q", got.Reason, tc.wantReason)
+			}
+		})
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	r := RequestFromSetup(map[string]any{"client_metadata": ClientMetadata(v1beta1.AdoptionPolicyFailIfExists, "owner", "uid-not-found", true)})
+	r.NotFound()
+	if got := writtenCondition(t, "uid-not-found"); got.Reason != ReasonNoExistingObject || got.Status != corev1.ConditionFalse {
+		t.Errorf("Adoption = %+v, want reason %q", got, ReasonNoExistingObject)
+	}
+}
+
+func TestAmbiguous(t *testing.T) {
+	r := RequestFromSetup(map[string]any{"client_metadata": ClientMetadata(v1beta1.AdoptionPolicyAdopt, "owner", "uid-ambiguous", true)})
+	err := r.Ambiguous([]string{"g1", "g2"})
+	if err == nil || !strings.Contains(err.Error(), "2 Keycloak objects match the identifying properties: g1, g2") {
+		t.Errorf("Ambiguous() = %v, want an error listing the candidates", err)
+	}
+	if err != nil && !strings.HasPrefix(err.Error(), string(ReasonAmbiguousMatch)+": ") {
+		t.Errorf("Ambiguous() = %v, want the reason %q for the Synced condition", err, ReasonAmbiguousMatch)
+	}
+	if got := writtenCondition(t, "uid-ambiguous"); got.Reason != ReasonAmbiguousMatch || got.Status != corev1.ConditionFalse {
+		t.Errorf("Adoption = %+v, want reason %q", got, ReasonAmbiguousMatch)
+	}
+}
+
+func TestMayWrite(t *testing.T) {
+	cases := map[string]struct {
+		policies xpv1.ManagementPolicies
+		want     bool
+	}{
+		"Default":     {want: true},
+		"All":         {policies: xpv1.ManagementPolicies{xpv1.ManagementActionAll}, want: true},
+		"ObserveOnly": {policies: xpv1.ManagementPolicies{xpv1.ManagementActionObserve}},
+		"NoUpdate":    {policies: xpv1.ManagementPolicies{xpv1.ManagementActionObserve, xpv1.ManagementActionCreate, xpv1.ManagementActionDelete}},
+		"Update":      {policies: xpv1.ManagementPolicies{xpv1.ManagementActionObserve, xpv1.ManagementActionUpdate}, want: true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			mg := &fake.Managed{}
+			mg.SetManagementPolicies(tc.policies)
+			if got := MayWrite(mg); got != tc.want {
+				t.Errorf("MayWrite() = %v, want %v", got, tc.want)
+			}
+			md := ClientMetadata(v1beta1.AdoptionPolicyAdopt, "owner", "uid", tc.want)
+			if got := RequestFromSetup(map[string]any{"client_metadata": md}).MayWrite; got != tc.want {
+				t.Errorf("RequestFromSetup().MayWrite = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRequestFromSetupWithoutMetadata(t *testing.T) {
+	if got := RequestFromSetup(map[string]any{}); got.Policy != v1beta1.AdoptionPolicyAdopt {
+		t.Errorf("Policy = %q, want existing objects to be adopted without client metadata", got.Policy)
+	}
+}
+
+func TestOwner(t *testing.T) {
+	gk := schema.GroupKind{Group: "openidclient.keycloak.crossplane.io", Kind: "Client"}
+	if got, want := Owner(gk, types.NamespacedName{Name: "app"}), "Client.openidclient.keycloak.crossplane.io/app"; got != want {
+		t.Errorf("Owner() = %q, want %q", got, want)
+	}
+}
+
+// writtenCondition returns the Adoption condition written with the status of
+// the managed resource with the given UID.
+func writtenCondition(t *testing.T, uid types.UID) xpv1.Condition {
+	t.Helper()
+	kube := conditions.WrapClient(&test.MockClient{MockStatusUpdate: test.NewMockSubResourceUpdateFn(nil)})
+	mg := &fake.Managed{}
+	mg.SetUID(uid)
+	if err := kube.Status().Update(context.Background(), mg); err != nil {
+		t.Fatal(errors.Wrap(err, "cannot update status"))
+	}
+	return mg.GetCondition(TypeAdoption)
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2021 Upbound Inc.
+*/
+
+package clients
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-version"
+	"github.com/keycloak/terraform-provider-keycloak/keycloak"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+
+	"github.com/crossplane-contrib/provider-keycloak/internal/conditions"
+	"github.com/crossplane-contrib/provider-keycloak/internal/tfconcurrency"
+)
+
+// ReasonUnsupported is the reason of the Synced condition of a managed
+// resource whose kind the Keycloak server does not support.
+const ReasonUnsupported xpv1.ConditionReason = "Unsupported"
+
+const (
+	errFmtUnsupportedVersion = "%s requires Keycloak %s or later, the server runs %s"
+	errFmtFeatureDisabled    = "%s requires the Keycloak server feature %q, which is disabled"
+)
+
+// requirement is what a Terraform resource needs from the Keycloak server.
+type requirement struct {
+	// minVersion is the first Keycloak version that supports the resource.
+	minVersion *version.Version
+	// feature is the server feature the resource needs, as listed in the
+	// profile of the server info.
+	feature string
+}
+
+// requirements of the Terraform resources that only work on recent Keycloak
+// versions or with specific server features enabled.
+var requirements = map[string]requirement{
+	"keycloak_organization":                       {minVersion: version.Must(version.NewVersion("26.0.0")), feature: "organization"},
+	"keycloak_workflow":                           {minVersion: version.Must(version.NewVersion("26.4.0")), feature: "workflows"},
+	"keycloak_spiffe_identity_provider":           {minVersion: version.Must(version.NewVersion("26.3.0")), feature: "spiffe"},
+	"keycloak_realm_client_policy_profile":        {minVersion: version.Must(version.NewVersion("15.0.0")), feature: "client-policies"},
+	"keycloak_realm_client_policy_profile_policy": {minVersion: version.Must(version.NewVersion("15.0.0")), feature: "client-policies"},
+}
+
+// Backoff of the retries of a failed read of the server capabilities.
+const (
+	capabilitiesInitialBackoff = 10 * time.Second
+	capabilitiesMaxBackoff     = 10 * time.Minute
+)
+
+// capabilities of a Keycloak server.
+type capabilities struct {
+	// version is nil if the server version cannot be compared with Keycloak
+	// versions, e.g. for Red Hat SSO.
+	version  *version.Version
+	disabled map[string]bool
+}
+
+// serverCapabilities are the capabilities of the server of a configuration.
+// They are read once, when the client pool of the configuration is created.
+// A failed read is retried by a later check, with exponential backoff, and
+// no resource is refused until the capabilities are known.
+type serverCapabilities struct {
+	read func(context.Context) (*capabilities, error)
+
+	mu       sync.Mutex
+	caps     *capabilities
+	reading  bool
+	backoff  time.Duration
+	nextRead time.Time
+}
+
+// newServerCapabilities returns the capabilities of the server of the given
+// pool, whose clients are configured with config.
+func newServerCapabilities(pool *tfconcurrency.Pool, config map[string]any) *serverCapabilities {
+	return &serverCapabilities{read: func(ctx context.Context) (*capabilities, error) {
+		c, err := pool.Borrow(ctx)
+		if err != nil {
+			return nil, err
+		}
+		caps, err := readCapabilities(ctx, c, config)
+		pool.Release(c, err)
+		return caps, err
+	}}
+}
+
+// check reads the capabilities if they are not known yet and a read is due,
+// and returns an error if the server lacks the version or a feature the kind
+// of the managed resource needs. Only one check reads at a time; concurrent
+// ones refuse no resource.
+func (s *serverCapabilities) check(ctx context.Context, mg resource.Managed) error {
+	s.mu.Lock()
+	caps := s.caps
+	due := caps == nil && !s.reading && !time.Now().Before(s.nextRead)
+	if due {
+		s.reading = true
+	}
+	s.mu.Unlock()
+	if due {
+		read, err := s.read(ctx)
+		s.mu.Lock()
+		s.reading = false
+		if err != nil {
+			s.backoff = min(max(2*s.backoff, capabilitiesInitialBackoff), capabilitiesMaxBackoff)
+			s.nextRead = time.Now().Add(s.backoff)
+		} else {
+			s.caps, s.backoff = read, 0
+		}
+		caps = s.caps
+		s.mu.Unlock()
+	}
+	return caps.check(mg)
+}
+
+// readCapabilities reads the capabilities of the server a client is connected
+// to.
+func readCapabilities(ctx context.Context, c *keycloak.KeycloakClient, config map[string]any) (*capabilities, error) {
+	info, err := c.GetServerInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	caps := &capabilities{disabled: make(map[string]bool, len(info.ProfileInfo.DisabledFeatures))}
+	if redHatSSO, _ := config["red_hat_sso"].(bool); !redHatSSO {
+		if v, err := version.NewVersion(info.SystemInfo.ServerVersion); err == nil {
+			caps.version = v.Core()
+		}
+	}
+	for _, f := range info.ProfileInfo.DisabledFeatures {
+		caps.disabled[normalizeFeature(f)] = true
+	}
+	return caps, nil
+}
+
+// normalizeFeature maps the feature names of the different Keycloak versions,
+// e.g. CLIENT_POLICIES and client-policies, to the same name.
+func normalizeFeature(f string) string {
+	return strings.ToLower(strings.ReplaceAll(f, "_", "-"))
+}
+
+// check returns an error if the server lacks the version or a feature the
+// kind of the managed resource needs.
+func (c *capabilities) check(mg resource.Managed) error {
+	tr, ok := mg.(interface{ GetTerraformResourceType() string })
+	if c == nil || !ok {
+		return nil
+	}
+	rt := tr.GetTerraformResourceType()
+	req, ok := requirements[rt]
+	if !ok {
+		return nil
+	}
+	if c.version != nil && c.version.LessThan(req.minVersion) {
+		return conditions.WithReason(ReasonUnsupported, errors.Errorf(errFmtUnsupportedVersion, rt, req.minVersion, c.version))
+	}
+	if c.disabled[req.feature] {
+		return conditions.WithReason(ReasonUnsupported, errors.Errorf(errFmtFeatureDisabled, rt, req.feature))
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource/fake"
+	"github.com/hashicorp/go-version"
+)
+
+type fakeTerraformed struct {
+	fake.Managed
+	resourceType string
+}
+
+func (f *fakeTerraformed) GetTerraformResourceType() string {
+	return f.resourceType
+}
+
+func TestCapabilitiesCheck(t *testing.T) {
+	v25 := version.Must(version.NewVersion("25.0.6"))
+	v26 := version.Must(version.NewVersion("26.4.1"))
+
+	cases := map[string]struct {
+		caps         *capabilities
+		resourceType string
+		wantErr      string
+	}{
+		"Unknown":            {resourceType: "keycloak_organization"},
+		"NoRequirement":      {caps: &capabilities{version: v25}, resourceType: "keycloak_realm"},
+		"Supported":          {caps: &capabilities{version: v26, disabled: map[string]bool{}}, resourceType: "keycloak_organization"},
+		"TooOld":             {caps: &capabilities{version: v25}, resourceType: "keycloak_organization", wantErr: "requires Keycloak 26.0.0 or later"},
+		"FeatureDisabled":    {caps: &capabilities{version: v26, disabled: map[string]bool{"workflows": true}}, resourceType: "keycloak_workflow", wantErr: `feature "workflows"`},
+		"UncomparableServer": {caps: &capabilities{disabled: map[string]bool{}}, resourceType: "keycloak_spiffe_identity_provider"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.caps.check(&fakeTerraformed{resourceType: tc.resourceType})
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("error: got %v, want it to contain %q", err, tc.wantErr)
+			}
+			if !strings.HasPrefix(err.Error(), string(ReasonUnsupported)+": ") {
+				t.Errorf("expected the error to carry reason %s, got %q", ReasonUnsupported, err)
+			}
+		})
+	}
+}
+
+func TestNormalizeFeature(t *testing.T) {
+	if got := normalizeFeature("CLIENT_POLICIES"); got != "client-policies" {
+		t.Errorf("normalizeFeature() = %q", got)
+	}
+}
+
+func TestServerCapabilitiesRetry(t *testing.T) {
+	v25 := version.Must(version.NewVersion("25.0.6"))
+	reads := 0
+	var readErr error
+	s := &serverCapabilities{read: func(context.Context) (*capabilities, error) {
+		reads++
+		if readErr != nil {
+			return nil, readErr
+		}
+		return &capabilities{version: v25}, nil
+	}}
+	org := &fakeTerraformed{resourceType: "keycloak_organization"}
+	ctx := context.Background()
+
+	// A failed read refuses nothing and is not retried before the backoff.
+	readErr = errors.New("503 Service Unavailable")
+	for i := 0; i < 2; i++ {
+		if err := s.check(ctx, org); err != nil {
+			t.Fatalf("check() after a failed read = %v, want nil", err)
+		}
+	}
+	if reads != 1 {
+		t.Fatalf("reads = %d, want 1 within the backoff", reads)
+	}
+	if s.backoff != capabilitiesInitialBackoff {
+		t.Errorf("backoff = %v, want %v", s.backoff, capabilitiesInitialBackoff)
+	}
+
+	// Once the backoff has passed the read is retried, and its result kept.
+	readErr = nil
+	s.nextRead = time.Time{}
+	for i := 0; i < 2; i++ {
+		if err := s.check(ctx, org); err == nil {
+			t.Fatalf("check() after a successful read = nil, want %s refused", org.resourceType)
+		}
+	}
+	if reads != 2 {
+		t.Errorf("reads = %d, want 2", reads)
+	}
+}
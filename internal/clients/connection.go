@@ -0,0 +1,190 @@
+/*
+Copyright 2021 Upbound Inc.
+*/
+
+package clients
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	namespacedv1beta1 "github.com/crossplane-contrib/provider-keycloak/apis/namespaced/v1beta1"
+)
+
+const (
+	errGetConnectionSecret = "cannot get connection secret"
+	errFmtNoSecretKey      = "secret %s/%s has no key %s"
+	errGetCAConfigMap      = "cannot get root CA config map"
+	errFmtNoConfigMapKey   = "config map %s/%s has no key %s"
+
+	// defaultCAConfigMapKey is the key cert-manager's trust-manager and the
+	// kube-root-ca.crt ConfigMaps hold their CA bundle in.
+	defaultCAConfigMapKey = "ca.crt"
+)
+
+// applyConnectionConfig merges the typed fields of a ProviderConfig spec into
+// the settings read from its credentials. A typed field that is set always
+// wins over the matching key of the credentials.
+func applyConnectionConfig(ctx context.Context, kube client.Client, pcSpec *namespacedv1beta1.ClusterProviderConfigSpec, creds map[string]any) error {
+	c := pcSpec.ConnectionConfig
+	for key, v := range map[string]*string{
+		"url":                    c.URL,
+		"base_path":              c.BasePath,
+		"admin_url":              c.AdminURL,
+		"realm":                  c.Realm,
+		"client_id":              c.ClientID,
+		"username":               c.Username,
+		"root_ca_certificate":    c.RootCACertificate,
+		"tls_client_certificate": c.TLSClientCertificate,
+		"jwt_signing_alg":        c.JWTSigningAlg,
+		"jwt_token_file":         c.JWTTokenFile,
+	} {
+		if v != nil {
+			creds[key] = *v
+		}
+	}
+	for key, v := range map[string]*bool{
+		"initial_login":            c.InitialLogin,
+		"tls_insecure_skip_verify": c.TLSInsecureSkipVerify,
+		"red_hat_sso":              c.RedHatSSO,
+	} {
+		if v != nil {
+			creds[key] = *v
+		}
+	}
+	if c.ClientTimeout != nil {
+		creds["client_timeout"] = *c.ClientTimeout
+	}
+	if len(c.FailoverURLs) > 0 {
+		urls := make([]any, len(c.FailoverURLs))
+		for i, u := range c.FailoverURLs {
+			urls[i] = u
+		}
+		creds["failover_urls"] = urls
+	}
+	if len(c.AdditionalHeaders) > 0 {
+		headers := make(map[string]any, len(c.AdditionalHeaders))
+		for k, v := range c.AdditionalHeaders {
+			headers[k] = v
+		}
+		creds["additional_headers"] = headers
+	}
+
+	for key, sel := range connectionSecretRefs(pcSpec) {
+		v, err := secretKeyValue(ctx, kube, sel)
+		if err != nil {
+			return err
+		}
+		creds[key] = v
+	}
+	if ref := pcSpec.RootCAConfigMapRef; ref != nil {
+		v, err := configMapKeyValue(ctx, kube, ref)
+		if err != nil {
+			return err
+		}
+		creds["root_ca_certificate"] = v
+	}
+	return nil
+}
+
+// connectionSecretRefs returns the Secret keys referenced by the typed fields
+// of a ProviderConfig spec, keyed by the configuration key they provide.
+func connectionSecretRefs(pcSpec *namespacedv1beta1.ClusterProviderConfigSpec) map[string]*xpv1.SecretKeySelector {
+	refs := make(map[string]*xpv1.SecretKeySelector, 4)
+	for key, sel := range map[string]*xpv1.SecretKeySelector{
+		"password":               pcSpec.PasswordSecretRef,
+		"client_secret":          pcSpec.ClientSecretSecretRef,
+		"tls_client_private_key": pcSpec.TLSClientPrivateKeySecretRef,
+		"jwt_signing_key":        pcSpec.JWTSigningKeySecretRef,
+	} {
+		if sel != nil {
+			refs[key] = sel
+		}
+	}
+	// The certificate and key of a TLS Secret win over the individual fields,
+	// so that a renewed certificate is never paired with a stale key.
+	if ref := pcSpec.TLSSecretRef; ref != nil {
+		refs["tls_client_certificate"] = &xpv1.SecretKeySelector{SecretReference: *ref, Key: corev1.TLSCertKey}
+		refs["tls_client_private_key"] = &xpv1.SecretKeySelector{SecretReference: *ref, Key: corev1.TLSPrivateKeyKey}
+	}
+	return refs
+}
+
+func secretKeyValue(ctx context.Context, kube client.Client, sel *xpv1.SecretKeySelector) (string, error) {
+	s := &corev1.Secret{}
+	if err := kube.Get(ctx, types.NamespacedName{Namespace: sel.Namespace, Name: sel.Name}, s); err != nil {
+		return "", errors.Wrap(err, errGetConnectionSecret)
+	}
+	v, ok := s.Data[sel.Key]
+	if !ok {
+		return "", errors.Errorf(errFmtNoSecretKey, sel.Namespace, sel.Name, sel.Key)
+	}
+	return string(v), nil
+}
+
+func configMapKeyValue(ctx context.Context, kube client.Client, sel *namespacedv1beta1.ConfigMapKeySelector) (string, error) {
+	key := sel.Key
+	if key == "" {
+		key = defaultCAConfigMapKey
+	}
+	cm := &corev1.ConfigMap{}
+	if err := kube.Get(ctx, types.NamespacedName{Namespace: sel.Namespace, Name: sel.Name}, cm); err != nil {
+		return "", errors.Wrap(err, errGetCAConfigMap)
+	}
+	v, ok := cm.Data[key]
+	if !ok {
+		return "", errors.Errorf(errFmtNoConfigMapKey, sel.Namespace, sel.Name, key)
+	}
+	return v, nil
+}
+
+// localCredentials returns the credentials of a namespaced ProviderConfig: its
+// credentials Secret in the ProviderConfig's namespace, or none, in which case
+// it is configured from its typed fields only.
+func localCredentials(ref *namespacedv1beta1.ProviderCredentials, namespace string) namespacedv1beta1.ClusterProviderCredentials {
+	if ref == nil {
+		return namespacedv1beta1.ClusterProviderCredentials{Source: xpv1.CredentialsSourceNone}
+	}
+	return namespacedv1beta1.ClusterProviderCredentials{
+		Source: xpv1.CredentialsSourceSecret,
+		CommonCredentialSelectors: xpv1.CommonCredentialSelectors{
+			SecretRef: localSecretKeySelector(&ref.LocalSecretKeySelector, namespace),
+		},
+	}
+}
+
+// localSecretKeySelector resolves a Secret key of a namespaced ProviderConfig
+// in the ProviderConfig's namespace.
+func localSecretKeySelector(sel *xpv1.LocalSecretKeySelector, namespace string) *xpv1.SecretKeySelector {
+	if sel == nil {
+		return nil
+	}
+	return &xpv1.SecretKeySelector{
+		SecretReference: xpv1.SecretReference{Name: sel.Name, Namespace: namespace},
+		Key:             sel.Key,
+	}
+}
+
+// localSecretReference resolves a Secret of a namespaced ProviderConfig in the
+// ProviderConfig's namespace.
+func localSecretReference(ref *xpv1.LocalSecretReference, namespace string) *xpv1.SecretReference {
+	if ref == nil {
+		return nil
+	}
+	return &xpv1.SecretReference{Name: ref.Name, Namespace: namespace}
+}
+
+// localConfigMapKeySelector resolves a ConfigMap key of a namespaced
+// ProviderConfig in the ProviderConfig's namespace.
+func localConfigMapKeySelector(sel *namespacedv1beta1.LocalConfigMapKeySelector, namespace string) *namespacedv1beta1.ConfigMapKeySelector {
+	if sel == nil {
+		return nil
+	}
+	return &namespacedv1beta1.ConfigMapKeySelector{Name: sel.Name, Namespace: namespace, Key: sel.Key}
+}
@@ -0,0 +1,208 @@
+package clients
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	v1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	namespacedv1beta1 "github.com/crossplane-contrib/provider-keycloak/apis/namespaced/v1beta1"
+)
+
+func TestProviderConfigurationTypedFields(t *testing.T) {
+	kube := fake.NewClientBuilder().
+		WithObjects(
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "blob", Namespace: "team-a"},
+				Data: map[string][]byte{
+					"credentials": []byte(`{"client_id":"admin-cli","url":"https://old.example.com","realm":"master","password":"old"}`),
+				},
+			},
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "admin", Namespace: "team-a"},
+				Data:       map[string][]byte{"password": []byte("n3w")},
+			},
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "client-tls", Namespace: "team-a"},
+				Type:       corev1.SecretTypeTLS,
+				Data:       map[string][]byte{corev1.TLSCertKey: []byte("renewed-cert"), corev1.TLSPrivateKeyKey: []byte("renewed-key")},
+			},
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "trust", Namespace: "team-a"},
+				Data:       map[string]string{"ca.crt": "ca-bundle"},
+			},
+		).
+		Build()
+
+	tests := []struct {
+		name    string
+		pc      *namespacedv1beta1.ProviderConfig
+		want    map[string]any
+		wantErr bool
+	}{
+		{
+			name: "typed fields win over the credentials blob",
+			pc: &namespacedv1beta1.ProviderConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "pc", Namespace: "team-a"},
+				Spec: namespacedv1beta1.ProviderConfigSpec{
+					CredentialsSecretRef: &namespacedv1beta1.ProviderCredentials{
+						LocalSecretKeySelector: v1.LocalSecretKeySelector{
+							LocalSecretReference: v1.LocalSecretReference{Name: "blob"},
+							Key:                  "credentials",
+						},
+					},
+					ConnectionConfig: namespacedv1beta1.ConnectionConfig{
+						URL:               ptrTo("https://keycloak.example.com/"),
+						Username:          ptrTo("admin"),
+						ClientTimeout:     ptrTo(15),
+						AdditionalHeaders: map[string]string{"X-Tenant": "a"},
+					},
+					PasswordSecretRef: &v1.LocalSecretKeySelector{
+						LocalSecretReference: v1.LocalSecretReference{Name: "admin"},
+						Key:                  "password",
+					},
+				},
+			},
+			want: map[string]any{
+				"client_id":          "admin-cli",
+				"url":                "https://keycloak.example.com",
+				"realm":              "master",
+				"username":           "admin",
+				"password":           "n3w",
+				"client_timeout":     15,
+				"additional_headers": map[string]any{"X-Tenant": "a"},
+			},
+		},
+		{
+			name: "typed fields only, without a credentials secret",
+			pc: &namespacedv1beta1.ProviderConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "pc", Namespace: "team-a"},
+				Spec: namespacedv1beta1.ProviderConfigSpec{
+					ConnectionConfig: namespacedv1beta1.ConnectionConfig{
+						URL:      ptrTo("https://keycloak.example.com"),
+						ClientID: ptrTo("crossplane"),
+						Username: ptrTo("admin"),
+					},
+					PasswordSecretRef: &v1.LocalSecretKeySelector{
+						LocalSecretReference: v1.LocalSecretReference{Name: "admin"},
+						Key:                  "password",
+					},
+				},
+			},
+			want: map[string]any{
+				"client_id": "crossplane",
+				"url":       "https://keycloak.example.com",
+				"username":  "admin",
+				"password":  "n3w",
+			},
+		},
+		{
+			name: "missing key of a typed secret reference",
+			pc: &namespacedv1beta1.ProviderConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "pc", Namespace: "team-a"},
+				Spec: namespacedv1beta1.ProviderConfigSpec{
+					CredentialsSecretRef: &namespacedv1beta1.ProviderCredentials{
+						LocalSecretKeySelector: v1.LocalSecretKeySelector{
+							LocalSecretReference: v1.LocalSecretReference{Name: "blob"},
+							Key:                  "credentials",
+						},
+					},
+					ClientSecretSecretRef: &v1.LocalSecretKeySelector{
+						LocalSecretReference: v1.LocalSecretReference{Name: "admin"},
+						Key:                  "client_secret",
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "TLS secret and CA config map win over the inline certificates",
+			pc: &namespacedv1beta1.ProviderConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "pc", Namespace: "team-a"},
+				Spec: namespacedv1beta1.ProviderConfigSpec{
+					CredentialsSecretRef: &namespacedv1beta1.ProviderCredentials{
+						LocalSecretKeySelector: v1.LocalSecretKeySelector{
+							LocalSecretReference: v1.LocalSecretReference{Name: "blob"},
+							Key:                  "credentials",
+						},
+					},
+					ConnectionConfig: namespacedv1beta1.ConnectionConfig{
+						RootCACertificate:    ptrTo("inline-ca"),
+						TLSClientCertificate: ptrTo("inline-cert"),
+					},
+					TLSSecretRef:       &v1.LocalSecretReference{Name: "client-tls"},
+					RootCAConfigMapRef: &namespacedv1beta1.LocalConfigMapKeySelector{Name: "trust"},
+				},
+			},
+			want: map[string]any{
+				"client_id":              "admin-cli",
+				"url":                    "https://old.example.com",
+				"realm":                  "master",
+				"password":               "old",
+				"root_ca_certificate":    "ca-bundle",
+				"tls_client_certificate": "renewed-cert",
+				"tls_client_private_key": "renewed-key",
+			},
+		},
+		{
+			name: "missing key of the CA config map",
+			pc: &namespacedv1beta1.ProviderConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "pc", Namespace: "team-a"},
+				Spec: namespacedv1beta1.ProviderConfigSpec{
+					CredentialsSecretRef: &namespacedv1beta1.ProviderCredentials{
+						LocalSecretKeySelector: v1.LocalSecretKeySelector{
+							LocalSecretReference: v1.LocalSecretReference{Name: "blob"},
+							Key:                  "credentials",
+						},
+					},
+					RootCAConfigMapRef: &namespacedv1beta1.LocalConfigMapKeySelector{Name: "trust", Key: "bundle.pem"},
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := ProviderConfigSpec(tt.pc)
+			if err != nil {
+				t.Fatalf("ProviderConfigSpec() error = %v", err)
+			}
+			got, err := providerConfiguration(context.Background(), kube, spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("providerConfiguration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("providerConfiguration() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProviderConfigurationTypedOnly(t *testing.T) {
+	spec := &namespacedv1beta1.ClusterProviderConfigSpec{
+		Credentials: namespacedv1beta1.ClusterProviderCredentials{Source: v1.CredentialsSourceNone},
+		ConnectionConfig: namespacedv1beta1.ConnectionConfig{
+			URL:      ptrTo("https://keycloak.example.com"),
+			ClientID: ptrTo("crossplane"),
+		},
+		// The allowed realms do not take part in the configuration, so that
+		// configs that only differ in them share their clients.
+		AllowedRealms: []string{"team-a"},
+	}
+	got, err := providerConfiguration(context.Background(), fake.NewClientBuilder().Build(), spec)
+	if err != nil {
+		t.Fatalf("providerConfiguration() error = %v", err)
+	}
+	want := map[string]any{"client_id": "crossplane", "url": "https://keycloak.example.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("providerConfiguration() got = %v, want %v", got, want)
+	}
+}
+
+func ptrTo[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,248 @@
+/*
+Copyright 2021 Upbound Inc.
+*/
+
+package clients
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/keycloak/terraform-provider-keycloak/keycloak"
+	"github.com/prometheus/client_golang/prometheus"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+
+	clusterv1beta1 "github.com/crossplane-contrib/provider-keycloak/apis/cluster/v1beta1"
+	namespacedv1beta1 "github.com/crossplane-contrib/provider-keycloak/apis/namespaced/v1beta1"
+	"github.com/crossplane-contrib/provider-keycloak/internal/tfconcurrency"
+)
+
+// Caches and reasons of the keycloak_client_cache_evictions_total metric.
+const (
+	cacheProvider = "provider"
+	cachePool     = "pool"
+
+	reasonTTL      = "ttl"
+	reasonIdle     = "idle"
+	reasonOrphaned = "orphaned"
+	reasonRotated  = "rotated"
+	reasonRefresh  = "refresh_failed"
+)
+
+var evictions = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "keycloak",
+	Subsystem: "client_cache",
+	Name:      "evictions_total",
+	Help:      "Number of cached Keycloak clients that were logged out and evicted, by cache and reason.",
+}, []string{"cache", "reason"})
+
+var providerConfigInfoDesc = prometheus.NewDesc("keycloak_provider_config_info",
+	"Set to 1 for every ProviderConfig that resolves to a cached configuration. Joins the config label of the keycloak_pool_* and keycloak_rate_limit_* metrics to ProviderConfigs.",
+	[]string{"config", "kind", "namespace", "name"}, nil)
+
+// providerConfigCollector reports which ProviderConfigs use every cached
+// configuration when scraped. ProviderConfigs that resolve to the same
+// configuration share a pool, so the pool metrics are labelled by
+// configuration rather than by ProviderConfig.
+type providerConfigCollector struct{}
+
+func (providerConfigCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- providerConfigInfoDesc
+}
+
+func (providerConfigCollector) Collect(ch chan<- prometheus.Metric) {
+	metaCache.Range(func(key, value any) bool {
+		label := tfconcurrency.ConfigLabel(key.(string))
+		for _, ref := range value.(*cachedMeta).usage.providerConfigs() {
+			ch <- prometheus.MustNewConstMetric(providerConfigInfoDesc, prometheus.GaugeValue, 1,
+				label, ref.gvk.Kind, ref.nn.Namespace, ref.nn.Name)
+		}
+		return true
+	})
+}
+
+// EvictionOptions configure which cached Keycloak clients EvictStale evicts.
+type EvictionOptions struct {
+	// TTL is the maximum age of a cached configuration. Zero disables it.
+	TTL time.Duration
+
+	// IdleTimeout evicts cached configurations that were not used for this
+	// long, and shrinks pools whose clients were idle for this long. Zero
+	// disables it.
+	IdleTimeout time.Duration
+}
+
+// providerConfigRef identifies a ProviderConfig of any of the provider's
+// ProviderConfig kinds.
+type providerConfigRef struct {
+	gvk schema.GroupVersionKind
+	nn  types.NamespacedName
+}
+
+// pcCacheKeys records the cache key each ProviderConfig last resolved to, so
+// that a ProviderConfig whose configuration changed stops holding on to the
+// entry of its previous configuration.
+var pcCacheKeys sync.Map // map[providerConfigRef]string
+
+// entryUsage records when a cache entry was created and last used, and which
+// ProviderConfigs resolve to it.
+type entryUsage struct {
+	created time.Time
+	// lastUsed is in Unix nanoseconds.
+	lastUsed atomic.Int64
+
+	mu  sync.Mutex
+	pcs map[providerConfigRef]struct{}
+}
+
+func (u *entryUsage) add(ref providerConfigRef) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.pcs == nil {
+		u.pcs = make(map[providerConfigRef]struct{})
+	}
+	u.pcs[ref] = struct{}{}
+}
+
+func (u *entryUsage) remove(ref providerConfigRef) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	delete(u.pcs, ref)
+}
+
+func (u *entryUsage) providerConfigs() []providerConfigRef {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	refs := make([]providerConfigRef, 0, len(u.pcs))
+	for ref := range u.pcs {
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// providerConfigRefOf returns the ProviderConfig a managed resource
+// references.
+func providerConfigRefOf(mg resource.Managed) (providerConfigRef, bool) {
+	switch managed := mg.(type) {
+	case resource.LegacyManaged: //nolint:staticcheck // intentional: this provider still supports legacy cluster-scoped MRs alongside ModernManaged
+		if ref := managed.GetProviderConfigReference(); ref != nil {
+			return providerConfigRef{gvk: clusterv1beta1.ProviderConfigGroupVersionKind, nn: types.NamespacedName{Name: ref.Name}}, true
+		}
+	case resource.ModernManaged:
+		if ref := managed.GetProviderConfigReference(); ref != nil {
+			r := providerConfigRef{gvk: namespacedv1beta1.SchemeGroupVersion.WithKind(ref.Kind), nn: types.NamespacedName{Name: ref.Name}}
+			if ref.Kind == namespacedv1beta1.ProviderConfigKind {
+				r.nn.Namespace = mg.GetNamespace()
+			}
+			return r, true
+		}
+	}
+	return providerConfigRef{}, false
+}
+
+// useCachedMeta records that the ProviderConfig of mg resolved to the cache
+// entry with the given key.
+func useCachedMeta(key string, entry *cachedMeta, mg resource.Managed) {
+	entry.usage.lastUsed.Store(time.Now().UnixNano())
+	ref, ok := providerConfigRefOf(mg)
+	if !ok {
+		return
+	}
+	entry.usage.add(ref)
+	if prev, loaded := pcCacheKeys.Swap(ref, key); loaded && prev.(string) != key {
+		if old, ok := metaCache.Load(prev); ok {
+			old.(*cachedMeta).usage.remove(ref)
+		}
+	}
+}
+
+// EvictStale logs out and evicts the cached Keycloak clients that outlived
+// the TTL, were idle for too long, or whose ProviderConfigs no longer exist,
+// and shrinks the pools of the remaining ones.
+func EvictStale(ctx context.Context, kube client.Client, o EvictionOptions) {
+	metaCache.Range(func(key, value any) bool {
+		entry := value.(*cachedMeta)
+		if reason := staleReason(ctx, kube, entry, o); reason != "" {
+			// Only evict the entry if it was not replaced in the meantime.
+			if metaCache.CompareAndDelete(key, entry) {
+				evictCachedMeta(ctx, key.(string), entry, reason)
+			}
+			return true
+		}
+		if o.IdleTimeout > 0 && entry.pool != nil {
+			for _, c := range entry.pool.Shrink(o.IdleTimeout) {
+				forgetClient(c)
+				entry.sessions.logoutClient(ctx, c)
+				evictions.WithLabelValues(cachePool, reasonIdle).Inc()
+			}
+		}
+		return true
+	})
+}
+
+// staleReason returns why a cache entry must be evicted, or an empty string
+// if it is still in use.
+func staleReason(ctx context.Context, kube client.Client, entry *cachedMeta, o EvictionOptions) string {
+	switch {
+	case o.TTL > 0 && !entry.usage.created.IsZero() && time.Since(entry.usage.created) > o.TTL:
+		return reasonTTL
+	case o.IdleTimeout > 0 && time.Since(time.Unix(0, entry.usage.lastUsed.Load())) > o.IdleTimeout:
+		return reasonIdle
+	case kube != nil && orphaned(ctx, kube, entry):
+		return reasonOrphaned
+	}
+	return ""
+}
+
+// orphaned reports whether none of the ProviderConfigs that resolved to a
+// cache entry still exist. ProviderConfigs that were deleted are forgotten.
+// Errors other than NotFound count as the ProviderConfig still existing.
+func orphaned(ctx context.Context, kube client.Client, entry *cachedMeta) bool {
+	remaining := 0
+	for _, ref := range entry.usage.providerConfigs() {
+		obj, err := kube.Scheme().New(ref.gvk)
+		if err != nil {
+			remaining++
+			continue
+		}
+		pc, ok := obj.(client.Object)
+		if !ok {
+			remaining++
+			continue
+		}
+		if err := kube.Get(ctx, ref.nn, pc); !kerrors.IsNotFound(err) {
+			remaining++
+			continue
+		}
+		entry.usage.remove(ref)
+	}
+	return remaining == 0
+}
+
+// retireClient returns the RetireFunc of the pool of a configuration. It drops
+// a client whose session could not be refreshed, e.g. because Keycloak lost it
+// while it was down, so that the next borrow logs in afresh instead of
+// failing with the same client again. Clients of a configuration with
+// failover endpoints are also retired by retireUnreachableClient. The
+// sessions of retired clients are logged out.
+func retireClient(sessions *configSessions, failover bool) tfconcurrency.RetireFunc {
+	return func(c *keycloak.KeycloakClient, err error) bool {
+		switch {
+		case tfconcurrency.IsRefreshFailure(err):
+			evictions.WithLabelValues(cachePool, reasonRefresh).Inc()
+		case failover && retireUnreachableClient(c, err):
+		default:
+			return false
+		}
+		forgetClient(c)
+		sessions.retire(c)
+		return true
+	}
+}
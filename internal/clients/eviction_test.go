@@ -0,0 +1,83 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/test"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	namespacedv1beta1 "github.com/crossplane-contrib/provider-keycloak/apis/namespaced/v1beta1"
+	"github.com/crossplane-contrib/provider-keycloak/internal/tfconcurrency"
+)
+
+func TestEvictStale(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := namespacedv1beta1.SchemeBuilder.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	deleted := types.NamespacedName{Namespace: "team-a", Name: "deleted"}
+	kube := &test.MockClient{
+		MockScheme: test.NewMockSchemeFn(scheme),
+		MockGet: func(_ context.Context, key client.ObjectKey, _ client.Object) error {
+			if key == deleted {
+				return kerrors.NewNotFound(namespacedv1beta1.SchemeGroupVersion.WithResource("providerconfigs").GroupResource(), key.Name)
+			}
+			return nil
+		},
+	}
+	pcRef := func(nn types.NamespacedName) providerConfigRef {
+		return providerConfigRef{gvk: namespacedv1beta1.ProviderConfigGroupVersionKind, nn: nn}
+	}
+
+	cases := map[string]struct {
+		created  time.Time
+		lastUsed time.Time
+		pc       types.NamespacedName
+		evicted  bool
+	}{
+		"InUse":    {created: time.Now(), lastUsed: time.Now(), pc: types.NamespacedName{Namespace: "team-a", Name: "kept"}},
+		"Expired":  {created: time.Now().Add(-2 * time.Hour), lastUsed: time.Now(), pc: types.NamespacedName{Namespace: "team-a", Name: "kept"}, evicted: true},
+		"Idle":     {created: time.Now(), lastUsed: time.Now().Add(-time.Hour), pc: types.NamespacedName{Namespace: "team-a", Name: "kept"}, evicted: true},
+		"Orphaned": {created: time.Now(), lastUsed: time.Now(), pc: deleted, evicted: true},
+	}
+	pools := map[string]*tfconcurrency.Pool{}
+	for name, tc := range cases {
+		pools[name] = storeOfflineEntry(t, name)
+		v, _ := metaCache.Load(name)
+		entry := v.(*cachedMeta)
+		entry.usage.created = tc.created
+		entry.usage.lastUsed.Store(tc.lastUsed.UnixNano())
+		entry.usage.add(pcRef(tc.pc))
+	}
+
+	EvictStale(context.Background(), kube, EvictionOptions{TTL: time.Hour, IdleTimeout: 30 * time.Minute})
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, cached := metaCache.Load(name)
+			if cached == tc.evicted {
+				t.Fatalf("cached: got %t, want %t", cached, !tc.evicted)
+			}
+			_, err := pools[name].Borrow(context.Background())
+			if closed := err == tfconcurrency.ErrPoolClosed; closed != tc.evicted {
+				t.Fatalf("pool closed: got %t, want %t", closed, tc.evicted)
+			}
+		})
+	}
+}
+
+func TestRetireClientOnRefreshFailure(t *testing.T) {
+	retire := retireClient(newConfigSessions(nil), false)
+	if !retire(nil, errors.New("error refreshing credentials: error sending POST request: 400 Bad Request")) {
+		t.Error("retire() after a failed refresh = false, want true")
+	}
+	if retire(nil, errors.New("error sending GET request to /admin/realms: 403 Forbidden")) {
+		t.Error("retire() after a rejected request = true, want false")
+	}
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2021 Upbound Inc.
+*/
+
+package clients
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/keycloak/terraform-provider-keycloak/keycloak"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/crossplane-contrib/provider-keycloak/internal/keycloaksession"
+	"github.com/crossplane-contrib/provider-keycloak/internal/tfconcurrency"
+)
+
+// failbackAfter is how long an endpoint that failed with a connection error
+// is passed over before it is tried again.
+const failbackAfter = time.Minute
+
+var (
+	// endpointFailures records when an endpoint last failed with a
+	// connection error.
+	endpointFailures sync.Map // map[string]time.Time
+
+	// clientEndpoints records the endpoint of every pooled client of a
+	// configuration with failover endpoints.
+	clientEndpoints sync.Map // map[*keycloak.KeycloakClient]clientEndpoint
+)
+
+type clientEndpoint struct {
+	endpoint  string
+	endpoints []string
+}
+
+var (
+	activeEndpoint = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "keycloak",
+		Subsystem: "endpoint",
+		Name:      "active",
+		Help:      "Set to 1 for the endpoint that new clients of a configuration with failover endpoints connect to.",
+	}, []string{"url", "endpoint"})
+	failovers = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "keycloak",
+		Subsystem: "endpoint",
+		Name:      "failovers_total",
+		Help:      "Number of connection errors that made a configuration fail over to another endpoint.",
+	}, []string{"url", "endpoint"})
+)
+
+// Collectors returns the Prometheus collectors of this package. They must be
+// registered once, e.g. with the controller-runtime metrics registry.
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{activeEndpoint, failovers, evictions, orphanedSessions, providerConfigCollector{}}
+}
+
+// configEndpoints returns the url of a configuration followed by its failover
+// endpoints.
+func configEndpoints(config map[string]any) []string {
+	url, _ := config["url"].(string)
+	endpoints := []string{url}
+	if v, ok := config["failover_urls"].([]any); ok {
+		for _, e := range v {
+			if s, ok := e.(string); ok && s != "" {
+				endpoints = append(endpoints, s)
+			}
+		}
+	}
+	return endpoints
+}
+
+// orderedEndpoints returns the endpoints in the order they should be tried:
+// those without a recent connection error in their configured order, then
+// the ones that failed recently.
+func orderedEndpoints(endpoints []string) []string {
+	healthy := make([]string, 0, len(endpoints))
+	var failed []string
+	for _, e := range endpoints {
+		if t, ok := endpointFailures.Load(e); ok && time.Since(t.(time.Time)) < failbackAfter {
+			failed = append(failed, e)
+			continue
+		}
+		healthy = append(healthy, e)
+	}
+	return append(healthy, failed...)
+}
+
+func markEndpointFailed(endpoints []string, endpoint string) {
+	endpointFailures.Store(endpoint, time.Now())
+	failovers.WithLabelValues(endpoints[0], endpoint).Inc()
+}
+
+func markEndpointActive(endpoints []string, endpoint string) {
+	endpointFailures.Delete(endpoint)
+	for _, e := range endpoints {
+		v := 0.0
+		if e == endpoint {
+			v = 1
+		}
+		activeEndpoint.WithLabelValues(endpoints[0], e).Set(v)
+	}
+}
+
+// newFailoverKeycloakClient builds a client for the first endpoint of the
+// configuration that accepts a login. Endpoints that refuse the connection
+// are skipped and passed over for failbackAfter; any other error is returned
+// immediately. It returns the endpoint the client connected to.
+func newFailoverKeycloakClient(ctx context.Context, config map[string]any, sessions *configSessions) (*keycloak.KeycloakClient, string, error) {
+	endpoints := configEndpoints(config)
+	if len(endpoints) == 1 {
+		c, err := sessionKeycloakClient(ctx, config, sessions, endpoints[0], initialLogin(config))
+		return c, endpoints[0], err
+	}
+
+	var lastErr error
+	for _, endpoint := range orderedEndpoints(endpoints) {
+		// Only a login proves that an endpoint is reachable.
+		c, err := sessionKeycloakClient(ctx, config, sessions, endpoint, true)
+		if err == nil {
+			markEndpointActive(endpoints, endpoint)
+			return c, endpoint, nil
+		}
+		if !tfconcurrency.IsConnectionError(err) {
+			return nil, "", err
+		}
+		markEndpointFailed(endpoints, endpoint)
+		lastErr = err
+	}
+	return nil, "", lastErr
+}
+
+// retireUnreachableClient decides for a pool whose configuration has failover
+// endpoints whether to retire a client. It retires a client whose operation
+// failed with a connection error, so that the next borrow fails over, and a
+// client on a failover endpoint once a preferred endpoint is due to be tried
+// again, so that the pool fails back.
+func retireUnreachableClient(c *keycloak.KeycloakClient, err error) bool {
+	v, ok := clientEndpoints.Load(c)
+	if !ok {
+		return false
+	}
+	ce := v.(clientEndpoint)
+	if tfconcurrency.IsConnectionError(err) {
+		markEndpointFailed(ce.endpoints, ce.endpoint)
+		return true
+	}
+	return orderedEndpoints(ce.endpoints)[0] != ce.endpoint
+}
+
+// forgetClient drops the endpoint record and the session binding of a client
+// that is discarded.
+func forgetClient(c *keycloak.KeycloakClient) {
+	clientEndpoints.Delete(c)
+	keycloaksession.Unbind(c)
+}
@@ -0,0 +1,54 @@
+package clients
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOrderedEndpoints(t *testing.T) {
+	endpoints := []string{"https://site-a.example.com", "https://site-b.example.com", "https://site-c.example.com"}
+	t.Cleanup(func() {
+		for _, e := range endpoints {
+			endpointFailures.Delete(e)
+		}
+	})
+
+	if got := orderedEndpoints(endpoints); !reflect.DeepEqual(got, endpoints) {
+		t.Fatalf("orderedEndpoints() without failures = %v, want %v", got, endpoints)
+	}
+
+	markEndpointFailed(endpoints, endpoints[0])
+	want := []string{endpoints[1], endpoints[2], endpoints[0]}
+	if got := orderedEndpoints(endpoints); !reflect.DeepEqual(got, want) {
+		t.Fatalf("orderedEndpoints() after a failure = %v, want %v", got, want)
+	}
+
+	// The failed endpoint is preferred again once failbackAfter has passed.
+	endpointFailures.Store(endpoints[0], time.Now().Add(-2*failbackAfter))
+	if got := orderedEndpoints(endpoints); !reflect.DeepEqual(got, endpoints) {
+		t.Fatalf("orderedEndpoints() after failback = %v, want %v", got, endpoints)
+	}
+}
+
+func TestNormalizeFailoverURLs(t *testing.T) {
+	config := map[string]any{
+		"url":           "https://site-a.example.com/",
+		"failover_urls": "https://site-b.example.com/, https://site-c.example.com",
+	}
+	if err := validateAndNormalizeURLAndBasePath(config); err != nil {
+		t.Fatalf("validateAndNormalizeURLAndBasePath() error = %v", err)
+	}
+	want := []any{"https://site-b.example.com", "https://site-c.example.com"}
+	if !reflect.DeepEqual(config["failover_urls"], want) {
+		t.Errorf("failover_urls = %v, want %v", config["failover_urls"], want)
+	}
+	if got := configEndpoints(config); len(got) != 3 || got[0] != "https://site-a.example.com" {
+		t.Errorf("configEndpoints() = %v", got)
+	}
+
+	config["failover_urls"] = []any{"not a url"}
+	if err := validateAndNormalizeURLAndBasePath(config); err == nil {
+		t.Error("validateAndNormalizeURLAndBasePath() with an invalid failover url: want error")
+	}
+}
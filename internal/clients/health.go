@@ -0,0 +1,112 @@
+/*
+Copyright 2021 Upbound Inc.
+*/
+
+package clients
+
+import (
+	"context"
+	"time"
+
+	"github.com/keycloak/terraform-provider-keycloak/keycloak"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+
+	"github.com/crossplane-contrib/provider-keycloak/internal/keycloaksession"
+	"github.com/crossplane-contrib/provider-keycloak/internal/tfconcurrency"
+)
+
+const (
+	errHealthLogin      = "cannot log in to Keycloak"
+	errHealthBorrow     = "cannot borrow a Keycloak client"
+	errHealthServerInfo = "cannot read Keycloak server info"
+)
+
+// Health is the outcome of a successful ProviderConfig health check.
+type Health struct {
+	// ServerVersion is the version reported by the Keycloak server.
+	ServerVersion string
+	// Endpoint is the Keycloak endpoint the check connected to.
+	Endpoint string
+	// Realm is the realm the provider authenticated against.
+	Realm string
+	// TokenExpiry is the expiry of the access token obtained by the check.
+	// It is nil if the token does not carry an expiry.
+	TokenExpiry *time.Time
+}
+
+// CheckHealth reads the server version of the Keycloak instance of the given
+// ProviderConfig. It borrows a client from the pool of the ProviderConfig's
+// configuration, so that the check shares its session and is subject to its
+// circuit breaker and rate limit. A configuration that has no pool yet, e.g.
+// because no managed resource uses it, is checked with a session of its own,
+// which is logged out again, subject to the same breaker and rate limit.
+func CheckHealth(ctx context.Context, kube client.Client, pc resource.ProviderConfig) (*Health, error) {
+	pcSpec, err := ProviderConfigSpec(pc)
+	if err != nil {
+		return nil, err
+	}
+	config, err := providerConfiguration(ctx, kube, pcSpec)
+	if err != nil {
+		return nil, err
+	}
+	key := keycloaksession.ConfigCacheKey(config)
+	kcClient, release, err := tfconcurrency.BorrowByKey(ctx, key)
+	if errors.Is(err, tfconcurrency.ErrNoPool) {
+		return checkHealthWithSession(ctx, key, config)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, errHealthBorrow)
+	}
+	endpoint := configEndpoints(config)[0]
+	if v, ok := clientEndpoints.Load(kcClient); ok {
+		endpoint = v.(clientEndpoint).endpoint
+	}
+	h, err := serverHealth(ctx, kcClient, endpoint, config)
+	release(err)
+	return h, err
+}
+
+// checkHealthWithSession checks the health of a configuration that has no
+// pool with a dedicated session, which is logged out once it is done.
+func checkHealthWithSession(ctx context.Context, key string, config map[string]any) (*Health, error) {
+	if err := tfconcurrency.Admit(key); err != nil {
+		return nil, err
+	}
+	// A health check must always authenticate, even when the configuration
+	// defers the login to the first request.
+	config["initial_login"] = true
+	sessions := newConfigSessions(config)
+	defer sessions.logout(ctx)
+
+	kcClient, endpoint, err := newFailoverKeycloakClient(ctx, config, sessions)
+	tfconcurrency.RecordResult(key, err)
+	if err != nil {
+		return nil, errors.Wrap(err, errHealthLogin)
+	}
+	defer forgetClient(kcClient)
+	return serverHealth(ctx, kcClient, endpoint, config)
+}
+
+// serverHealth reads the server version with the given client, which is
+// connected to endpoint.
+func serverHealth(ctx context.Context, kcClient *keycloak.KeycloakClient, endpoint string, config map[string]any) (*Health, error) {
+	info, err := kcClient.GetServerInfo(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errHealthServerInfo)
+	}
+
+	h := &Health{
+		ServerVersion: info.SystemInfo.ServerVersion,
+		Endpoint:      endpoint,
+		Realm:         loginRealm(config),
+	}
+	if s, ok := keycloaksession.SessionOf(kcClient); ok {
+		if tokens := s.Tokens(); !tokens.Expiry.IsZero() {
+			h.TokenExpiry = &tokens.Expiry
+		}
+	}
+	return h, nil
+}
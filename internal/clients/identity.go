@@ -0,0 +1,101 @@
+/*
+Copyright 2021 Upbound Inc.
+*/
+
+package clients
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	namespacedv1beta1 "github.com/crossplane-contrib/provider-keycloak/apis/namespaced/v1beta1"
+)
+
+// DefaultIdentityTokenPath is the path of the projected ServiceAccount token
+// that the InjectedIdentity credentials source reads if the ProviderConfig
+// does not name one.
+const DefaultIdentityTokenPath = "/var/run/secrets/keycloak.crossplane.io/serviceaccount/token"
+
+const (
+	errReadIdentityToken  = "cannot read projected service account token"
+	errFmtEmptyIdentToken = "projected service account token %s is empty"
+)
+
+// identityToken records the projected ServiceAccount token a cache entry was
+// built from, so that the entry can be evicted once kubelet rotates the token.
+type identityToken struct {
+	path string
+	sum  string
+}
+
+// extractInjectedIdentityCredentials reads the provider's projected
+// ServiceAccount token and presents it as the client assertion of the client
+// JWT grant. All other settings come from the typed fields of the
+// ProviderConfig.
+func extractInjectedIdentityCredentials(selector xpv1.CommonCredentialSelectors) (map[string]any, error) {
+	path := identityTokenPath(selector)
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, errReadIdentityToken)
+	}
+	token := strings.TrimSpace(string(raw))
+	if token == "" {
+		return nil, errors.Errorf(errFmtEmptyIdentToken, path)
+	}
+	return map[string]any{"jwt_token": token}, nil
+}
+
+func identityTokenPath(selector xpv1.CommonCredentialSelectors) string {
+	if selector.Fs != nil && selector.Fs.Path != "" {
+		return selector.Fs.Path
+	}
+	return DefaultIdentityTokenPath
+}
+
+// trackIdentityToken records the projected token of a ProviderConfig that uses
+// the InjectedIdentity credentials source on the cache entry built from it.
+func trackIdentityToken(entry *cachedMeta, pcSpec *namespacedv1beta1.ClusterProviderConfigSpec, config map[string]any) {
+	if pcSpec.Credentials.Source != xpv1.CredentialsSourceInjectedIdentity {
+		return
+	}
+	token, _ := config["jwt_token"].(string)
+	entry.identity = identityToken{
+		path: identityTokenPath(pcSpec.Credentials.CommonCredentialSelectors),
+		sum:  tokenSum([]byte(token)),
+	}
+}
+
+func tokenSum(token []byte) string {
+	s := sha256.Sum256([]byte(strings.TrimSpace(string(token))))
+	return hex.EncodeToString(s[:])
+}
+
+// RotateIdentityTokens evicts every cached Keycloak client that was built from
+// a projected ServiceAccount token which has since been rotated, so that the
+// next reconciliation logs in again with the current token. Tokens that cannot
+// be read are left alone; the old token stays valid until it expires. It
+// returns the number of evicted configurations.
+func RotateIdentityTokens(ctx context.Context) int {
+	evicted := 0
+	metaCache.Range(func(key, value any) bool {
+		entry := value.(*cachedMeta)
+		if entry.identity.path == "" {
+			return true
+		}
+		raw, err := os.ReadFile(entry.identity.path)
+		if err != nil || tokenSum(raw) == entry.identity.sum {
+			return true
+		}
+		metaCache.Delete(key)
+		evictCachedMeta(ctx, key.(string), entry, reasonRotated)
+		evicted++
+		return true
+	})
+	return evicted
+}
@@ -10,9 +10,13 @@ import (
 	"fmt"
 	"math"
 	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	terraformSDK "github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 	"github.com/keycloak/terraform-provider-keycloak/keycloak"
@@ -20,9 +24,9 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
-	"github.com/crossplane/crossplane-runtime/v2/apis/common"
 	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
 	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/event"
 	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
 
 	"github.com/crossplane/upjet/v2/pkg/terraform"
@@ -30,8 +34,10 @@ import (
 
 	clusterv1beta1 "github.com/crossplane-contrib/provider-keycloak/apis/cluster/v1beta1"
 	namespacedv1beta1 "github.com/crossplane-contrib/provider-keycloak/apis/namespaced/v1beta1"
+	"github.com/crossplane-contrib/provider-keycloak/internal/adoption"
 	"github.com/crossplane-contrib/provider-keycloak/internal/clients/stalerefs"
 	"github.com/crossplane-contrib/provider-keycloak/internal/keycloaksession"
+	"github.com/crossplane-contrib/provider-keycloak/internal/tenancy"
 	"github.com/crossplane-contrib/provider-keycloak/internal/tfconcurrency"
 )
 
@@ -43,21 +49,31 @@ const (
 	errExtractCredentials           = "cannot extract credentials"
 	errUnmarshalCredentials         = "cannot unmarshal keycloak credentials as JSON"
 	errExtractSecretKey             = "cannot extract from secret key when none specified"
+	errExtractEnvKey                = "cannot extract from environment variable when none specified"
+	errExtractFsPath                = "cannot extract from filesystem path when none specified"
+	errReadCredentialsFs            = "cannot read credentials from filesystem"
+	errFmtNoEnvCredentials          = "neither environment variable %s nor any variable prefixed with %s is set"
+	errFmtUnsupportedCredSource     = "credentials source %s is not supported"
 	errGetCredentialsSecret         = "cannot get credentials secret"
 	errInvalidClientTimeout         = "invalid client_timeout value in credentials secret"
 	errInvalidTLSInsecureSkipVerify = "invalid tls_insecure_skip_verify value in credentials secret"
 	errInvalidURL                   = "invalid url value in credentials secret"
 	errInvalidAdminURL              = "invalid admin_url value in credentials secret"
 	errInvalidBasePath              = "invalid base_path value in credentials secret"
+	errInvalidFailoverURLs          = "invalid failover_urls value in credentials secret"
 )
 
 // cachedMeta holds the Terraform provider meta alongside the
 // configuration that produced it, so that the session can be logged
-// out on shutdown.
+// out on shutdown or when its credentials are rotated.
 type cachedMeta struct {
-	meta   interface{}
-	config map[string]any
-	pool   *tfconcurrency.Pool
+	meta         interface{}
+	sessions     *configSessions
+	pool         *tfconcurrency.Pool
+	capabilities *serverCapabilities
+	secrets      credentialSecrets
+	identity     identityToken
+	usage        entryUsage
 }
 
 // metaCache caches the configured Terraform provider meta (keycloak
@@ -100,16 +116,18 @@ var optionalKeycloakConfigKeys = []string{
 	"jwt_signing_key",
 	"jwt_token",
 	"jwt_token_file",
+	"failover_urls",
 }
 
 // TerraformSetupBuilder builds Terraform a terraform.SetupFn function which
-// returns Terraform provider setup configuration
+// returns Terraform provider setup configuration. Stale-reference recoveries
+// are recorded as events with record.
 // nolint: gocyclo
-func TerraformSetupBuilder(poolSize int) terraform.SetupFn {
+func TerraformSetupBuilder(poolSize int, record event.Recorder) terraform.SetupFn {
 	return func(ctx context.Context, client client.Client, mg resource.Managed) (terraform.Setup, error) {
 		ps := terraform.Setup{}
 
-		if recovered, err := stalerefs.MaybeRecover(ctx, client, mg); err != nil {
+		if recovered, err := stalerefs.MaybeRecover(ctx, client, record, mg); err != nil {
 			return terraform.Setup{}, errors.Wrap(err, "stale reference recovery failed")
 		} else if recovered {
 			return terraform.Setup{}, errors.New("cleared stale references; reconciling")
@@ -119,31 +137,23 @@ func TerraformSetupBuilder(poolSize int) terraform.SetupFn {
 		if err != nil {
 			return terraform.Setup{}, err
 		}
-
-		creds, err := ExtractCredentials(ctx, pcSpec.Credentials.Source, client, pcSpec.Credentials.CommonCredentialSelectors)
-		if err != nil {
-			return ps, errors.Wrap(err, errExtractCredentials)
+		if err := tenancy.CheckNamespace(pcSpec.AllowedNamespaces, mg.GetNamespace()); err != nil {
+			return terraform.Setup{}, err
 		}
-
-		// set provider configuration
-		ps.Configuration = map[string]any{}
-		// Iterate over the requiredKeycloakConfigKeys, they must be set
-		for _, key := range requiredKeycloakConfigKeys {
-			value, ok := creds[key]
-			if !ok {
-				return ps, errors.Errorf("required Keycloak configuration key '%s' is missing", key)
-			}
-			ps.Configuration[key] = value
+		if err := tenancy.CheckManaged(pcSpec.AllowedRealms, mg); err != nil {
+			return terraform.Setup{}, err
 		}
-
-		// Iterate over the optionalKeycloakConfigKeys, they can be set and do not have to be in the creds map
-		for _, key := range optionalKeycloakConfigKeys {
-			if value, ok := creds[key]; ok {
-				ps.Configuration[key] = value
-			}
+		// The adoption policy reaches the lookups of the external name
+		// through the client metadata, see config/lookup.
+		if ps.ClientMetadata, err = adoptionMetadata(client, mg, pcSpec.AdoptionPolicy); err != nil {
+			return terraform.Setup{}, err
 		}
+		// So do the allowed realms, which must not be part of the
+		// configuration that identifies the cached clients.
+		tenancy.SetAllowedRealms(ps.ClientMetadata, pcSpec.AllowedRealms)
 
-		if err := validateAndNormalizeURLAndBasePath(ps.Configuration); err != nil {
+		ps.Configuration, err = providerConfiguration(ctx, client, pcSpec)
+		if err != nil {
 			return ps, err
 		}
 
@@ -151,9 +161,9 @@ func TerraformSetupBuilder(poolSize int) terraform.SetupFn {
 		// reuse it to avoid creating a new Keycloak login session on
 		// every reconciliation.
 		cacheKey := keycloaksession.ConfigCacheKey(ps.Configuration)
+		setRateLimit(cacheKey, mg, pcSpec.RateLimit)
 		if cached, ok := metaCache.Load(cacheKey); ok {
-			ps.Meta = cached.(*cachedMeta).meta
-			return ps, nil
+			return reuseCachedMeta(ctx, ps, cacheKey, cached.(*cachedMeta), pcSpec, mg)
 		}
 
 		// Not cached yet – create the client under a mutex so that
@@ -162,11 +172,21 @@ func TerraformSetupBuilder(poolSize int) terraform.SetupFn {
 		metaCacheMu.Lock()
 		defer metaCacheMu.Unlock()
 		if cached, ok := metaCache.Load(cacheKey); ok {
-			ps.Meta = cached.(*cachedMeta).meta
-			return ps, nil
+			return reuseCachedMeta(ctx, ps, cacheKey, cached.(*cachedMeta), pcSpec, mg)
 		}
 
-		if err := configureNoForkKeycloakClient(ctx, &ps); err != nil {
+		// Do not log in while Keycloak is known to be down, so that a
+		// returning server does not face a login from every reconciliation.
+		if err := tfconcurrency.CheckAvailable(cacheKey); err != nil {
+			return ps, err
+		}
+		sessions := newConfigSessions(ps.Configuration)
+		if pcSpec.CleanupOrphanedSessions {
+			sessions.track(ctx, managedLedgerKey(mg))
+		}
+		err = configureNoForkKeycloakClient(ctx, &ps, sessions)
+		tfconcurrency.RecordResult(cacheKey, err)
+		if err != nil {
 			return ps, errors.Wrap(err, "failed to configure the no-fork client")
 		}
 
@@ -180,26 +200,122 @@ func TerraformSetupBuilder(poolSize int) terraform.SetupFn {
 		// pool so it is reused rather than left idle.
 		cfg := ps.Configuration
 		pool := tfconcurrency.NewPool(poolSize, func(fctx context.Context) (*keycloak.KeycloakClient, error) {
-			return newConfiguredKeycloakClient(fctx, cfg)
+			return newConfiguredKeycloakClient(fctx, cfg, sessions)
 		})
 		pool.Seed(primary)
+		pool.SetConfigKey(cacheKey)
+		pool.SetRetire(retireClient(sessions, len(configEndpoints(cfg)) > 1))
 		tfconcurrency.Register(primary, pool)
+		tfconcurrency.RegisterKey(cacheKey, pool)
 
-		// Store only the fields needed for logout to reduce sensitive
-		// credential exposure in process memory.
-		metaCache.Store(cacheKey, &cachedMeta{
-			meta:   ps.Meta,
-			config: keycloaksession.LogoutConfig(ps.Configuration),
-			pool:   pool,
-		})
+		entry := &cachedMeta{
+			meta:         ps.Meta,
+			sessions:     sessions,
+			pool:         pool,
+			capabilities: newServerCapabilities(pool, cfg),
+		}
+		entry.usage.created = time.Now()
+		trackCredentialSecret(entry, pcSpec)
+		trackIdentityToken(entry, pcSpec, ps.Configuration)
+		useCachedMeta(cacheKey, entry, mg)
+		metaCache.Store(cacheKey, entry)
+		if err := entry.capabilities.check(ctx, mg); err != nil {
+			return terraform.Setup{}, err
+		}
 		return ps, nil
 	}
 }
 
+// adoptionMetadata returns the client metadata that carries the adoption
+// policy of mg to the lookups of its external name.
+func adoptionMetadata(kube client.Client, mg resource.Managed, def namespacedv1beta1.AdoptionPolicy) (map[string]string, error) {
+	policy, err := adoption.Policy(mg, def)
+	if err != nil {
+		return nil, err
+	}
+	gvk, err := kube.GroupVersionKindFor(mg)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot determine the kind of the managed resource")
+	}
+	owner := adoption.Owner(gvk.GroupKind(), types.NamespacedName{Namespace: mg.GetNamespace(), Name: mg.GetName()})
+	return adoption.ClientMetadata(policy, owner, mg.GetUID(), adoption.MayWrite(mg)), nil
+}
+
+// reuseCachedMeta sets up ps with the cached client of its configuration.
+func reuseCachedMeta(ctx context.Context, ps terraform.Setup, key string, entry *cachedMeta, pcSpec *namespacedv1beta1.ClusterProviderConfigSpec, mg resource.Managed) (terraform.Setup, error) {
+	trackCredentialSecret(entry, pcSpec)
+	if pcSpec.CleanupOrphanedSessions {
+		entry.sessions.track(ctx, managedLedgerKey(mg))
+	}
+	useCachedMeta(key, entry, mg)
+	if err := entry.capabilities.check(ctx, mg); err != nil {
+		return terraform.Setup{}, err
+	}
+	ps.Meta = entry.meta
+	return ps, nil
+}
+
+// providerConfiguration extracts the credentials of a ProviderConfig and
+// builds the validated Terraform provider configuration from them.
+func providerConfiguration(ctx context.Context, client client.Client, pcSpec *namespacedv1beta1.ClusterProviderConfigSpec) (map[string]any, error) {
+	creds := map[string]any{}
+	if pcSpec.Credentials.Source != xpv1.CredentialsSourceNone {
+		var err error
+		creds, err = ExtractCredentials(ctx, pcSpec.Credentials.Source, client, pcSpec.Credentials.CommonCredentialSelectors)
+		if err != nil {
+			return nil, errors.Wrap(err, errExtractCredentials)
+		}
+	}
+	if err := applyConnectionConfig(ctx, client, pcSpec, creds); err != nil {
+		return nil, err
+	}
+
+	// set provider configuration
+	config := map[string]any{}
+	// Iterate over the requiredKeycloakConfigKeys, they must be set
+	for _, key := range requiredKeycloakConfigKeys {
+		value, ok := creds[key]
+		if !ok {
+			return nil, errors.Errorf("required Keycloak configuration key '%s' is missing", key)
+		}
+		config[key] = value
+	}
+
+	// Iterate over the optionalKeycloakConfigKeys, they can be set and do not have to be in the creds map
+	for _, key := range optionalKeycloakConfigKeys {
+		if value, ok := creds[key]; ok {
+			config[key] = value
+		}
+	}
+
+	if err := validateAndNormalizeURLAndBasePath(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// trackCredentialSecret records the credentials Secrets of a ProviderConfig on
+// the cache entry built from them, so that the entry can be evicted when one
+// of the Secrets changes.
+func trackCredentialSecret(entry *cachedMeta, pcSpec *namespacedv1beta1.ClusterProviderConfigSpec) {
+	if pcSpec.Credentials.Source == xpv1.CredentialsSourceSecret && pcSpec.Credentials.SecretRef != nil {
+		entry.secrets.add(types.NamespacedName{
+			Namespace: pcSpec.Credentials.SecretRef.Namespace,
+			Name:      pcSpec.Credentials.SecretRef.Name,
+		})
+	}
+	for _, sel := range connectionSecretRefs(pcSpec) {
+		entry.secrets.add(types.NamespacedName{Namespace: sel.Namespace, Name: sel.Name})
+	}
+}
+
 func validateAndNormalizeURLAndBasePath(config map[string]any) error {
 	if err := normalizeURLField(config, "url", errInvalidURL); err != nil {
 		return err
 	}
+	if err := normalizeFailoverURLs(config); err != nil {
+		return err
+	}
 	if _, ok := config["admin_url"]; ok {
 		if err := normalizeURLField(config, "admin_url", errInvalidAdminURL); err != nil {
 			return err
@@ -234,6 +350,48 @@ func validateAndNormalizeURLAndBasePath(config map[string]any) error {
 	return nil
 }
 
+// normalizeFailoverURLs normalizes the failover endpoints of a configuration
+// like its url. They may be given as a list or as a comma separated string.
+func normalizeFailoverURLs(config map[string]any) error {
+	value, ok := config["failover_urls"]
+	if !ok {
+		return nil
+	}
+	var raw []string
+	switch v := value.(type) {
+	case string:
+		raw = strings.Split(v, ",")
+	case []any:
+		for _, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return errors.New(errInvalidFailoverURLs)
+			}
+			raw = append(raw, s)
+		}
+	default:
+		return errors.New(errInvalidFailoverURLs)
+	}
+
+	urls := make([]any, 0, len(raw))
+	for _, r := range raw {
+		if strings.TrimSpace(r) == "" {
+			continue
+		}
+		u := map[string]any{"url": r}
+		if err := normalizeURLField(u, "url", errInvalidFailoverURLs); err != nil {
+			return err
+		}
+		urls = append(urls, u["url"])
+	}
+	if len(urls) == 0 {
+		delete(config, "failover_urls")
+		return nil
+	}
+	config["failover_urls"] = urls
+	return nil
+}
+
 func normalizeURLField(config map[string]any, key, errMessage string) error {
 	value, ok := config[key]
 	if !ok {
@@ -270,8 +428,21 @@ func normalizeURLField(config map[string]any, key, errMessage string) error {
 
 // ExtractCredentials Function that extracts credentials from the secret provided to providerconfig
 func ExtractCredentials(ctx context.Context, source xpv1.CredentialsSource, client client.Client, selector xpv1.CommonCredentialSelectors) (map[string]any, error) {
-	creds := make(map[string]any)
+	switch source { //nolint:exhaustive // None is handled by the caller
+	case xpv1.CredentialsSourceSecret:
+		return extractSecretCredentials(ctx, source, client, selector)
+	case xpv1.CredentialsSourceEnvironment:
+		return extractEnvironmentCredentials(selector)
+	case xpv1.CredentialsSourceFilesystem:
+		return extractFilesystemCredentials(selector)
+	case xpv1.CredentialsSourceInjectedIdentity:
+		return extractInjectedIdentityCredentials(selector)
+	default:
+		return nil, errors.Errorf(errFmtUnsupportedCredSource, source)
+	}
+}
 
+func extractSecretCredentials(ctx context.Context, source xpv1.CredentialsSource, client client.Client, selector xpv1.CommonCredentialSelectors) (map[string]any, error) {
 	// first try to see if the secret contains a proper key-value map
 	if selector.SecretRef == nil {
 		return nil, errors.New(errExtractSecretKey)
@@ -281,26 +452,7 @@ func ExtractCredentials(ctx context.Context, source xpv1.CredentialsSource, clie
 		return nil, errors.Wrap(err, errGetCredentialsSecret)
 	}
 	if _, ok := secret.Data[selector.SecretRef.Key]; !ok {
-		for k, v := range secret.Data {
-			switch k {
-			case "client_timeout":
-				if n, err := strconv.Atoi(string(v)); err == nil {
-					creds[k] = n
-				} else {
-					return nil, errors.Wrap(err, errInvalidClientTimeout)
-				}
-				continue
-			case "tls_insecure_skip_verify":
-				if b, err := strconv.ParseBool(string(v)); err == nil {
-					creds[k] = b
-				} else {
-					return nil, errors.Wrap(err, errInvalidTLSInsecureSkipVerify)
-				}
-				continue
-			}
-			creds[k] = string(v)
-		}
-		return creds, nil
+		return parseCredentialsKeyValues(secret.Data)
 	}
 
 	// if that fails, use Crossplane's way of extracting a JSON document
@@ -308,6 +460,112 @@ func ExtractCredentials(ctx context.Context, source xpv1.CredentialsSource, clie
 	if err != nil {
 		return nil, err
 	}
+	return parseCredentialsJSON(rawData)
+}
+
+// extractEnvironmentCredentials reads the credentials from the environment
+// variable named by the selector, which holds the same JSON document a Secret
+// key would. If that variable is unset, every variable prefixed with
+// "<name>_" is read as a single key instead, e.g. KEYCLOAK_CLIENT_ID for the
+// client_id key when the selector names KEYCLOAK.
+func extractEnvironmentCredentials(selector xpv1.CommonCredentialSelectors) (map[string]any, error) {
+	if selector.Env == nil || selector.Env.Name == "" {
+		return nil, errors.New(errExtractEnvKey)
+	}
+	if raw, ok := os.LookupEnv(selector.Env.Name); ok {
+		return parseCredentialsJSON([]byte(raw))
+	}
+
+	prefix := selector.Env.Name + "_"
+	data := make(map[string][]byte)
+	for _, kv := range os.Environ() {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, prefix) || len(name) == len(prefix) {
+			continue
+		}
+		data[strings.ToLower(strings.TrimPrefix(name, prefix))] = []byte(value)
+	}
+	if len(data) == 0 {
+		return nil, errors.Errorf(errFmtNoEnvCredentials, selector.Env.Name, prefix)
+	}
+	return parseCredentialsKeyValues(data)
+}
+
+// extractFilesystemCredentials reads the credentials from the path named by
+// the selector. A regular file holds the same JSON document a Secret key would.
+// A directory, such as a mounted Secret or projected volume, holds one file per
+// key, exactly like the flat key format of a Secret.
+func extractFilesystemCredentials(selector xpv1.CommonCredentialSelectors) (map[string]any, error) {
+	if selector.Fs == nil || selector.Fs.Path == "" {
+		return nil, errors.New(errExtractFsPath)
+	}
+	info, err := os.Stat(selector.Fs.Path)
+	if err != nil {
+		return nil, errors.Wrap(err, errReadCredentialsFs)
+	}
+	if !info.IsDir() {
+		raw, err := os.ReadFile(selector.Fs.Path)
+		if err != nil {
+			return nil, errors.Wrap(err, errReadCredentialsFs)
+		}
+		return parseCredentialsJSON(raw)
+	}
+
+	entries, err := os.ReadDir(selector.Fs.Path)
+	if err != nil {
+		return nil, errors.Wrap(err, errReadCredentialsFs)
+	}
+	data := make(map[string][]byte, len(entries))
+	for _, e := range entries {
+		// Kubernetes volumes keep their atomic-update bookkeeping (..data,
+		// ..2024_01_01...) in hidden entries next to the key files.
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		p := filepath.Join(selector.Fs.Path, e.Name())
+		// Follow the symlinks of mounted volumes and skip anything that does
+		// not resolve to a regular file.
+		if fi, err := os.Stat(p); err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		v, err := os.ReadFile(filepath.Clean(p))
+		if err != nil {
+			return nil, errors.Wrap(err, errReadCredentialsFs)
+		}
+		data[e.Name()] = v
+	}
+	return parseCredentialsKeyValues(data)
+}
+
+// parseCredentialsKeyValues converts a flat key-value credentials map, where
+// every value is a raw string, into the provider configuration types.
+func parseCredentialsKeyValues(data map[string][]byte) (map[string]any, error) {
+	creds := make(map[string]any, len(data))
+	for k, v := range data {
+		switch k {
+		case "client_timeout":
+			if n, err := strconv.Atoi(string(v)); err == nil {
+				creds[k] = n
+			} else {
+				return nil, errors.Wrap(err, errInvalidClientTimeout)
+			}
+			continue
+		case "tls_insecure_skip_verify":
+			if b, err := strconv.ParseBool(string(v)); err == nil {
+				creds[k] = b
+			} else {
+				return nil, errors.Wrap(err, errInvalidTLSInsecureSkipVerify)
+			}
+			continue
+		}
+		creds[k] = string(v)
+	}
+	return creds, nil
+}
+
+// parseCredentialsJSON parses a JSON credentials document.
+func parseCredentialsJSON(rawData []byte) (map[string]any, error) {
+	creds := make(map[string]any)
 	if err := json.Unmarshal(rawData, &creds); err != nil {
 		return nil, errors.Wrap(err, errUnmarshalCredentials)
 	}
@@ -325,8 +583,8 @@ func ExtractCredentials(ctx context.Context, source xpv1.CredentialsSource, clie
 }
 
 // Function to setup provider that uses terraform SDK
-func configureNoForkKeycloakClient(ctx context.Context, ps *terraform.Setup) error {
-	client, err := newConfiguredKeycloakClient(ctx, ps.Configuration)
+func configureNoForkKeycloakClient(ctx context.Context, ps *terraform.Setup, sessions *configSessions) error {
+	client, err := newConfiguredKeycloakClient(ctx, ps.Configuration, sessions)
 	if err != nil {
 		return err
 	}
@@ -335,11 +593,36 @@ func configureNoForkKeycloakClient(ctx context.Context, ps *terraform.Setup) err
 }
 
 // newConfiguredKeycloakClient builds and configures a *keycloak.KeycloakClient
-// from a provider configuration (performing the initial login). It is used both
-// for the primary client and for the additional clients in the per-config pool.
-func newConfiguredKeycloakClient(ctx context.Context, config map[string]any) (*keycloak.KeycloakClient, error) {
+// from a provider configuration and records its session with the
+// configuration's sessions. It is used both for the primary client and for
+// the additional clients in the per-config pool. If the configuration lists
+// failover endpoints, the client connects to the first reachable one.
+func newConfiguredKeycloakClient(ctx context.Context, config map[string]any, sessions *configSessions) (*keycloak.KeycloakClient, error) {
+	c, endpoint, err := newFailoverKeycloakClient(ctx, config, sessions)
+	if err != nil {
+		return nil, err
+	}
+	if endpoints := configEndpoints(config); len(endpoints) > 1 {
+		clientEndpoints.Store(c, clientEndpoint{endpoint: endpoint, endpoints: endpoints})
+	}
+	return c, nil
+}
+
+// providerOnlyConfigKeys are the configuration keys consumed by this
+// provider, not by the Terraform provider.
+var providerOnlyConfigKeys = []string{"failover_urls"}
+
+// configureKeycloakClient configures the Terraform provider for a single
+// endpoint and returns its client.
+func configureKeycloakClient(ctx context.Context, config map[string]any) (*keycloak.KeycloakClient, error) {
+	tfConfig := make(map[string]any, len(config))
+	for k, v := range config {
+		if !slices.Contains(providerOnlyConfigKeys, k) {
+			tfConfig[k] = v
+		}
+	}
 	cb := keycloakProvider.KeycloakProvider(nil)
-	diags := cb.Configure(ctx, terraformSDK.NewResourceConfigRaw(config))
+	diags := cb.Configure(ctx, terraformSDK.NewResourceConfigRaw(tfConfig))
 	if diags.HasError() {
 		return nil, fmt.Errorf("failed to configure the Keycloak provider: %v", diags)
 	}
@@ -350,22 +633,22 @@ func newConfiguredKeycloakClient(ctx context.Context, config map[string]any) (*k
 	return client, nil
 }
 
-// CleanupSessions logs out all cached Keycloak sessions. It should be
-// called during graceful shutdown to avoid leaving orphaned sessions
-// on the Keycloak server (relevant only for password-grant configs).
+// CleanupSessions logs out all cached Keycloak sessions and revokes their
+// tokens. It should be called during graceful shutdown to avoid leaving
+// orphaned sessions and valid tokens on the Keycloak server.
 func CleanupSessions(ctx context.Context) {
 	metaCache.Range(func(key, value any) bool {
 		entry := value.(*cachedMeta)
 		if entry.pool != nil {
 			for _, c := range entry.pool.Clients() {
-				keycloaksession.LogoutSession(ctx, entry.config, c)
+				forgetClient(c)
 			}
 			if primary, ok := entry.meta.(*keycloak.KeycloakClient); ok {
 				tfconcurrency.Unregister(primary)
 			}
-		} else if kcClient, ok := entry.meta.(*keycloak.KeycloakClient); ok {
-			keycloaksession.LogoutSession(ctx, entry.config, kcClient)
+			tfconcurrency.UnregisterKey(key.(string), entry.pool)
 		}
+		entry.sessions.logout(ctx)
 		metaCache.Delete(key)
 		return true
 	})
@@ -435,31 +718,43 @@ func resolveProviderConfigModern(ctx context.Context, crClient client.Client, mg
 		return nil, errors.Wrap(err, errGetProviderConfig)
 	}
 
-	var pcSpec namespacedv1beta1.ClusterProviderConfigSpec
-	switch pc := pcObj.(type) {
-	case *namespacedv1beta1.ProviderConfig:
-		pcSpec = namespacedv1beta1.ClusterProviderConfigSpec{
-			Credentials: namespacedv1beta1.ClusterProviderCredentials{
-				Source: "Secret",
-				CommonCredentialSelectors: common.CommonCredentialSelectors{
-					SecretRef: &common.SecretKeySelector{
-						Key: pc.Spec.CredentialsSecretRef.Key,
-						SecretReference: common.SecretReference{
-							Name:      pc.Spec.CredentialsSecretRef.Name,
-							Namespace: mg.GetNamespace(),
-						},
-					},
-				},
-			},
-		}
-	case *namespacedv1beta1.ClusterProviderConfig:
-		pcSpec = pc.Spec
-	default:
-		return nil, errors.New("unknown provider config kind")
+	pcSpec, err := ProviderConfigSpec(pcObj)
+	if err != nil {
+		return nil, err
 	}
 	t := resource.NewProviderConfigUsageTracker(crClient, &namespacedv1beta1.ProviderConfigUsage{})
 	if err := t.Track(ctx, mg); err != nil {
 		return nil, errors.Wrap(err, errTrackUsage)
 	}
-	return &pcSpec, nil
+	return pcSpec, nil
+}
+
+// ProviderConfigSpec returns the spec of any of the provider's ProviderConfig
+// kinds in the shape of a ClusterProviderConfigSpec. The Secrets of a
+// namespaced ProviderConfig are resolved in the ProviderConfig's namespace.
+func ProviderConfigSpec(pc resource.ProviderConfig) (*namespacedv1beta1.ClusterProviderConfigSpec, error) {
+	switch pc := pc.(type) {
+	case *clusterv1beta1.ProviderConfig:
+		return legacyToModernProviderConfigSpec(pc)
+	case *namespacedv1beta1.ProviderConfig:
+		return &namespacedv1beta1.ClusterProviderConfigSpec{
+			Credentials:                  localCredentials(pc.Spec.CredentialsSecretRef, pc.GetNamespace()),
+			ConnectionConfig:             *pc.Spec.ConnectionConfig.DeepCopy(),
+			PasswordSecretRef:            localSecretKeySelector(pc.Spec.PasswordSecretRef, pc.GetNamespace()),
+			ClientSecretSecretRef:        localSecretKeySelector(pc.Spec.ClientSecretSecretRef, pc.GetNamespace()),
+			TLSClientPrivateKeySecretRef: localSecretKeySelector(pc.Spec.TLSClientPrivateKeySecretRef, pc.GetNamespace()),
+			JWTSigningKeySecretRef:       localSecretKeySelector(pc.Spec.JWTSigningKeySecretRef, pc.GetNamespace()),
+			TLSSecretRef:                 localSecretReference(pc.Spec.TLSSecretRef, pc.GetNamespace()),
+			RootCAConfigMapRef:           localConfigMapKeySelector(pc.Spec.RootCAConfigMapRef, pc.GetNamespace()),
+			RateLimit:                    pc.Spec.RateLimit.DeepCopy(),
+			AllowedRealms:                slices.Clone(pc.Spec.AllowedRealms),
+			CleanupOrphanedSessions:      pc.Spec.CleanupOrphanedSessions,
+			AdoptionPolicy:               pc.Spec.AdoptionPolicy,
+		}, nil
+	case *namespacedv1beta1.ClusterProviderConfig:
+		spec := pc.Spec
+		return &spec, nil
+	default:
+		return nil, errors.New("unknown provider config kind")
+	}
 }
@@ -2,6 +2,8 @@ package clients
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"testing"
@@ -124,6 +126,110 @@ func TestExtractCredentials(t *testing.T) {
 	}
 }
 
+func TestExtractCredentialsEnvironment(t *testing.T) {
+	want := map[string]any{
+		"client_id":                "test-client",
+		"url":                      "my-keycloak.nmspc.svc.cluster.local",
+		"client_timeout":           30,
+		"tls_insecure_skip_verify": true,
+	}
+	selector := v1.CommonCredentialSelectors{Env: &v1.EnvSelector{Name: "KEYCLOAK_TEST_CREDS"}}
+
+	t.Run("extracting credentials from JSON environment variable works", func(t *testing.T) {
+		t.Setenv("KEYCLOAK_TEST_CREDS", `{"client_id": "test-client", "url": "my-keycloak.nmspc.svc.cluster.local", "client_timeout": 30, "tls_insecure_skip_verify": true}`)
+		got, err := ExtractCredentials(context.Background(), v1.CredentialsSourceEnvironment, nil, selector)
+		if err != nil {
+			t.Fatalf("ExtractCredentials() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ExtractCredentials() got = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("extracting credentials from prefixed environment variables works", func(t *testing.T) {
+		t.Setenv("KEYCLOAK_TEST_CREDS_CLIENT_ID", "test-client")
+		t.Setenv("KEYCLOAK_TEST_CREDS_URL", "my-keycloak.nmspc.svc.cluster.local")
+		t.Setenv("KEYCLOAK_TEST_CREDS_CLIENT_TIMEOUT", "30")
+		t.Setenv("KEYCLOAK_TEST_CREDS_TLS_INSECURE_SKIP_VERIFY", "true")
+		got, err := ExtractCredentials(context.Background(), v1.CredentialsSourceEnvironment, nil, selector)
+		if err != nil {
+			t.Fatalf("ExtractCredentials() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ExtractCredentials() got = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("invalid client_timeout in prefixed environment variable fails", func(t *testing.T) {
+		t.Setenv("KEYCLOAK_TEST_CREDS_CLIENT_TIMEOUT", "soon")
+		if _, err := ExtractCredentials(context.Background(), v1.CredentialsSourceEnvironment, nil, selector); err == nil {
+			t.Error("ExtractCredentials() expected error for invalid client_timeout")
+		}
+	})
+
+	t.Run("missing environment variables fail", func(t *testing.T) {
+		if _, err := ExtractCredentials(context.Background(), v1.CredentialsSourceEnvironment, nil, selector); err == nil {
+			t.Error("ExtractCredentials() expected error when no variable is set")
+		}
+	})
+}
+
+func TestExtractCredentialsFilesystem(t *testing.T) {
+	want := map[string]any{
+		"client_id":                "test-client",
+		"url":                      "my-keycloak.nmspc.svc.cluster.local",
+		"client_timeout":           30,
+		"tls_insecure_skip_verify": true,
+	}
+
+	t.Run("extracting credentials from JSON file works", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "credentials.json")
+		if err := os.WriteFile(path, []byte(`{"client_id": "test-client", "url": "my-keycloak.nmspc.svc.cluster.local", "client_timeout": 30, "tls_insecure_skip_verify": true}`), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ExtractCredentials(context.Background(), v1.CredentialsSourceFilesystem, nil, v1.CommonCredentialSelectors{Fs: &v1.FsSelector{Path: path}})
+		if err != nil {
+			t.Fatalf("ExtractCredentials() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ExtractCredentials() got = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("extracting credentials from projected volume directory works", func(t *testing.T) {
+		dir := t.TempDir()
+		files := map[string]string{
+			"client_id":                "test-client",
+			"url":                      "my-keycloak.nmspc.svc.cluster.local",
+			"client_timeout":           "30",
+			"tls_insecure_skip_verify": "true",
+			"..data":                   "ignored",
+		}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.Mkdir(filepath.Join(dir, "nested"), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ExtractCredentials(context.Background(), v1.CredentialsSourceFilesystem, nil, v1.CommonCredentialSelectors{Fs: &v1.FsSelector{Path: dir}})
+		if err != nil {
+			t.Fatalf("ExtractCredentials() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ExtractCredentials() got = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("missing path fails", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "missing")
+		if _, err := ExtractCredentials(context.Background(), v1.CredentialsSourceFilesystem, nil, v1.CommonCredentialSelectors{Fs: &v1.FsSelector{Path: path}}); err == nil {
+			t.Error("ExtractCredentials() expected error for missing path")
+		}
+	})
+}
+
 func TestValidateAndNormalizeURLAndBasePath(t *testing.T) {
 	tests := []struct {
 		name    string
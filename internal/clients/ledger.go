@@ -0,0 +1,392 @@
+/*
+Copyright 2021 Upbound Inc.
+*/
+
+package clients
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/keycloak/terraform-provider-keycloak/keycloak"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+
+	namespacedv1beta1 "github.com/crossplane-contrib/provider-keycloak/apis/namespaced/v1beta1"
+	"github.com/crossplane-contrib/provider-keycloak/internal/keycloaksession"
+)
+
+// SessionLedgerName is the name of the ConfigMap that records the Keycloak
+// sessions of the configurations that opted in to orphaned session cleanup.
+const SessionLedgerName = "provider-keycloak-sessions"
+
+const (
+	errGetSessionLedger    = "cannot get the session ledger"
+	errUpdateSessionLedger = "cannot update the session ledger"
+	errDecodeSessionLedger = "cannot decode the session ledger"
+	errFmtDeleteSession    = "cannot log out orphaned session %s"
+)
+
+// Results of the keycloak_orphaned_sessions_total metric.
+const (
+	resultLoggedOut = "logged_out"
+	resultGone      = "gone"
+	resultFailed    = "failed"
+	resultDropped   = "dropped"
+)
+
+var orphanedSessions = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "keycloak",
+	Subsystem: "orphaned_sessions",
+	Name:      "total",
+	Help:      "Number of Keycloak sessions left behind by previous provider processes that were found on startup, by result.",
+}, []string{"result"})
+
+// ledgerSession is a Keycloak session recorded in the session ledger.
+type ledgerSession struct {
+	// Instance identifies the provider process that started the session.
+	Instance string `json:"instance"`
+	// Endpoint is the Keycloak endpoint the session was started with.
+	Endpoint string `json:"endpoint"`
+	// Realm is the realm the session was started in.
+	Realm string `json:"realm,omitempty"`
+}
+
+// sessionLedger records the Keycloak sessions the provider starts for the
+// ProviderConfigs that opted in to orphaned session cleanup in a ConfigMap.
+// The sessions of each ProviderConfig are stored under its ledger key,
+// tagged with the provider process that started them, so that a later
+// process can tell the sessions of a crashed predecessor from its own and
+// from the sessions of anyone else using the same credentials, which are
+// never recorded.
+type sessionLedger struct {
+	// reader reads the ConfigMap from the API server, so that the provider
+	// does not cache every ConfigMap of the cluster.
+	reader   client.Reader
+	kube     client.Client
+	nn       types.NamespacedName
+	instance string
+
+	// mu serializes the ledger updates of this process.
+	mu sync.Mutex
+	// recorded holds the sessions this process recorded, keyed by ledger
+	// key and session ID.
+	recorded sync.Map // map[[2]string]struct{}
+}
+
+var ledger atomic.Pointer[sessionLedger]
+
+// EnableSessionLedger makes the provider record the Keycloak sessions of the
+// ProviderConfigs that opted in to orphaned session cleanup in the
+// SessionLedgerName ConfigMap of the given namespace. The ConfigMap is read
+// with reader, e.g. the API reader of the manager, and written with kube.
+// Each call tags the sessions with a new provider process identity.
+func EnableSessionLedger(reader client.Reader, kube client.Client, namespace string) {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	ledger.Store(&sessionLedger{
+		reader:   reader,
+		kube:     kube,
+		nn:       types.NamespacedName{Namespace: namespace, Name: SessionLedgerName},
+		instance: hex.EncodeToString(id),
+	})
+}
+
+// ledgerKey returns the key the sessions of the ProviderConfig of the given
+// kind, namespace and name are recorded under, e.g.
+// ProviderConfig.team-a.keycloak. The keys of a ConfigMap cannot contain
+// slashes, and a namespace cannot contain dots.
+func ledgerKey(kind, namespace, name string) string {
+	if namespace == "" {
+		return kind + "." + name
+	}
+	return kind + "." + namespace + "." + name
+}
+
+// managedLedgerKey returns the ledger key of the ProviderConfig of mg, or an
+// empty string if it has none that may opt in to orphaned session cleanup.
+func managedLedgerKey(mg resource.Managed) string {
+	m, ok := mg.(resource.ModernManaged)
+	if !ok || m.GetProviderConfigReference() == nil {
+		return ""
+	}
+	ref := m.GetProviderConfigReference()
+	switch ref.Kind {
+	case namespacedv1beta1.ProviderConfigKind:
+		return ledgerKey(ref.Kind, mg.GetNamespace(), ref.Name)
+	case namespacedv1beta1.ClusterProviderConfigKind:
+		return ledgerKey(ref.Kind, "", ref.Name)
+	default:
+		return ""
+	}
+}
+
+// ledgerProviderConfig returns an empty ProviderConfig of the kind, and the
+// name, of the given ledger key. ok is false for keys that name no
+// ProviderConfig, such as those of older provider versions.
+func ledgerProviderConfig(key string) (pc resource.ProviderConfig, nn types.NamespacedName, ok bool) {
+	kind, rest, _ := strings.Cut(key, ".")
+	switch kind {
+	case namespacedv1beta1.ClusterProviderConfigKind:
+		return &namespacedv1beta1.ClusterProviderConfig{}, types.NamespacedName{Name: rest}, rest != ""
+	case namespacedv1beta1.ProviderConfigKind:
+		ns, name, found := strings.Cut(rest, ".")
+		return &namespacedv1beta1.ProviderConfig{}, types.NamespacedName{Namespace: ns, Name: name}, found && ns != "" && name != ""
+	default:
+		return nil, types.NamespacedName{}, false
+	}
+}
+
+// read returns the sessions recorded for every ProviderConfig.
+func (l *sessionLedger) read(ctx context.Context) (map[string]map[string]ledgerSession, error) {
+	cm := &corev1.ConfigMap{}
+	if err := l.reader.Get(ctx, l.nn, cm); err != nil {
+		return nil, errors.Wrap(resource.IgnoreNotFound(err), errGetSessionLedger)
+	}
+	all := make(map[string]map[string]ledgerSession, len(cm.Data))
+	for key, raw := range cm.Data {
+		sessions := map[string]ledgerSession{}
+		if err := json.Unmarshal([]byte(raw), &sessions); err != nil {
+			return nil, errors.Wrap(err, errDecodeSessionLedger)
+		}
+		all[key] = sessions
+	}
+	return all, nil
+}
+
+// update applies f to the sessions recorded for the ProviderConfig with the
+// given ledger key. f reports whether it changed them.
+func (l *sessionLedger) update(ctx context.Context, key string, f func(map[string]ledgerSession) bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm := &corev1.ConfigMap{}
+		err := l.reader.Get(ctx, l.nn, cm)
+		create := kerrors.IsNotFound(err)
+		if err != nil && !create {
+			return errors.Wrap(err, errGetSessionLedger)
+		}
+		sessions := map[string]ledgerSession{}
+		if raw, ok := cm.Data[key]; ok {
+			if err := json.Unmarshal([]byte(raw), &sessions); err != nil {
+				return errors.Wrap(err, errDecodeSessionLedger)
+			}
+		}
+		if !f(sessions) {
+			return nil
+		}
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		if len(sessions) == 0 {
+			delete(cm.Data, key)
+		} else {
+			raw, err := json.Marshal(sessions)
+			if err != nil {
+				return err
+			}
+			cm.Data[key] = string(raw)
+		}
+		if create {
+			cm.Namespace, cm.Name = l.nn.Namespace, l.nn.Name
+			return errors.Wrap(l.kube.Create(ctx, cm), errUpdateSessionLedger)
+		}
+		return errors.Wrap(l.kube.Update(ctx, cm), errUpdateSessionLedger)
+	})
+}
+
+// record tags the session with the given ID as started by this process. It
+// is a no-op for sessions it recorded before.
+func (l *sessionLedger) record(ctx context.Context, key, endpoint, realm, id string) error {
+	if id == "" {
+		return nil
+	}
+	if _, ok := l.recorded.Load([2]string{key, id}); ok {
+		return nil
+	}
+	err := l.update(ctx, key, func(sessions map[string]ledgerSession) bool {
+		sessions[id] = ledgerSession{Instance: l.instance, Endpoint: endpoint, Realm: realm}
+		return true
+	})
+	if err == nil {
+		l.recorded.Store([2]string{key, id}, struct{}{})
+	}
+	return err
+}
+
+// forget removes the sessions with the given IDs from the ledger.
+func (l *sessionLedger) forget(ctx context.Context, key string, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	for _, id := range ids {
+		l.recorded.Delete([2]string{key, id})
+	}
+	return l.update(ctx, key, func(sessions map[string]ledgerSession) bool {
+		changed := false
+		for _, id := range ids {
+			if _, ok := sessions[id]; ok {
+				delete(sessions, id)
+				changed = true
+			}
+		}
+		return changed
+	})
+}
+
+// CleanupOrphanedSessions logs out the Keycloak sessions in the session
+// ledger that were started by previous provider processes, e.g. one that
+// crashed before it could log them out, for every ProviderConfig that opted
+// in to orphaned session cleanup. Sessions that no longer exist are dropped
+// from the ledger, and so are the sessions of ProviderConfigs that no longer
+// exist, which cannot be logged in with anymore. It returns the number of
+// sessions it logged out. It is a no-op unless EnableSessionLedger was
+// called.
+func CleanupOrphanedSessions(ctx context.Context, kube client.Client) (int, error) {
+	l := ledger.Load()
+	if l == nil {
+		return 0, nil
+	}
+	recorded, err := l.read(ctx)
+	if err != nil || len(recorded) == 0 {
+		return 0, err
+	}
+
+	keys := make([]string, 0, len(recorded))
+	for key := range recorded {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	loggedOut := 0
+	var errs []error
+	for _, key := range keys {
+		pc, nn, ok := ledgerProviderConfig(key)
+		if ok {
+			err := kube.Get(ctx, nn, pc)
+			if resource.IgnoreNotFound(err) != nil {
+				errs = append(errs, errors.Wrap(err, errGetProviderConfig))
+				continue
+			}
+			ok = err == nil
+		}
+		if !ok {
+			if err := l.drop(ctx, key, recorded[key]); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		pcSpec, err := ProviderConfigSpec(pc)
+		if err != nil || !pcSpec.CleanupOrphanedSessions {
+			continue
+		}
+		config, err := providerConfiguration(ctx, kube, pcSpec)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		n, err := l.cleanup(ctx, key, config, recorded[key])
+		loggedOut += n
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return loggedOut, errors.Join(errs...)
+}
+
+// drop forgets the sessions that other provider processes recorded under a
+// key whose ProviderConfig no longer exists.
+func (l *sessionLedger) drop(ctx context.Context, key string, recorded map[string]ledgerSession) error {
+	var ids []string
+	for id, s := range recorded {
+		if s.Instance != l.instance {
+			ids = append(ids, id)
+		}
+	}
+	orphanedSessions.WithLabelValues(resultDropped).Add(float64(len(ids)))
+	return l.forget(ctx, key, ids)
+}
+
+// cleanup logs out the sessions of a ProviderConfig that other provider
+// processes recorded and forgets them. They are logged out with the current
+// credentials of the ProviderConfig, at the endpoint and in the realm they
+// were started with.
+func (l *sessionLedger) cleanup(ctx context.Context, key string, config map[string]any, recorded map[string]ledgerSession) (int, error) {
+	byEndpoint := map[string][]string{}
+	for id, s := range recorded {
+		if s.Instance != l.instance {
+			byEndpoint[s.Endpoint] = append(byEndpoint[s.Endpoint], id)
+		}
+	}
+	if len(byEndpoint) == 0 {
+		return 0, nil
+	}
+
+	// The cleanup logs in with a session of its own, which is not recorded.
+	sessions := newConfigSessions(config)
+	defer sessions.logout(ctx)
+
+	loggedOut := 0
+	var forgotten []string
+	var errs []error
+	endpoints := make([]string, 0, len(byEndpoint))
+	for e := range byEndpoint {
+		endpoints = append(endpoints, e)
+	}
+	sort.Strings(endpoints)
+	for _, endpoint := range endpoints {
+		c, err := sessionKeycloakClient(ctx, config, sessions, endpoint, false)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		session, _ := keycloaksession.SessionOf(c)
+		for _, id := range byEndpoint[endpoint] {
+			realm := recorded[id].Realm
+			if realm == "" {
+				realm = loginRealm(config)
+			}
+			err := session.Delete(ctx, fmt.Sprintf("/realms/%s/sessions/%s", realm, id))
+			var apiErr *keycloak.ApiError
+			switch {
+			case err == nil:
+				orphanedSessions.WithLabelValues(resultLoggedOut).Inc()
+				loggedOut++
+				forgotten = append(forgotten, id)
+			case errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound:
+				orphanedSessions.WithLabelValues(resultGone).Inc()
+				forgotten = append(forgotten, id)
+			default:
+				orphanedSessions.WithLabelValues(resultFailed).Inc()
+				errs = append(errs, errors.Wrapf(err, errFmtDeleteSession, id))
+			}
+		}
+		forgetClient(c)
+	}
+	if err := l.forget(ctx, key, forgotten); err != nil {
+		errs = append(errs, err)
+	}
+	return loggedOut, errors.Join(errs...)
+}
+
+// loginRealm returns the realm the provider logs in to with config.
+func loginRealm(config map[string]any) string {
+	if realm, _ := config["realm"].(string); realm != "" {
+		return realm
+	}
+	return "master"
+}
@@ -0,0 +1,265 @@
+/*
+Copyright 2021 Upbound Inc.
+*/
+
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	namespacedv1beta1 "github.com/crossplane-contrib/provider-keycloak/apis/namespaced/v1beta1"
+)
+
+// sessionKeycloak is a Keycloak that starts a session with the given ID on
+// every login and records the sessions deleted through the admin API.
+type sessionKeycloak struct {
+	mu      sync.Mutex
+	session string
+	deleted []string
+}
+
+func (k *sessionKeycloak) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	const sessions = "/admin/realms/master/sessions/"
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/protocol/openid-connect/token"):
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  "access",
+			"refresh_token": "refresh",
+			"expires_in":    60,
+			"session_state": k.session,
+		})
+	case r.URL.Path == "/admin/serverinfo":
+		_, _ = w.Write([]byte(`{}`))
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, sessions):
+		id := strings.TrimPrefix(r.URL.Path, sessions)
+		k.deleted = append(k.deleted, id)
+		if id == "gone" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func newLedgerClient(t *testing.T, objs ...client.Object) client.Client {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := namespacedv1beta1.SchemeBuilder.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	kube := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+	EnableSessionLedger(kube, kube, "crossplane-system")
+	t.Cleanup(func() { ledger.Store(nil) })
+	return kube
+}
+
+func readLedger(t *testing.T, key string) map[string]ledgerSession {
+	t.Helper()
+	all, err := ledger.Load().read(context.Background())
+	if err != nil {
+		t.Fatalf("read() = %v", err)
+	}
+	return all[key]
+}
+
+func TestConfigSessionsLedger(t *testing.T) {
+	kc := &sessionKeycloak{session: "sess-1"}
+	srv := httptest.NewServer(kc)
+	defer srv.Close()
+	newLedgerClient(t)
+	ctx := context.Background()
+
+	config := map[string]any{"url": srv.URL, "client_id": "admin-cli", "username": "admin", "password": "admin"}
+	sessions := newConfigSessions(config)
+	c, err := sessionKeycloakClient(ctx, config, sessions, srv.URL, true)
+	if err != nil {
+		t.Fatalf("sessionKeycloakClient() = %v", err)
+	}
+	t.Cleanup(func() { forgetClient(c) })
+
+	// Sessions of configurations that did not opt in are not recorded.
+	if got := readLedger(t, "config"); len(got) != 0 {
+		t.Fatalf("ledger = %v, want no sessions before track", got)
+	}
+
+	// Tracking records the sessions that were already started.
+	sessions.track(ctx, "config")
+	want := map[string]ledgerSession{"sess-1": {Instance: ledger.Load().instance, Endpoint: srv.URL, Realm: "master"}}
+	if got := readLedger(t, "config"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ledger = %v, want %v", got, want)
+	}
+
+	// Sessions started later are recorded as their clients log in.
+	kc.mu.Lock()
+	kc.session = "sess-2"
+	kc.mu.Unlock()
+	c2, err := sessionKeycloakClient(ctx, config, sessions, srv.URL, true)
+	if err != nil {
+		t.Fatalf("sessionKeycloakClient() = %v", err)
+	}
+	t.Cleanup(func() { forgetClient(c2) })
+	want["sess-2"] = ledgerSession{Instance: ledger.Load().instance, Endpoint: srv.URL, Realm: "master"}
+	if got := readLedger(t, "config"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ledger = %v, want %v", got, want)
+	}
+
+	sessions.logout(ctx)
+	if got := readLedger(t, "config"); len(got) != 0 {
+		t.Errorf("ledger after logout = %v, want no sessions", got)
+	}
+}
+
+func TestCleanupOrphanedSessions(t *testing.T) {
+	kc := &sessionKeycloak{session: "cleanup"}
+	srv := httptest.NewServer(kc)
+	defer srv.Close()
+
+	creds, _ := json.Marshal(map[string]any{"url": srv.URL, "client_id": "admin-cli", "username": "admin", "password": "admin"})
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: "team-a"},
+		Data:       map[string][]byte{"credentials": creds},
+	}
+	pc := func(name string, cleanup bool) *namespacedv1beta1.ProviderConfig {
+		return &namespacedv1beta1.ProviderConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "team-a"},
+			Spec: namespacedv1beta1.ProviderConfigSpec{
+				CredentialsSecretRef: &namespacedv1beta1.ProviderCredentials{
+					LocalSecretKeySelector: xpv1.LocalSecretKeySelector{
+						LocalSecretReference: xpv1.LocalSecretReference{Name: "creds"},
+						Key:                  "credentials",
+					},
+				},
+				CleanupOrphanedSessions: cleanup,
+			},
+		}
+	}
+	optedIn := pc("opted-in", true)
+	kube := newLedgerClient(t, secret, optedIn)
+	ctx := context.Background()
+
+	key := ledgerKey(namespacedv1beta1.ProviderConfigKind, "team-a", "opted-in")
+	l := ledger.Load()
+	if err := l.update(ctx, key, func(s map[string]ledgerSession) bool {
+		s["orphan"] = ledgerSession{Instance: "crashed", Endpoint: srv.URL, Realm: "master"}
+		s["gone"] = ledgerSession{Instance: "crashed", Endpoint: srv.URL}
+		s["mine"] = ledgerSession{Instance: l.instance, Endpoint: srv.URL}
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := CleanupOrphanedSessions(ctx, kube)
+	if err != nil {
+		t.Fatalf("CleanupOrphanedSessions() = %v", err)
+	}
+	if n != 1 {
+		t.Errorf("CleanupOrphanedSessions() logged out %d sessions, want 1", n)
+	}
+	sort.Strings(kc.deleted)
+	if want := []string{"gone", "orphan"}; !reflect.DeepEqual(kc.deleted, want) {
+		t.Errorf("deleted sessions = %v, want %v", kc.deleted, want)
+	}
+	if got := readLedger(t, key); len(got) != 1 || got["mine"].Instance != l.instance {
+		t.Errorf("ledger = %v, want only the session of this process", got)
+	}
+
+	// ProviderConfigs that did not opt in are left alone.
+	if err := kube.Update(ctx, func() client.Object {
+		o := &namespacedv1beta1.ProviderConfig{}
+		_ = kube.Get(ctx, types.NamespacedName{Namespace: "team-a", Name: "opted-in"}, o)
+		o.Spec.CleanupOrphanedSessions = false
+		return o
+	}()); err != nil {
+		t.Fatal(err)
+	}
+	_ = l.update(ctx, key, func(s map[string]ledgerSession) bool {
+		s["orphan"] = ledgerSession{Instance: "crashed", Endpoint: srv.URL}
+		return true
+	})
+	kc.deleted = nil
+	if _, err := CleanupOrphanedSessions(ctx, kube); err != nil || len(kc.deleted) != 0 {
+		t.Errorf("CleanupOrphanedSessions() without opt-in = %v, deleted %v, want none", err, kc.deleted)
+	}
+}
+
+func TestCleanupOrphanedSessionsPrunes(t *testing.T) {
+	kc := &sessionKeycloak{}
+	srv := httptest.NewServer(kc)
+	defer srv.Close()
+	kube := newLedgerClient(t)
+	ctx := context.Background()
+
+	l := ledger.Load()
+	deleted := ledgerKey(namespacedv1beta1.ProviderConfigKind, "team-a", "deleted")
+	for _, key := range []string{deleted, "3f2a9c", ledgerKey(namespacedv1beta1.ClusterProviderConfigKind, "", "deleted")} {
+		if err := l.update(ctx, key, func(s map[string]ledgerSession) bool {
+			s["orphan"] = ledgerSession{Instance: "crashed", Endpoint: srv.URL}
+			return true
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.update(ctx, deleted, func(s map[string]ledgerSession) bool {
+		s["mine"] = ledgerSession{Instance: l.instance, Endpoint: srv.URL}
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := CleanupOrphanedSessions(ctx, kube); n != 0 || err != nil {
+		t.Fatalf("CleanupOrphanedSessions() = %d, %v, want no sessions logged out", n, err)
+	}
+	if len(kc.deleted) != 0 {
+		t.Errorf("deleted sessions = %v, want none", kc.deleted)
+	}
+	all, err := l.read(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]map[string]ledgerSession{deleted: {"mine": {Instance: l.instance, Endpoint: srv.URL}}}
+	if !reflect.DeepEqual(all, want) {
+		t.Errorf("ledger = %v, want only the session of this process", all)
+	}
+}
+
+func TestLedgerProviderConfig(t *testing.T) {
+	cases := map[string]struct {
+		key    string
+		wantNN types.NamespacedName
+		wantOK bool
+	}{
+		"ProviderConfig":        {key: "ProviderConfig.team-a.keycloak", wantNN: types.NamespacedName{Namespace: "team-a", Name: "keycloak"}, wantOK: true},
+		"ClusterProviderConfig": {key: "ClusterProviderConfig.keycloak.example", wantNN: types.NamespacedName{Name: "keycloak.example"}, wantOK: true},
+		"NoNamespace":           {key: "ProviderConfig.keycloak"},
+		"CacheKey":              {key: "3f2a9c"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, nn, ok := ledgerProviderConfig(tc.key)
+			if ok != tc.wantOK || (ok && nn != tc.wantNN) {
+				t.Errorf("ledgerProviderConfig(%q) = %v, %t, want %v, %t", tc.key, nn, ok, tc.wantNN, tc.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2021 Upbound Inc.
+*/
+
+package clients
+
+import (
+	"sync"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+
+	namespacedv1beta1 "github.com/crossplane-contrib/provider-keycloak/apis/namespaced/v1beta1"
+	"github.com/crossplane-contrib/provider-keycloak/internal/tfconcurrency"
+)
+
+// rateLimitTTL is how long the rate limit of a ProviderConfig applies after
+// a managed resource last used it, so that the limit of a deleted
+// ProviderConfig does not hold forever.
+const rateLimitTTL = time.Hour
+
+// requestedRateLimit is the rate limit a ProviderConfig asks for its
+// configuration.
+type requestedRateLimit struct {
+	key   string
+	limit *namespacedv1beta1.RateLimit
+	seen  time.Time
+}
+
+// rateLimits records the rate limit every ProviderConfig asks for. The
+// ProviderConfigs that resolve to the same configuration share one token
+// bucket, which is limited by the strictest of them.
+var rateLimits = struct {
+	sync.Mutex
+	byPC map[providerConfigRef]requestedRateLimit
+}{byPC: map[providerConfigRef]requestedRateLimit{}}
+
+// setRateLimit records the rate limit the ProviderConfig of mg asks for and
+// applies the strictest limit of the configuration's ProviderConfigs to its
+// token bucket. It is called on every reconciliation so that changes to the
+// rate limit take effect without rebuilding the clients.
+func setRateLimit(cacheKey string, mg resource.Managed, rl *namespacedv1beta1.RateLimit) {
+	ref, ok := providerConfigRefOf(mg)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	rateLimits.Lock()
+	defer rateLimits.Unlock()
+	prev, had := rateLimits.byPC[ref]
+	rateLimits.byPC[ref] = requestedRateLimit{key: cacheKey, limit: rl, seen: now}
+	if had && prev.key != cacheKey {
+		applyRateLimit(prev.key, now)
+	}
+	applyRateLimit(cacheKey, now)
+}
+
+// applyRateLimit sets the token bucket of the configuration with the given
+// key to the strictest rate limit its ProviderConfigs ask for, forgetting
+// those that were not used for rateLimitTTL. A ProviderConfig without a rate
+// limit does not lift the limit of another. rateLimits must be locked.
+func applyRateLimit(key string, now time.Time) {
+	qps, burst := 0, 0
+	for ref, r := range rateLimits.byPC {
+		if now.Sub(r.seen) > rateLimitTTL {
+			delete(rateLimits.byPC, ref)
+			continue
+		}
+		if r.key != key || r.limit == nil || r.limit.QPS <= 0 {
+			continue
+		}
+		b := r.limit.QPS
+		if r.limit.Burst != nil {
+			b = *r.limit.Burst
+		}
+		if qps == 0 || r.limit.QPS < qps {
+			qps = r.limit.QPS
+		}
+		if burst == 0 || b < burst {
+			burst = b
+		}
+	}
+	tfconcurrency.SetRateLimit(key, qps, burst)
+}
+
+// forgetRateLimits removes the token bucket of the configuration with the
+// given key and the rate limits its ProviderConfigs asked for.
+func forgetRateLimits(key string) {
+	rateLimits.Lock()
+	defer rateLimits.Unlock()
+	for ref, r := range rateLimits.byPC {
+		if r.key == key {
+			delete(rateLimits.byPC, ref)
+		}
+	}
+	tfconcurrency.RemoveRateLimit(key)
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2021 Upbound Inc.
+*/
+
+package clients
+
+import (
+	"errors"
+	"testing"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource/fake"
+	"k8s.io/utils/ptr"
+
+	namespacedv1beta1 "github.com/crossplane-contrib/provider-keycloak/apis/namespaced/v1beta1"
+	"github.com/crossplane-contrib/provider-keycloak/internal/tfconcurrency"
+)
+
+func TestSetRateLimitStrictest(t *testing.T) {
+	const key = "shared-rate-limit"
+	t.Cleanup(func() { forgetRateLimits(key) })
+	usingPC := func(name string) *fake.ModernManaged {
+		mg := &fake.ModernManaged{}
+		mg.SetProviderConfigReference(&xpv1.ProviderConfigReference{Kind: namespacedv1beta1.ClusterProviderConfigKind, Name: name})
+		return mg
+	}
+	strict, lenient, unlimited := usingPC("strict"), usingPC("lenient"), usingPC("unlimited")
+
+	// Whatever order the ProviderConfigs are used in, the strictest limit
+	// applies.
+	for i := 0; i < 2; i++ {
+		setRateLimit(key, strict, &namespacedv1beta1.RateLimit{QPS: 1})
+		setRateLimit(key, lenient, &namespacedv1beta1.RateLimit{QPS: 100, Burst: ptr.To(100)})
+		setRateLimit(key, unlimited, nil)
+	}
+	if err := tfconcurrency.Allow(key); err != nil {
+		t.Fatalf("first Allow() = %v, want nil", err)
+	}
+	if err := tfconcurrency.Allow(key); !errors.Is(err, tfconcurrency.ErrThrottled) {
+		t.Fatalf("second Allow() = %v, want the strictest limit to apply", err)
+	}
+
+	// The limit is lifted once no ProviderConfig of the configuration asks
+	// for one.
+	setRateLimit(key, strict, nil)
+	setRateLimit(key, lenient, nil)
+	for i := 0; i < 5; i++ {
+		if err := tfconcurrency.Allow(key); err != nil {
+			t.Fatalf("Allow() without limits = %v, want nil", err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2021 Upbound Inc.
+*/
+
+package clients
+
+import (
+	"context"
+	"sync"
+
+	"github.com/keycloak/terraform-provider-keycloak/keycloak"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplane-contrib/provider-keycloak/internal/tfconcurrency"
+)
+
+// credentialSecrets records which credential Secrets produced a cached
+// configuration. Several ProviderConfigs may point at different Secrets with
+// identical contents, which all resolve to the same cache entry.
+type credentialSecrets struct {
+	mu   sync.Mutex
+	refs map[types.NamespacedName]struct{}
+}
+
+func (s *credentialSecrets) add(nn types.NamespacedName) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.refs == nil {
+		s.refs = make(map[types.NamespacedName]struct{})
+	}
+	s.refs[nn] = struct{}{}
+}
+
+func (s *credentialSecrets) has(nn types.NamespacedName) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.refs[nn]
+	return ok
+}
+
+// IsTrackedSecret reports whether any cached Keycloak client was configured
+// from the given credentials Secret.
+func IsTrackedSecret(nn types.NamespacedName) bool {
+	tracked := false
+	metaCache.Range(func(_, value any) bool {
+		tracked = value.(*cachedMeta).secrets.has(nn)
+		return !tracked
+	})
+	return tracked
+}
+
+// RotateCredentials evicts every cached Keycloak client that was configured
+// from the given credentials Secret, so that the next reconciliation reads
+// the new credentials and logs in again. It returns the number of evicted
+// configurations.
+func RotateCredentials(ctx context.Context, nn types.NamespacedName) int {
+	evicted := 0
+	metaCache.Range(func(key, value any) bool {
+		entry := value.(*cachedMeta)
+		if !entry.secrets.has(nn) {
+			return true
+		}
+		// Remove the entry before draining so that new reconciliations
+		// immediately build a fresh client instead of waiting.
+		metaCache.Delete(key)
+		evictCachedMeta(ctx, key.(string), entry, reasonRotated)
+		evicted++
+		return true
+	})
+	return evicted
+}
+
+// evictCachedMeta drains and closes the pool of a cache entry that has
+// already been removed from metaCache, logs out its sessions and revokes
+// their tokens. reason labels the eviction in the evictions metric.
+func evictCachedMeta(ctx context.Context, key string, entry *cachedMeta, reason string) {
+	if entry.pool != nil {
+		tfconcurrency.UnregisterKey(key, entry.pool)
+		for _, c := range entry.pool.Close(ctx) {
+			forgetClient(c)
+		}
+		if primary, ok := entry.meta.(*keycloak.KeycloakClient); ok {
+			tfconcurrency.Unregister(primary)
+		}
+	}
+	if kcClient, ok := entry.meta.(*keycloak.KeycloakClient); ok {
+		forgetClient(kcClient)
+	}
+	entry.sessions.logout(ctx)
+	forgetRateLimits(key)
+	tfconcurrency.RemoveBreaker(key)
+	for _, ref := range entry.usage.providerConfigs() {
+		pcCacheKeys.CompareAndDelete(ref, key)
+	}
+	evictions.WithLabelValues(cacheProvider, reason).Inc()
+}
@@ -0,0 +1,114 @@
+package clients
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/keycloak/terraform-provider-keycloak/keycloak"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplane-contrib/provider-keycloak/internal/tfconcurrency"
+)
+
+func newOfflineClient(ctx context.Context) (*keycloak.KeycloakClient, error) {
+	return keycloak.NewKeycloakClient(
+		ctx,
+		"http://127.0.0.1:1", "", "", "admin-cli", "", "master",
+		"", "", "", "", "", "", "",
+		false, 5, "", true, "", "", "test", false, nil, "",
+	)
+}
+
+func storeOfflineEntry(t *testing.T, key string, secrets ...types.NamespacedName) *tfconcurrency.Pool {
+	t.Helper()
+	primary, err := newOfflineClient(context.Background())
+	if err != nil {
+		t.Fatalf("NewKeycloakClient: %v", err)
+	}
+	pool := tfconcurrency.NewPool(2, newOfflineClient)
+	pool.Seed(primary)
+	tfconcurrency.Register(primary, pool)
+	entry := &cachedMeta{meta: primary, pool: pool}
+	for _, s := range secrets {
+		entry.secrets.add(s)
+	}
+	metaCache.Store(key, entry)
+	t.Cleanup(func() { metaCache.Delete(key) })
+	return pool
+}
+
+func TestRotateCredentials(t *testing.T) {
+	rotated := types.NamespacedName{Namespace: "crossplane-system", Name: "rotated"}
+	shared := types.NamespacedName{Namespace: "team-a", Name: "shared"}
+	untouched := types.NamespacedName{Namespace: "crossplane-system", Name: "untouched"}
+
+	rotatedPool := storeOfflineEntry(t, "rotated-config", rotated, shared)
+	untouchedPool := storeOfflineEntry(t, "untouched-config", untouched)
+
+	if !IsTrackedSecret(shared) {
+		t.Fatal("expected a secret recorded on a cache entry to be tracked")
+	}
+	if IsTrackedSecret(types.NamespacedName{Namespace: "crossplane-system", Name: "unknown"}) {
+		t.Fatal("expected an unrelated secret not to be tracked")
+	}
+
+	if got := RotateCredentials(context.Background(), rotated); got != 1 {
+		t.Fatalf("RotateCredentials() evicted %d configurations, want 1", got)
+	}
+	if _, ok := metaCache.Load("rotated-config"); ok {
+		t.Fatal("expected the cache entry of the rotated secret to be evicted")
+	}
+	if _, err := rotatedPool.Borrow(context.Background()); err != tfconcurrency.ErrPoolClosed {
+		t.Fatalf("Borrow on evicted pool: got %v, want ErrPoolClosed", err)
+	}
+	if IsTrackedSecret(shared) {
+		t.Fatal("expected secrets of the evicted entry to no longer be tracked")
+	}
+
+	if _, ok := metaCache.Load("untouched-config"); !ok {
+		t.Fatal("expected the cache entry of an unrelated secret to be kept")
+	}
+	c, err := untouchedPool.Borrow(context.Background())
+	if err != nil {
+		t.Fatalf("Borrow on untouched pool: %v", err)
+	}
+	untouchedPool.Return(c)
+}
+
+func TestRotateIdentityTokens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("token-1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	creds, err := extractInjectedIdentityCredentials(xpv1.CommonCredentialSelectors{Fs: &xpv1.FsSelector{Path: path}})
+	if err != nil {
+		t.Fatalf("extractInjectedIdentityCredentials() error = %v", err)
+	}
+	if creds["jwt_token"] != "token-1" {
+		t.Fatalf("jwt_token = %v, want token-1", creds["jwt_token"])
+	}
+
+	pool := storeOfflineEntry(t, "identity-config")
+	v, _ := metaCache.Load("identity-config")
+	v.(*cachedMeta).identity = identityToken{path: path, sum: tokenSum([]byte(creds["jwt_token"].(string)))}
+
+	if got := RotateIdentityTokens(context.Background()); got != 0 {
+		t.Fatalf("RotateIdentityTokens() before rotation evicted %d configurations, want 0", got)
+	}
+
+	if err := os.WriteFile(path, []byte("token-2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got := RotateIdentityTokens(context.Background()); got != 1 {
+		t.Fatalf("RotateIdentityTokens() after rotation evicted %d configurations, want 1", got)
+	}
+	if _, ok := metaCache.Load("identity-config"); ok {
+		t.Fatal("expected the cache entry of the rotated token to be evicted")
+	}
+	if _, err := pool.Borrow(context.Background()); err != tfconcurrency.ErrPoolClosed {
+		t.Fatalf("Borrow on evicted pool: got %v, want ErrPoolClosed", err)
+	}
+}
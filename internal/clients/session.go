@@ -0,0 +1,193 @@
+/*
+Copyright 2021 Upbound Inc.
+*/
+
+package clients
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/keycloak/terraform-provider-keycloak/keycloak"
+
+	"github.com/crossplane-contrib/provider-keycloak/internal/keycloaksession"
+)
+
+// logoutTimeout bounds the logout of a client that is retired from its pool.
+const logoutTimeout = 30 * time.Second
+
+// clientSession is the session of a Keycloak client of a configuration and
+// the endpoint the client connects to.
+type clientSession struct {
+	session  *keycloaksession.Session
+	endpoint string
+}
+
+// configSessions are the sessions of the Keycloak clients of a
+// configuration. Every client logs in on its own, with the login path of the
+// Keycloak Terraform client; its session records the tokens it obtains.
+type configSessions struct {
+	config map[string]any
+
+	// ledgerKey is the key of the ProviderConfig the sessions are recorded
+	// under in the session ledger, if it opted in to orphaned session
+	// cleanup.
+	ledgerKey atomic.Pointer[string]
+
+	mu       sync.Mutex
+	byClient map[*keycloak.KeycloakClient]clientSession
+}
+
+func newConfigSessions(config map[string]any) *configSessions {
+	return &configSessions{config: config, byClient: make(map[*keycloak.KeycloakClient]clientSession)}
+}
+
+// add instruments c, a client of the configuration that was configured with
+// epConfig, the configuration of the endpoint it connects to, and has not
+// logged in yet. It returns the session of c. The Keycloak sessions c starts
+// are recorded in the session ledger.
+func (s *configSessions) add(c *keycloak.KeycloakClient, epConfig map[string]any) (*keycloaksession.Session, error) {
+	endpoint, _ := epConfig["url"].(string)
+	var last atomic.Pointer[string]
+	session, err := keycloaksession.Instrument(c, epConfig, func(ctx context.Context, t keycloaksession.Tokens) {
+		if prev := last.Swap(&t.SessionID); prev != nil && *prev != t.SessionID {
+			s.forget(ctx, *prev)
+		}
+		s.record(ctx, endpoint, t.SessionID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.byClient[c] = clientSession{session: session, endpoint: endpoint}
+	s.mu.Unlock()
+	return session, nil
+}
+
+// track records the sessions of the configuration in the session ledger
+// under the given ledger key, including those that are already started.
+func (s *configSessions) track(ctx context.Context, key string) {
+	l := ledger.Load()
+	if l == nil || key == "" || s.ledgerKey.Swap(&key) != nil {
+		return
+	}
+	s.mu.Lock()
+	started := make([]clientSession, 0, len(s.byClient))
+	for _, cs := range s.byClient {
+		started = append(started, cs)
+	}
+	s.mu.Unlock()
+	for _, cs := range started {
+		if id := cs.session.SessionID(); id != "" {
+			_ = l.record(ctx, key, cs.endpoint, loginRealm(s.config), id)
+		}
+	}
+}
+
+// record records the session with the given endpoint in the session ledger,
+// if the configuration opted in to orphaned session cleanup. The ledger is
+// best effort: a session that could not be recorded is merely not cleaned up
+// should the provider crash.
+func (s *configSessions) record(ctx context.Context, endpoint, id string) {
+	key := s.ledgerKey.Load()
+	if l := ledger.Load(); l != nil && key != nil && id != "" {
+		_ = l.record(ctx, *key, endpoint, loginRealm(s.config), id)
+	}
+}
+
+// forget removes the sessions with the given IDs from the session ledger.
+func (s *configSessions) forget(ctx context.Context, ids ...string) {
+	if l, key := ledger.Load(), s.ledgerKey.Load(); l != nil && key != nil && len(ids) > 0 {
+		_ = l.forget(ctx, *key, ids)
+	}
+}
+
+// logoutClient logs out the session of a client of the configuration that is
+// discarded, revokes its tokens and removes it from the session ledger.
+func (s *configSessions) logoutClient(ctx context.Context, c *keycloak.KeycloakClient) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	cs, ok := s.byClient[c]
+	delete(s.byClient, c)
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+	id := cs.session.SessionID()
+	cs.session.Logout(ctx)
+	s.forget(ctx, id)
+}
+
+// retire logs out the session of a client that was retired from its pool in
+// the background, since the pool retires clients while it is in use.
+func (s *configSessions) retire(c *keycloak.KeycloakClient) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), logoutTimeout)
+		defer cancel()
+		s.logoutClient(ctx, c)
+	}()
+}
+
+// logout logs out every session of the configuration, revokes its tokens and
+// removes it from the session ledger.
+func (s *configSessions) logout(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	sessions := make([]*keycloaksession.Session, 0, len(s.byClient))
+	for _, cs := range s.byClient {
+		sessions = append(sessions, cs.session)
+	}
+	clear(s.byClient)
+	s.mu.Unlock()
+	ids := make([]string, 0, len(sessions))
+	for _, session := range sessions {
+		if id := session.SessionID(); id != "" {
+			ids = append(ids, id)
+		}
+		session.Logout(ctx)
+	}
+	s.forget(ctx, ids...)
+}
+
+// sessionKeycloakClient configures a client for a single endpoint of the
+// configuration and instruments it. The client logs in with its own login
+// path: right away if login is set, otherwise on its first request.
+func sessionKeycloakClient(ctx context.Context, config map[string]any, sessions *configSessions, endpoint string, login bool) (*keycloak.KeycloakClient, error) {
+	epConfig := make(map[string]any, len(config))
+	for k, v := range config {
+		epConfig[k] = v
+	}
+	epConfig["url"] = endpoint
+	// The client must not log in before it is instrumented, or its first
+	// tokens go unnoticed.
+	epConfig["initial_login"] = false
+	c, err := configureKeycloakClient(ctx, epConfig)
+	if err != nil {
+		return nil, err
+	}
+	session, err := sessions.add(c, epConfig)
+	if err != nil {
+		return nil, err
+	}
+	if login {
+		if err := session.Login(ctx); err != nil {
+			forgetClient(c)
+			sessions.logoutClient(ctx, c)
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// initialLogin reports whether the clients of a configuration log in as soon
+// as they are configured, the default of the Terraform provider.
+func initialLogin(config map[string]any) bool {
+	login, ok := config["initial_login"].(bool)
+	return login || !ok
+}
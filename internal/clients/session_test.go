@@ -0,0 +1,56 @@
+/*
+Copyright 2021 Upbound Inc.
+*/
+
+package clients
+
+import (
+	"context"
+	"testing"
+
+	"github.com/crossplane-contrib/provider-keycloak/internal/keycloaksession"
+)
+
+func TestInitialLogin(t *testing.T) {
+	cases := map[string]struct {
+		config map[string]any
+		want   bool
+	}{
+		"Default":  {config: map[string]any{}, want: true},
+		"Enabled":  {config: map[string]any{"initial_login": true}, want: true},
+		"Disabled": {config: map[string]any{"initial_login": false}},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := initialLogin(tc.config); got != tc.want {
+				t.Errorf("initialLogin() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestConfigSessionsLogoutClient(t *testing.T) {
+	ctx := context.Background()
+	c, err := newOfflineClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := map[string]any{"url": "http://127.0.0.1:1"}
+	sessions := newConfigSessions(config)
+	if _, err := sessions.add(c, config); err != nil {
+		t.Fatalf("add() = %v", err)
+	}
+	t.Cleanup(func() { forgetClient(c) })
+	if _, ok := keycloaksession.SessionOf(c); !ok {
+		t.Fatal("add() did not instrument the client")
+	}
+
+	sessions.logoutClient(ctx, c)
+	if len(sessions.byClient) != 0 {
+		t.Errorf("sessions after logoutClient() = %v, want none", sessions.byClient)
+	}
+	forgetClient(c)
+	if _, ok := keycloaksession.SessionOf(c); ok {
+		t.Error("forgetClient() did not drop the session binding")
+	}
+}
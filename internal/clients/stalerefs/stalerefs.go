@@ -10,20 +10,25 @@
 //
 // MaybeRecover detects this state from the Synced condition and clears every
 // reference-resolved value field on spec.forProvider (and spec.initProvider),
-// so that the next reconcile re-resolves them with the new UUIDs.
+// so that the next reconcile re-resolves them with the new UUIDs. Every
+// recovery is reported as a Kubernetes Event on the managed resource and
+// counted per kind.
 package stalerefs
 
 import (
 	"context"
+	"fmt"
 	"reflect"
 	"strconv"
 	"strings"
 
+	"github.com/prometheus/client_golang/prometheus"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/event"
 	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
 )
 
@@ -33,6 +38,35 @@ import (
 // bumps the spec, recovery becomes eligible again.
 const RecoveryAtGenerationAnnotation = "provider-keycloak.crossplane.io/stale-ref-recovery-at-generation"
 
+// DisableRecoveryAnnotation opts a resource out of stale-reference recovery
+// when set to "true". Its reference-resolved fields are then never cleared
+// automatically, and a stale UUID has to be fixed by hand.
+const DisableRecoveryAnnotation = "provider-keycloak.crossplane.io/disable-stale-ref-recovery"
+
+// reasonRecoveredStaleReferences is the reason of the Event MaybeRecover
+// records on a resource whose stale references it cleared.
+const reasonRecoveredStaleReferences event.Reason = "RecoveredStaleReferences"
+
+var recoveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "keycloak",
+	Subsystem: "stale_reference",
+	Name:      "recoveries_total",
+	Help:      "Number of times stale reference-resolved fields were cleared on a managed resource, by kind.",
+}, []string{"kind"})
+
+// Collectors returns the Prometheus collectors of stale-reference recovery.
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{recoveries}
+}
+
+// ClearedField is a reference-resolved value field cleared by MaybeRecover.
+type ClearedField struct {
+	// Path is the field path, e.g. spec.forProvider.roleId.
+	Path string
+	// Values are the stale values the field held.
+	Values []string
+}
+
 // Managed is the minimum subset of resource.Managed that MaybeRecover needs.
 // Narrowing the interface keeps the package independently testable.
 type Managed interface {
@@ -43,10 +77,15 @@ type Managed interface {
 // MaybeRecover inspects mg's Synced condition. If it signals a stale-reference
 // 404 AND the resource was previously created in Keycloak, MaybeRecover clears
 // every value field on mg.Spec.ForProvider (and mg.Spec.InitProvider) whose
-// sibling XRef/XRefs/XSelector is non-nil, then persists the update via kube.
-// It returns true when a clearing was attempted (regardless of whether the
+// sibling XRef/XRefs/XSelector is non-nil, then persists the update via kube
+// and records an Event listing the cleared fields and their stale values.
+// Resources annotated with DisableRecoveryAnnotation are never cleared. It
+// returns true when a clearing was attempted (regardless of whether the
 // update raced with another writer).
-func MaybeRecover(ctx context.Context, kube client.Client, mg Managed) (bool, error) {
+func MaybeRecover(ctx context.Context, kube client.Client, record event.Recorder, mg Managed) (bool, error) {
+	if recoveryDisabled(mg) {
+		return false, nil
+	}
 	if !isStaleRefCondition(mg.GetCondition(xpv1.TypeSynced)) {
 		return false, nil
 	}
@@ -56,7 +95,8 @@ func MaybeRecover(ctx context.Context, kube client.Client, mg Managed) (bool, er
 	if alreadyRecoveredForCurrentGeneration(mg) {
 		return false, nil
 	}
-	if !clearResolvedRefs(mg) {
+	cleared := clearResolvedRefs(mg)
+	if len(cleared) == 0 {
 		return false, nil
 	}
 	setRecoveryAnnotation(mg)
@@ -66,9 +106,48 @@ func MaybeRecover(ctx context.Context, kube client.Client, mg Managed) (bool, er
 		}
 		return false, err
 	}
+	recoveries.WithLabelValues(kindOf(mg)).Inc()
+	record.Event(mg, event.Normal(reasonRecoveredStaleReferences, recoveryMessage(cleared)))
 	return true, nil
 }
 
+// recoveryDisabled reports whether mg opted out of stale-reference recovery.
+func recoveryDisabled(mg Managed) bool {
+	return mg.GetAnnotations()[DisableRecoveryAnnotation] == "true"
+}
+
+// kindOf returns the kind of mg. Typed objects read through a cache often
+// have an empty TypeMeta, in which case the Go type name, which upjet names
+// after the kind, is used instead.
+func kindOf(mg Managed) string {
+	if k := mg.GetObjectKind().GroupVersionKind().Kind; k != "" {
+		return k
+	}
+	t := reflect.TypeOf(mg)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+// recoveryMessage describes the cleared fields, e.g. "Cleared stale
+// references so that they are resolved again: spec.forProvider.roleId (was
+// 1b4e...)".
+func recoveryMessage(cleared []ClearedField) string {
+	return "Cleared stale references so that they are resolved again: " + DescribeCleared(cleared)
+}
+
+// DescribeCleared lists the cleared fields and the values they held, e.g.
+// "spec.forProvider.roleId (was 1b4e...); spec.forProvider.groupIds (was
+// 5c1a..., 9f0e...)".
+func DescribeCleared(cleared []ClearedField) string {
+	fields := make([]string, 0, len(cleared))
+	for _, f := range cleared {
+		fields = append(fields, fmt.Sprintf("%s (was %s)", f.Path, strings.Join(f.Values, ", ")))
+	}
+	return strings.Join(fields, "; ")
+}
+
 // wasCreatedExternally reports whether the managed resource has ever
 // successfully been created in Keycloak, as recorded by crossplane-runtime
 // via the external-create-succeeded annotation. A resource that has never
@@ -122,46 +201,54 @@ func isStaleRefCondition(c xpv1.Condition) bool {
 
 // clearResolvedRefs walks Spec.ForProvider and Spec.InitProvider; for each
 // suffix-paired field (X / XRef, X / XRefs, X / XSelector) whose ref or
-// selector sibling is non-nil, it zeroes the value field X. Returns true
-// if at least one value was cleared.
-func clearResolvedRefs(mg Managed) bool {
+// selector sibling is non-nil, it zeroes the value field X. It returns the
+// cleared fields.
+func clearResolvedRefs(mg Managed) []ClearedField {
 	v := reflect.ValueOf(mg)
 	for v.Kind() == reflect.Pointer {
 		v = v.Elem()
 	}
 	if v.Kind() != reflect.Struct {
-		return false
+		return nil
 	}
-	spec := v.FieldByName("Spec")
-	if !spec.IsValid() || spec.Kind() != reflect.Struct {
-		return false
+	spec, ok := v.Type().FieldByName("Spec")
+	if !ok || spec.Type.Kind() != reflect.Struct {
+		return nil
 	}
-	cleared := false
+	var cleared []ClearedField
 	for _, name := range []string{"ForProvider", "InitProvider"} {
-		params := spec.FieldByName(name)
-		if !params.IsValid() {
+		f, ok := spec.Type.FieldByName(name)
+		if !ok {
 			continue
 		}
-		if clearRefsInStruct(params) {
-			cleared = true
-		}
+		prefix := JSONName(spec) + "." + JSONName(f)
+		cleared = append(cleared, clearRefsInStruct(prefix, v.FieldByIndex(spec.Index).FieldByIndex(f.Index))...)
 	}
 	return cleared
 }
 
+// JSONName returns the JSON name of a struct field, falling back to the
+// lower camel case Go name for fields without a JSON tag.
+func JSONName(f reflect.StructField) string {
+	if n, _, _ := strings.Cut(f.Tag.Get("json"), ","); n != "" && n != "-" {
+		return n
+	}
+	return strings.ToLower(f.Name[:1]) + f.Name[1:]
+}
+
 // refSuffixes are the field-name suffixes that mark a field as a reference
 // or selector sibling. Order matters: "Refs" must precede "Ref" so the
 // suffix match doesn't truncate "Refs" to "Ref" and look up the wrong value
 // field name.
 var refSuffixes = []string{"Selector", "Refs", "Ref"}
 
-func clearRefsInStruct(params reflect.Value) bool {
+func clearRefsInStruct(prefix string, params reflect.Value) []ClearedField {
 	if params.Kind() != reflect.Struct {
-		return false
+		return nil
 	}
 	t := params.Type()
 	clearedValues := map[string]bool{}
-	cleared := false
+	var cleared []ClearedField
 	for i := 0; i < params.NumField(); i++ {
 		fname := t.Field(i).Name
 		valueName, ok := valueFieldName(fname)
@@ -174,17 +261,41 @@ func clearRefsInStruct(params reflect.Value) bool {
 		if isNilOrEmpty(params.Field(i)) {
 			continue
 		}
-		value := params.FieldByName(valueName)
-		if !value.IsValid() || !value.CanSet() || isNilOrEmpty(value) {
+		vf, ok := t.FieldByName(valueName)
+		if !ok {
 			continue
 		}
+		value := params.FieldByIndex(vf.Index)
+		if !value.CanSet() || isNilOrEmpty(value) {
+			continue
+		}
+		cleared = append(cleared, ClearedField{Path: prefix + "." + JSONName(vf), Values: StringValues(value)})
 		value.Set(reflect.Zero(value.Type()))
 		clearedValues[valueName] = true
-		cleared = true
 	}
 	return cleared
 }
 
+// StringValues returns the values held by a reference-resolved value field,
+// which is a string, a slice of strings or a pointer to either.
+func StringValues(v reflect.Value) []string {
+	switch v.Kind() { //nolint:exhaustive // only the kinds upjet uses for ref-paired fields matter
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return StringValues(v.Elem())
+	case reflect.Slice:
+		var out []string
+		for i := 0; i < v.Len(); i++ {
+			out = append(out, StringValues(v.Index(i))...)
+		}
+		return out
+	default:
+		return []string{fmt.Sprint(v.Interface())}
+	}
+}
+
 // valueFieldName returns the value-field name a given ref/selector field
 // resolves into, e.g. "ClientIDRef" -> "ClientID", "RoleIdsRefs" -> "RoleIds",
 // "ClientIDSelector" -> "ClientID". Returns ("", false) if the name isn't a
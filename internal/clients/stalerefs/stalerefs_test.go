@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -15,6 +16,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/event"
 	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
 	"github.com/crossplane/crossplane-runtime/v2/pkg/test"
 )
@@ -265,7 +267,7 @@ func TestClearResolvedRefs(t *testing.T) {
 	}
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			got := clearResolvedRefs(tc.mg)
+			got := len(clearResolvedRefs(tc.mg)) > 0
 			if got != tc.wantCleared {
 				t.Fatalf("clearResolvedRefs() = %v, want %v", got, tc.wantCleared)
 			}
@@ -303,7 +305,7 @@ func TestMaybeRecover(t *testing.T) {
 				return nil
 			},
 		}
-		recovered, err := MaybeRecover(context.Background(), kube, mg)
+		recovered, err := MaybeRecover(context.Background(), kube, event.NewNopRecorder(), mg)
 		if err != nil || recovered {
 			t.Fatalf("MaybeRecover() = (%v,%v), want (false,nil)", recovered, err)
 		}
@@ -332,7 +334,7 @@ func TestMaybeRecover(t *testing.T) {
 				return nil
 			},
 		}
-		recovered, err := MaybeRecover(context.Background(), kube, mg)
+		recovered, err := MaybeRecover(context.Background(), kube, event.NewNopRecorder(), mg)
 		if err != nil || !recovered {
 			t.Fatalf("MaybeRecover() = (%v,%v), want (true,nil)", recovered, err)
 		}
@@ -360,7 +362,7 @@ func TestMaybeRecover(t *testing.T) {
 				return nil
 			},
 		}
-		recovered, err := MaybeRecover(context.Background(), kube, mg)
+		recovered, err := MaybeRecover(context.Background(), kube, event.NewNopRecorder(), mg)
 		if err != nil || recovered {
 			t.Fatalf("MaybeRecover() = (%v,%v), want (false,nil)", recovered, err)
 		}
@@ -384,7 +386,7 @@ func TestMaybeRecover(t *testing.T) {
 				return apierrors.NewConflict(schema.GroupResource{Group: "x", Resource: "y"}, "name", errors.New("stale"))
 			},
 		}
-		recovered, err := MaybeRecover(context.Background(), kube, mg)
+		recovered, err := MaybeRecover(context.Background(), kube, event.NewNopRecorder(), mg)
 		if err != nil {
 			t.Fatalf("MaybeRecover() returned unexpected error: %v", err)
 		}
@@ -416,7 +418,7 @@ func TestMaybeRecover(t *testing.T) {
 				return nil
 			},
 		}
-		recovered, err := MaybeRecover(context.Background(), kube, mg)
+		recovered, err := MaybeRecover(context.Background(), kube, event.NewNopRecorder(), mg)
 		if err != nil || recovered {
 			t.Fatalf("MaybeRecover() = (%v,%v), want (false,nil) — already recovered this gen", recovered, err)
 		}
@@ -451,7 +453,7 @@ func TestMaybeRecover(t *testing.T) {
 				return nil
 			},
 		}
-		recovered, err := MaybeRecover(context.Background(), kube, mg)
+		recovered, err := MaybeRecover(context.Background(), kube, event.NewNopRecorder(), mg)
 		if err != nil || !recovered {
 			t.Fatalf("MaybeRecover() = (%v,%v), want (true,nil)", recovered, err)
 		}
@@ -479,7 +481,7 @@ func TestMaybeRecover(t *testing.T) {
 		kube := &test.MockClient{
 			MockUpdate: func(_ context.Context, _ client.Object, _ ...client.UpdateOption) error { return nil },
 		}
-		recovered, err := MaybeRecover(context.Background(), kube, mg)
+		recovered, err := MaybeRecover(context.Background(), kube, event.NewNopRecorder(), mg)
 		if err != nil || !recovered {
 			t.Fatalf("MaybeRecover() = (%v,%v), want (true,nil) — malformed annotation should not block recovery", recovered, err)
 		}
@@ -508,7 +510,7 @@ func TestMaybeRecover(t *testing.T) {
 				return nil
 			},
 		}
-		recovered, err := MaybeRecover(context.Background(), kube, mg)
+		recovered, err := MaybeRecover(context.Background(), kube, event.NewNopRecorder(), mg)
 		if err != nil || !recovered {
 			t.Fatalf("MaybeRecover() = (%v,%v), want (true,nil)", recovered, err)
 		}
@@ -539,7 +541,7 @@ func TestMaybeRecover(t *testing.T) {
 				return nil
 			},
 		}
-		recovered, err := MaybeRecover(context.Background(), kube, mg)
+		recovered, err := MaybeRecover(context.Background(), kube, event.NewNopRecorder(), mg)
 		if err != nil || recovered {
 			t.Fatalf("MaybeRecover() = (%v,%v), want (false,nil) — cold-start 404 must not trigger clearing", recovered, err)
 		}
@@ -570,7 +572,7 @@ func TestMaybeRecover(t *testing.T) {
 				return wantErr
 			},
 		}
-		recovered, err := MaybeRecover(context.Background(), kube, mg)
+		recovered, err := MaybeRecover(context.Background(), kube, event.NewNopRecorder(), mg)
 		if !errors.Is(err, wantErr) {
 			t.Fatalf("MaybeRecover() err = %v, want %v", err, wantErr)
 		}
@@ -580,6 +582,80 @@ func TestMaybeRecover(t *testing.T) {
 	})
 }
 
+// eventRecorder records the events it is given.
+type eventRecorder struct {
+	events []event.Event
+}
+
+func (r *eventRecorder) Event(_ runtime.Object, e event.Event) { r.events = append(r.events, e) }
+
+func (r *eventRecorder) WithAnnotations(...string) event.Recorder { return r }
+
+func TestMaybeRecoverEvents(t *testing.T) {
+	staleCond := xpv1.Condition{
+		Type:    xpv1.TypeSynced,
+		Status:  corev1.ConditionFalse,
+		Message: "observe failed: keycloak.ApiError: 404 not found",
+	}
+	kube := &test.MockClient{
+		MockUpdate: func(_ context.Context, _ client.Object, _ ...client.UpdateOption) error { return nil },
+	}
+
+	t.Run("recovery records an event and counts it", func(t *testing.T) {
+		mg := &fakeManaged{
+			ObjectMeta: createdExternally(),
+			Spec: fakeSpec{ForProvider: fakeParams{
+				ClientID:    ptr("stale-client"),
+				ClientIDRef: &xpv1.Reference{Name: "c"},
+				RoleIds:     []*string{ptr("stale-a"), ptr("stale-b")},
+				RoleIdsRefs: []xpv1.Reference{{Name: "a"}, {Name: "b"}},
+			}},
+		}
+		mg.SetConditions(staleCond)
+		before := testutil.ToFloat64(recoveries.WithLabelValues("fakeManaged"))
+
+		record := &eventRecorder{}
+		if recovered, err := MaybeRecover(context.Background(), kube, record, mg); err != nil || !recovered {
+			t.Fatalf("MaybeRecover() = (%v,%v), want (true,nil)", recovered, err)
+		}
+		if len(record.events) != 1 {
+			t.Fatalf("recorded %d events, want 1", len(record.events))
+		}
+		e := record.events[0]
+		want := "Cleared stale references so that they are resolved again: spec.forProvider.clientID (was stale-client); spec.forProvider.roleIds (was stale-a, stale-b)"
+		if e.Type != event.TypeNormal || e.Reason != reasonRecoveredStaleReferences || e.Message != want {
+			t.Errorf("event = %+v, want a %s event with message %q", e, reasonRecoveredStaleReferences, want)
+		}
+		if got := testutil.ToFloat64(recoveries.WithLabelValues("fakeManaged")) - before; got != 1 {
+			t.Errorf("recoveries of fakeManaged increased by %v, want 1", got)
+		}
+	})
+
+	t.Run("opted out resource is left alone", func(t *testing.T) {
+		om := createdExternally()
+		om.Annotations[DisableRecoveryAnnotation] = "true"
+		mg := &fakeManaged{
+			ObjectMeta: om,
+			Spec: fakeSpec{ForProvider: fakeParams{
+				ClientID:    ptr("stale-client"),
+				ClientIDRef: &xpv1.Reference{Name: "c"},
+			}},
+		}
+		mg.SetConditions(staleCond)
+
+		record := &eventRecorder{}
+		if recovered, err := MaybeRecover(context.Background(), kube, record, mg); err != nil || recovered {
+			t.Fatalf("MaybeRecover() = (%v,%v), want (false,nil)", recovered, err)
+		}
+		if mg.Spec.ForProvider.ClientID == nil {
+			t.Error("ClientID cleared despite the opt-out annotation")
+		}
+		if len(record.events) != 0 {
+			t.Errorf("recorded events %v, want none", record.events)
+		}
+	})
+}
+
 // Compile-time check: ensure fakeManaged satisfies the narrow Managed
 // interface used by MaybeRecover.
 var _ Managed = (*fakeManaged)(nil)
@@ -0,0 +1,138 @@
+/*
+Copyright 2021 Upbound Inc.
+*/
+
+// Package conditions lets the errors of this provider set a specific reason on
+// the Synced condition of a managed resource, instead of the generic
+// ReconcileError the managed resource reconciler uses for every error, and
+// lets it report conditions of its own.
+package conditions
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+)
+
+// reasons holds every reason an error was created with by WithReason.
+var reasons = struct {
+	sync.RWMutex
+	known map[xpv1.ConditionReason]struct{}
+}{known: map[xpv1.ConditionReason]struct{}{}}
+
+// WithReason returns err prefixed with the given reason. When a managed
+// resource's reconciliation fails with the error, its Synced condition
+// carries the reason instead of ReconcileError, provided its status is
+// written through a client returned by WrapClient.
+func WithReason(reason xpv1.ConditionReason, err error) error {
+	reasons.RLock()
+	_, ok := reasons.known[reason]
+	reasons.RUnlock()
+	if !ok {
+		reasons.Lock()
+		reasons.known[reason] = struct{}{}
+		reasons.Unlock()
+	}
+	return errors.Wrap(err, string(reason))
+}
+
+// WrapManager returns a manager whose client is wrapped by WrapClient. The
+// managed resource reconcilers write the status of managed resources with the
+// client of the manager they are set up with.
+func WrapManager(mgr manager.Manager) manager.Manager {
+	return &reasonManager{Manager: mgr, client: WrapClient(mgr.GetClient())}
+}
+
+type reasonManager struct {
+	manager.Manager
+	client client.Client
+}
+
+func (m *reasonManager) GetClient() client.Client {
+	return m.client
+}
+
+// WrapClient returns a client whose status writer replaces the ReconcileError
+// reason of a Synced=False condition by the reason of the error in its
+// message, see WithReason, and sets the reported conditions, see Report.
+func WrapClient(c client.Client) client.Client {
+	return &reasonClient{Client: c}
+}
+
+type reasonClient struct {
+	client.Client
+}
+
+func (c *reasonClient) Status() client.SubResourceWriter {
+	return &reasonStatusWriter{SubResourceWriter: c.Client.Status()}
+}
+
+type reasonStatusWriter struct {
+	client.SubResourceWriter
+}
+
+func (w *reasonStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	setReason(obj)
+	conds := setReported(obj)
+	err := w.SubResourceWriter.Update(ctx, obj, opts...)
+	if err != nil {
+		restoreReported(obj, conds)
+	}
+	return err
+}
+
+func (w *reasonStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	setReason(obj)
+	conds := setReported(obj)
+	err := w.SubResourceWriter.Patch(ctx, obj, patch, opts...)
+	if err != nil {
+		restoreReported(obj, conds)
+	}
+	return err
+}
+
+func setReason(obj client.Object) {
+	c, ok := obj.(resource.Conditioned)
+	if !ok {
+		return
+	}
+	cond := c.GetCondition(xpv1.TypeSynced)
+	if cond.Status != corev1.ConditionFalse || cond.Reason != xpv1.ReasonReconcileError {
+		return
+	}
+	if reason, ok := reasonOf(cond.Message); ok {
+		cond.Reason = reason
+		c.SetConditions(cond)
+	}
+}
+
+// reasonOf returns the reason of the outermost error in msg that was created
+// by WithReason, i.e. the one closest to the start of msg. A reason only
+// matches at the start of msg or of one of the errors it wraps, so that a
+// reason is never taken for the tail of a longer one, and the first match is
+// the same whatever the order the reasons were registered in.
+func reasonOf(msg string) (xpv1.ConditionReason, bool) {
+	reasons.RLock()
+	defer reasons.RUnlock()
+	for i := 0; i < len(msg); {
+		for reason := range reasons.known {
+			if strings.HasPrefix(msg[i:], string(reason)+": ") {
+				return reason, true
+			}
+		}
+		next := strings.Index(msg[i:], ": ")
+		if next < 0 {
+			break
+		}
+		i += next + len(": ")
+	}
+	return "", false
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2021 Upbound Inc.
+*/
+
+package conditions
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/test"
+)
+
+func TestStatusUpdateSetsReason(t *testing.T) {
+	const (
+		reasonTest   xpv1.ConditionReason = "TestReason"
+		reasonOuter  xpv1.ConditionReason = "OuterReason"
+		reasonLonger xpv1.ConditionReason = "LongerTestReason"
+	)
+	errTagged := errors.Wrap(WithReason(reasonTest, errors.New("boom")), "connect failed")
+
+	cases := map[string]struct {
+		cond xpv1.Condition
+		want xpv1.ConditionReason
+	}{
+		"TaggedError":   {cond: xpv1.ReconcileError(errTagged), want: reasonTest},
+		"UntaggedError": {cond: xpv1.ReconcileError(errors.New("connect failed: boom")), want: xpv1.ReasonReconcileError},
+		"Success":       {cond: xpv1.ReconcileSuccess(), want: xpv1.ReasonReconcileSuccess},
+		"OutermostReason": {
+			cond: xpv1.ReconcileError(errors.Wrap(WithReason(reasonOuter, WithReason(reasonTest, errors.New("boom"))), "connect failed")),
+			want: reasonOuter,
+		},
+		"ReasonIsNotTheTailOfAnother": {
+			cond: xpv1.ReconcileError(errors.Wrap(WithReason(reasonLonger, errors.New("boom")), "connect failed")),
+			want: reasonLonger,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			kube := WrapClient(&test.MockClient{
+				MockStatusUpdate: test.NewMockSubResourceUpdateFn(nil),
+			})
+			mg := &fake.Managed{}
+			mg.SetConditions(tc.cond)
+			if err := kube.Status().Update(context.Background(), mg); err != nil {
+				t.Fatal(err)
+			}
+			if got := mg.GetCondition(xpv1.TypeSynced).Reason; got != tc.want {
+				t.Errorf("reason: got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStatusUpdateSetsReportedConditions(t *testing.T) {
+	const typeTest xpv1.ConditionType = "Test"
+	reported := func(reason xpv1.ConditionReason) xpv1.Condition {
+		return xpv1.Condition{Type: typeTest, Status: corev1.ConditionTrue, Reason: reason}
+	}
+	updateErr := errors.New("conflict")
+	var fail bool
+	kube := WrapClient(&test.MockClient{
+		MockStatusUpdate: func(context.Context, client.Object, ...client.SubResourceUpdateOption) error {
+			if fail {
+				return updateErr
+			}
+			return nil
+		},
+	})
+	mg := &fake.Managed{}
+	mg.SetUID("uid")
+	Report("uid", reported("First"))
+	Report("uid", reported("Second"))
+	Report("other", reported("Other"))
+
+	fail = true
+	if err := kube.Status().Update(context.Background(), mg); !errors.Is(err, updateErr) {
+		t.Fatalf("Update() = %v", err)
+	}
+	fail = false
+	if err := kube.Status().Update(context.Background(), &fake.Managed{}); err != nil {
+		t.Fatal(err)
+	}
+	mg = &fake.Managed{}
+	mg.SetUID("uid")
+	if err := kube.Status().Update(context.Background(), mg); err != nil {
+		t.Fatal(err)
+	}
+	if got := mg.GetCondition(typeTest).Reason; got != "Second" {
+		t.Errorf("reason = %q, want the last reported one to survive a failed update", got)
+	}
+
+	// Reported conditions are set once.
+	mg = &fake.Managed{}
+	mg.SetUID("uid")
+	if err := kube.Status().Update(context.Background(), mg); err != nil {
+		t.Fatal(err)
+	}
+	if got := mg.GetCondition(typeTest).Reason; got != "" {
+		t.Errorf("reason = %q, want no condition after it was written", got)
+	}
+}
+
+func TestReportedConditionsExpire(t *testing.T) {
+	reported.Lock()
+	reported.conditions["deleted"] = reportedConditions{
+		conditions: []xpv1.Condition{{Type: "Test", Status: corev1.ConditionTrue}},
+		at:         time.Now().Add(-reportTTL),
+	}
+	reported.pruned = time.Time{}
+	reported.Unlock()
+
+	Report("live", xpv1.Condition{Type: "Test", Status: corev1.ConditionTrue})
+
+	reported.Lock()
+	defer reported.Unlock()
+	if _, ok := reported.conditions["deleted"]; ok {
+		t.Error("conditions of a resource whose status was never written: want them forgotten after reportTTL")
+	}
+	if _, ok := reported.conditions["live"]; !ok {
+		t.Error("conditions reported just now: want them kept")
+	}
+	delete(reported.conditions, "live")
+}
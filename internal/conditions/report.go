@@ -0,0 +1,105 @@
+/*
+Copyright 2021 Upbound Inc.
+*/
+
+package conditions
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+)
+
+// reportTTL is how long the conditions reported for a managed resource are
+// kept if its status is not written, e.g. because it was deleted first.
+const reportTTL = 15 * time.Minute
+
+// reported holds the conditions reported for managed resources until their
+// status is written, or for reportTTL after they were last reported.
+var reported = struct {
+	sync.Mutex
+	conditions map[types.UID]reportedConditions
+	pruned     time.Time
+}{conditions: map[types.UID]reportedConditions{}}
+
+type reportedConditions struct {
+	conditions []xpv1.Condition
+	at         time.Time
+}
+
+// Report sets the given condition on the managed resource with the given UID
+// when its status is next written through a client returned by WrapClient. It
+// surfaces the outcome of code that has no access to the managed resource,
+// such as the lookup of its external name. A later report of the same
+// condition type replaces an earlier one.
+func Report(uid types.UID, c xpv1.Condition) {
+	if uid == "" {
+		return
+	}
+	reported.Lock()
+	defer reported.Unlock()
+	now := time.Now()
+	pruneReported(now)
+	conds := reported.conditions[uid].conditions
+	if i := slices.IndexFunc(conds, func(r xpv1.Condition) bool { return r.Type == c.Type }); i >= 0 {
+		conds[i] = c
+	} else {
+		conds = append(conds, c)
+	}
+	reported.conditions[uid] = reportedConditions{conditions: conds, at: now}
+}
+
+// pruneReported forgets the conditions reported longer than reportTTL ago.
+// It scans them at most once per reportTTL.
+func pruneReported(now time.Time) {
+	if now.Sub(reported.pruned) < reportTTL {
+		return
+	}
+	reported.pruned = now
+	for uid, r := range reported.conditions {
+		if now.Sub(r.at) >= reportTTL {
+			delete(reported.conditions, uid)
+		}
+	}
+}
+
+// setReported sets the conditions reported for obj on it, and forgets them.
+// It returns them, so that they can be restored if the status cannot be
+// written.
+func setReported(obj client.Object) []xpv1.Condition {
+	c, ok := obj.(resource.Conditioned)
+	if !ok {
+		return nil
+	}
+	reported.Lock()
+	defer reported.Unlock()
+	conds := reported.conditions[obj.GetUID()].conditions
+	delete(reported.conditions, obj.GetUID())
+	c.SetConditions(conds...)
+	return conds
+}
+
+// restoreReported reports the given conditions of obj again, unless they were
+// reported again in the meantime.
+func restoreReported(obj client.Object, conds []xpv1.Condition) {
+	reported.Lock()
+	defer reported.Unlock()
+	current := reported.conditions[obj.GetUID()]
+	for _, c := range conds {
+		if !slices.ContainsFunc(current.conditions, func(r xpv1.Condition) bool { return r.Type == c.Type }) {
+			current.conditions = append(current.conditions, c)
+		}
+	}
+	if len(current.conditions) > 0 {
+		if current.at.IsZero() {
+			current.at = time.Now()
+		}
+		reported.conditions[obj.GetUID()] = current
+	}
+}
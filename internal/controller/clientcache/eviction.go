@@ -0,0 +1,52 @@
+/*
+Copyright 2021 Upbound Inc.
+*/
+
+// Package clientcache evicts cached Keycloak clients that are no longer
+// needed.
+package clientcache
+
+import (
+	"context"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/crossplane/upjet/v2/pkg/controller"
+
+	"github.com/crossplane-contrib/provider-keycloak/internal/clients"
+)
+
+const (
+	// sweepInterval is how often the cached Keycloak clients are checked for
+	// eviction.
+	sweepInterval = time.Minute
+
+	// sweepTimeout bounds a single sweep, including the logout of evicted
+	// sessions.
+	sweepTimeout = 2 * time.Minute
+)
+
+// Setup adds a runnable that periodically logs out and evicts the cached
+// Keycloak clients that outlived their TTL, were idle for too long or whose
+// ProviderConfigs were deleted, and shrinks pools that are not fully used.
+func Setup(mgr ctrl.Manager, o controller.Options, eo clients.EvictionOptions) error {
+	log := o.Logger.WithValues("controller", "client-cache-eviction")
+	kube := mgr.GetClient()
+	return mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		log.Debug("Starting", "ttl", eo.TTL.String(), "idle-timeout", eo.IdleTimeout.String())
+		t := time.NewTicker(sweepInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-t.C:
+				sctx, cancel := context.WithTimeout(ctx, sweepTimeout)
+				clients.EvictStale(sctx, kube, eo)
+				cancel()
+			}
+		}
+	}))
+}
@@ -14,11 +14,19 @@ import (
 	"github.com/crossplane/upjet/v2/pkg/controller"
 
 	"github.com/crossplane-contrib/provider-keycloak/apis/cluster/v1beta1"
+	"github.com/crossplane-contrib/provider-keycloak/internal/controller/health"
 )
 
 // Setup adds a controller that reconciles ProviderConfigs by accounting for
-// their current usage.
+// their current usage, and a controller that reports their health.
 func Setup(mgr ctrl.Manager, o controller.Options) error {
+	if err := setupUsage(mgr, o); err != nil {
+		return err
+	}
+	return health.Setup(mgr, o, v1beta1.ProviderConfigGroupKind, func() resource.ProviderConfig { return &v1beta1.ProviderConfig{} }, healthStatus, setHealthStatus)
+}
+
+func setupUsage(mgr ctrl.Manager, o controller.Options) error {
 	name := providerconfig.ControllerName(v1beta1.ProviderConfigGroupKind)
 
 	of := resource.ProviderConfigKinds{
@@ -37,6 +45,28 @@ func Setup(mgr ctrl.Manager, o controller.Options) error {
 			providerconfig.WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))))) //nolint:staticcheck // event.NewAPIRecorder only accepts the deprecated record.EventRecorder
 }
 
+func healthStatus(pc resource.ProviderConfig) health.Status {
+	s := pc.(*v1beta1.ProviderConfig).Status
+	return health.Status{
+		ServerVersion:  s.ServerVersion,
+		ActiveEndpoint: s.ActiveEndpoint,
+		Realm:          s.Realm,
+		TokenExpiry:    s.TokenExpiry,
+		LastCheckTime:  s.LastCheckTime,
+		LastError:      s.LastError,
+	}
+}
+
+func setHealthStatus(pc resource.ProviderConfig, st health.Status) {
+	s := &pc.(*v1beta1.ProviderConfig).Status
+	s.ServerVersion = st.ServerVersion
+	s.ActiveEndpoint = st.ActiveEndpoint
+	s.Realm = st.Realm
+	s.TokenExpiry = st.TokenExpiry
+	s.LastCheckTime = st.LastCheckTime
+	s.LastError = st.LastError
+}
+
 // SetupWebhookWithManager registers the conversion webhook for the
 // ProviderConfig kind.
 func SetupWebhookWithManager(mgr ctrl.Manager) error {
@@ -0,0 +1,45 @@
+/*
+Copyright 2021 Upbound Inc.
+*/
+
+package credentials
+
+import (
+	"context"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/logging"
+
+	"github.com/crossplane-contrib/provider-keycloak/internal/clients"
+)
+
+// identityPollInterval is how often projected ServiceAccount tokens are
+// checked for rotation. Kubelet rotates a token once 80% of its lifetime has
+// passed, so the old token stays valid for much longer than this.
+const identityPollInterval = 30 * time.Second
+
+// setupIdentityRotation adds a runnable that evicts the cached Keycloak
+// clients of InjectedIdentity ProviderConfigs whenever kubelet rotates the
+// projected token they logged in with.
+func setupIdentityRotation(mgr ctrl.Manager, log logging.Logger) error {
+	log = log.WithValues("controller", "identity-rotation")
+	return mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		t := time.NewTicker(identityPollInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-t.C:
+				rctx, cancel := context.WithTimeout(ctx, rotationTimeout)
+				if n := clients.RotateIdentityTokens(rctx); n > 0 {
+					log.Info("Rotated projected service account token", "configurations", n)
+				}
+				cancel()
+			}
+		}
+	}))
+}
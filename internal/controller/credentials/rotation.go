@@ -0,0 +1,100 @@
+/*
+Copyright 2021 Upbound Inc.
+*/
+
+// Package credentials rotates cached Keycloak clients when the credentials
+// Secret or the projected ServiceAccount token of their ProviderConfig
+// changes.
+package credentials
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpevent "github.com/crossplane/crossplane-runtime/v2/pkg/event"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/logging"
+	"github.com/crossplane/upjet/v2/pkg/controller"
+
+	"github.com/crossplane-contrib/provider-keycloak/internal/clients"
+)
+
+const (
+	name = "credentials-rotation"
+
+	// rotationTimeout bounds how long a rotation waits for in-flight
+	// operations on the old clients before logging their sessions out.
+	rotationTimeout = 2 * time.Minute
+
+	reasonCredentialsRotated xpevent.Reason = "RotatedCredentials"
+)
+
+// Setup adds a controller that watches the credentials Secrets referenced by
+// ProviderConfigs and evicts the cached Keycloak clients built from a Secret
+// whenever it is updated or deleted. Only Secret metadata is cached, so the
+// contents of unrelated Secrets are never held in memory. It also adds a
+// runnable that does the same for rotated projected ServiceAccount tokens.
+func Setup(mgr ctrl.Manager, o controller.Options) error {
+	if err := setupIdentityRotation(mgr, o.Logger); err != nil {
+		return err
+	}
+	r := &reconciler{
+		log:    o.Logger.WithValues("controller", name),
+		record: xpevent.NewAPIRecorder(mgr.GetEventRecorderFor(name)), //nolint:staticcheck // event.NewAPIRecorder only accepts the deprecated record.EventRecorder
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		WithOptions(o.ForControllerRuntime()).
+		For(&corev1.Secret{}, builder.OnlyMetadata, builder.WithPredicates(trackedSecretChanged())).
+		Complete(r)
+}
+
+// trackedSecretChanged passes updates and deletions of Secrets that a cached
+// Keycloak client was configured from. Creations are ignored: they are replayed
+// for every existing Secret when the informer starts.
+func trackedSecretChanged() predicate.Funcs {
+	tracked := func(o metav1.Object) bool {
+		return clients.IsTrackedSecret(types.NamespacedName{Namespace: o.GetNamespace(), Name: o.GetName()})
+	}
+	return predicate.Funcs{
+		CreateFunc: func(event.CreateEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return e.ObjectOld.GetResourceVersion() != e.ObjectNew.GetResourceVersion() && tracked(e.ObjectNew)
+		},
+		DeleteFunc:  func(e event.DeleteEvent) bool { return tracked(e.Object) },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+	}
+}
+
+type reconciler struct {
+	log    logging.Logger
+	record xpevent.Recorder
+}
+
+func (r *reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, rotationTimeout)
+	defer cancel()
+
+	n := clients.RotateCredentials(ctx, req.NamespacedName)
+	if n == 0 {
+		return reconcile.Result{}, nil
+	}
+	r.log.Info("Rotated Keycloak credentials", "secret", req.NamespacedName.String(), "configurations", n)
+
+	s := &metav1.PartialObjectMetadata{}
+	s.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Secret"))
+	s.SetNamespace(req.Namespace)
+	s.SetName(req.Name)
+	r.record.Event(s, xpevent.Normal(reasonCredentialsRotated,
+		fmt.Sprintf("Credentials changed, logged out and evicted %d cached Keycloak client pool(s)", n)))
+	return reconcile.Result{}, nil
+}
@@ -0,0 +1,332 @@
+/*
+Copyright 2021 Upbound Inc.
+*/
+
+// Package dependents requeues the managed resources that reference a managed
+// resource whose Keycloak object was recreated, so that their references are
+// resolved again right away instead of after they failed with a 404.
+package dependents
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	xpevent "github.com/crossplane/crossplane-runtime/v2/pkg/event"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+	"github.com/crossplane/upjet/v2/pkg/controller"
+
+	"github.com/crossplane-contrib/provider-keycloak/internal/clients/stalerefs"
+	"github.com/crossplane-contrib/provider-keycloak/internal/resilience"
+)
+
+const (
+	errListDependents  = "cannot list dependents"
+	errUpdateDependent = "cannot update dependent"
+	errNewTarget       = "cannot create an object of the referenced kind"
+
+	reasonReferenceRecreated xpevent.Reason = "ReferencedResourceRecreated"
+)
+
+// dependentKind is a managed resource kind that references a kind watched by
+// a reconciler.
+type dependentKind struct {
+	gvk  schema.GroupVersionKind
+	refs []reference
+}
+
+// Setup adds a controller for every managed resource kind of o.Provider that
+// is referenced by another kind. When the external name or the
+// status.atProvider.id of a referenced managed resource changes, e.g. because
+// its Keycloak object was deleted and recreated out-of-band, the controller
+// clears the values its dependents resolved from it, which requeues them and
+// makes them resolve their references again. The dependents of a resource are
+// looked up through a field index built from the cross-resource reference
+// configuration of the provider. Only the dependents of the given kinds are
+// indexed; a nil selector selects all kinds. The controllers are registered
+// with o.Gate, which decides whether and when the controller of a referenced
+// kind is set up. Kinds whose CRDs are not installed when their controller is
+// set up are skipped.
+func Setup(mgr ctrl.Manager, o controller.Options, kinds *resilience.KindSelector) error {
+	log := o.Logger.WithValues("controller", "dependents")
+	byTarget := map[schema.GroupVersionKind][]dependentKind{}
+	for gvk, refs := range references(o.Provider) {
+		if !kinds.Selects(gvk.GroupKind()) {
+			continue
+		}
+		for _, t := range targets(refs) {
+			byTarget[t] = append(byTarget[t], dependentKind{gvk: gvk, refs: refs})
+		}
+	}
+
+	idx := &indexer{mgr: mgr, log: log, indexed: map[schema.GroupVersionKind]bool{}}
+	for target, deps := range byTarget {
+		sort.Slice(deps, func(i, j int) bool { return deps[i].gvk.String() < deps[j].gvk.String() })
+		setup := func() {
+			if err := setupTarget(mgr, o, log, idx, target, deps); err != nil {
+				log.Info("Cannot set up a dependents controller, the dependents of the kind are not requeued proactively", "gvk", target.String(), "error", err)
+			}
+		}
+		if o.Gate == nil {
+			setup()
+			continue
+		}
+		o.Gate.Register(setup, target)
+	}
+	return nil
+}
+
+// setupTarget adds the controller that clears the values the given dependents
+// resolved from recreated resources of the target kind.
+func setupTarget(mgr ctrl.Manager, o controller.Options, log logging.Logger, idx *indexer, target schema.GroupVersionKind, deps []dependentKind) error {
+	obj, err := newObject(mgr, target)
+	if err != nil {
+		return errors.Wrap(err, errNewTarget)
+	}
+	if _, err := mgr.GetRESTMapper().RESTMapping(target.GroupKind(), target.Version); err != nil {
+		log.Debug("Not watching a kind whose CRD is not installed", "gvk", target.String(), "error", err)
+		return nil
+	}
+	indexed := make([]dependentKind, 0, len(deps))
+	for _, dk := range deps {
+		if idx.index(dk) {
+			indexed = append(indexed, dk)
+		}
+	}
+	if len(indexed) == 0 {
+		return nil
+	}
+	name := "dependents/" + strings.ToLower(target.GroupKind().String())
+	r := &reconciler{
+		kube:       mgr.GetClient(),
+		scheme:     mgr.GetScheme(),
+		log:        log.WithValues("gvk", target.String()),
+		record:     xpevent.NewAPIRecorder(mgr.GetEventRecorderFor(name)), //nolint:staticcheck // event.NewAPIRecorder only accepts the deprecated record.EventRecorder
+		target:     target,
+		dependents: indexed,
+	}
+	if err := ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		WithOptions(o.ForControllerRuntime()).
+		Watches(obj, r.identityChanges()).
+		Complete(r); err != nil {
+		return errors.Wrapf(err, "cannot set up the dependents controller of %s", target)
+	}
+	return nil
+}
+
+// indexer indexes the references of the dependent kinds, each once, as the
+// controllers of the kinds they reference are set up.
+type indexer struct {
+	mgr ctrl.Manager
+	log logging.Logger
+
+	mu      sync.Mutex
+	indexed map[schema.GroupVersionKind]bool
+}
+
+// index indexes the references of dk, and reports whether they are indexed.
+func (i *indexer) index(dk dependentKind) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.indexed[dk.gvk] {
+		return true
+	}
+	obj, err := newObject(i.mgr, dk.gvk)
+	if err != nil {
+		return false
+	}
+	refs := dk.refs
+	if err := i.mgr.GetFieldIndexer().IndexField(context.Background(), obj, referenceIndex, func(o client.Object) []string {
+		return referenceKeys(o, o.GetNamespace(), refs)
+	}); err != nil {
+		i.log.Debug("Cannot index the references of a kind, its dependents are not requeued proactively", "gvk", dk.gvk.String(), "error", err)
+		return false
+	}
+	i.indexed[dk.gvk] = true
+	return true
+}
+
+// newObject returns a new object of the given kind.
+func newObject(mgr ctrl.Manager, gvk schema.GroupVersionKind) (client.Object, error) {
+	o, err := mgr.GetScheme().New(gvk)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := o.(client.Object)
+	if !ok {
+		return nil, errors.Errorf("%s is not an object", gvk)
+	}
+	return obj, nil
+}
+
+// targets returns the kinds referenced by refs.
+func targets(refs []reference) []schema.GroupVersionKind {
+	seen := map[schema.GroupVersionKind]bool{}
+	var out []schema.GroupVersionKind
+	for _, r := range refs {
+		if !seen[r.target] {
+			seen[r.target] = true
+			out = append(out, r.target)
+		}
+	}
+	return out
+}
+
+type reconciler struct {
+	kube       client.Client
+	scheme     *runtime.Scheme
+	log        logging.Logger
+	record     xpevent.Recorder
+	target     schema.GroupVersionKind
+	dependents []dependentKind
+
+	// stale holds the identities the watched resources had before they
+	// changed, until their dependents were updated.
+	mu    sync.Mutex
+	stale map[types.NamespacedName]map[string]bool
+}
+
+// identity returns the external name and the status.atProvider.id of a
+// managed resource, the values its dependents usually resolve from it.
+func identity(o client.Object) []string {
+	ids := []string{meta.GetExternalName(o)}
+	if t, ok := o.(interface {
+		GetObservation() (map[string]any, error)
+	}); ok {
+		if obs, err := t.GetObservation(); err == nil {
+			id, _ := obs["id"].(string)
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// identityChanges enqueues the watched resources whose external name or
+// status.atProvider.id changed from one value to another, and remembers the
+// previous values as stale.
+func (r *reconciler) identityChanges() handler.EventHandler {
+	return handler.Funcs{
+		UpdateFunc: func(_ context.Context, e event.UpdateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			previous, current := identity(e.ObjectOld), identity(e.ObjectNew)
+			var stale []string
+			for i := range previous {
+				if previous[i] != "" && current[i] != "" && previous[i] != current[i] {
+					stale = append(stale, previous[i])
+				}
+			}
+			if len(stale) == 0 {
+				return
+			}
+			nn := types.NamespacedName{Namespace: e.ObjectNew.GetNamespace(), Name: e.ObjectNew.GetName()}
+			r.remember(nn, stale...)
+			q.Add(reconcile.Request{NamespacedName: nn})
+		},
+	}
+}
+
+// remember records stale identities of the named resource.
+func (r *reconciler) remember(nn types.NamespacedName, ids ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stale == nil {
+		r.stale = map[types.NamespacedName]map[string]bool{}
+	}
+	if r.stale[nn] == nil {
+		r.stale[nn] = map[string]bool{}
+	}
+	for _, id := range ids {
+		r.stale[nn][id] = true
+	}
+}
+
+// take returns and forgets the stale identities of the named resource.
+func (r *reconciler) take(nn types.NamespacedName) map[string]bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stale := r.stale[nn]
+	delete(r.stale, nn)
+	return stale
+}
+
+func (r *reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	stale := r.take(req.NamespacedName)
+	if len(stale) == 0 {
+		return reconcile.Result{}, nil
+	}
+	err := r.clearDependents(ctx, req.NamespacedName, stale)
+	if err != nil {
+		// Dependents that were already updated no longer hold the stale
+		// values, so they are skipped when the request is retried.
+		ids := make([]string, 0, len(stale))
+		for id := range stale {
+			ids = append(ids, id)
+		}
+		r.remember(req.NamespacedName, ids...)
+	}
+	return reconcile.Result{}, err
+}
+
+// clearDependents clears the stale values the dependents of the named
+// resource resolved from it.
+func (r *reconciler) clearDependents(ctx context.Context, nn types.NamespacedName, stale map[string]bool) error {
+	key := referenceKey(r.target.GroupKind(), nn)
+	for _, dk := range r.dependents {
+		l, err := r.scheme.New(dk.gvk.GroupVersion().WithKind(dk.gvk.Kind + "List"))
+		if err != nil {
+			return errors.Wrap(err, errListDependents)
+		}
+		list, ok := l.(client.ObjectList)
+		if !ok {
+			return errors.Errorf("%s is not a list", dk.gvk)
+		}
+		if err := r.kube.List(ctx, list, client.MatchingFields{referenceIndex: key}); err != nil {
+			return errors.Wrap(err, errListDependents)
+		}
+		items, err := apimeta.ExtractList(list)
+		if err != nil {
+			return errors.Wrap(err, errListDependents)
+		}
+		for _, item := range items {
+			mg, ok := item.(client.Object)
+			if !ok || mg.GetAnnotations()[stalerefs.DisableRecoveryAnnotation] == "true" {
+				continue
+			}
+			cleared := clearStale(mg, mg.GetNamespace(), dk.refs, r.target.GroupKind(), nn, stale)
+			if len(cleared) == 0 {
+				continue
+			}
+			if err := r.kube.Update(ctx, mg); err != nil {
+				return errors.Wrap(err, errUpdateDependent)
+			}
+			r.log.Debug("Cleared references to a recreated resource", "dependent", dk.gvk.Kind, "namespace", mg.GetNamespace(), "name", mg.GetName())
+			r.record.Event(mg, xpevent.Normal(reasonReferenceRecreated, recreatedMessage(r.target.Kind, nn, cleared)))
+		}
+	}
+	return nil
+}
+
+// recreatedMessage describes the fields cleared because the named resource of
+// the given kind was recreated.
+func recreatedMessage(kind string, nn types.NamespacedName, cleared []stalerefs.ClearedField) string {
+	name := nn.Name
+	if nn.Namespace != "" {
+		name = nn.String()
+	}
+	return fmt.Sprintf("Referenced %s %s was recreated, cleared the values resolved from it so that they are resolved again: %s", kind, name, stalerefs.DescribeCleared(cleared))
+}
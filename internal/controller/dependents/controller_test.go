@@ -0,0 +1,216 @@
+/*
+Copyright 2021 Upbound Inc.
+*/
+
+package dependents
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	xpevent "github.com/crossplane/crossplane-runtime/v2/pkg/event"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+
+	apisNamespaced "github.com/crossplane-contrib/provider-keycloak/apis/namespaced"
+	rolev1alpha1 "github.com/crossplane-contrib/provider-keycloak/apis/namespaced/role/v1alpha1"
+	userv1alpha1 "github.com/crossplane-contrib/provider-keycloak/apis/namespaced/user/v1alpha1"
+	"github.com/crossplane-contrib/provider-keycloak/config"
+	"github.com/crossplane-contrib/provider-keycloak/internal/clients/stalerefs"
+)
+
+func newScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+	s := runtime.NewScheme()
+	if err := apisNamespaced.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func namespacedReferences(t *testing.T) map[schema.GroupVersionKind][]reference {
+	t.Helper()
+	p, err := config.GetProviderNamespaced(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return references(p)
+}
+
+// TestReferencesResolve checks that every configured reference names a kind
+// of the scheme and a value field with a reference field in its parameters,
+// i.e. that the dependents of every referenced kind can be found.
+func TestReferencesResolve(t *testing.T) {
+	s := newScheme(t)
+	refs := namespacedReferences(t)
+	if len(refs) == 0 {
+		t.Fatal("references() = none")
+	}
+	for gvk, rs := range refs {
+		o, err := s.New(gvk)
+		if err != nil {
+			t.Errorf("%s: %v", gvk, err)
+			continue
+		}
+		spec, _ := reflect.TypeOf(o).Elem().FieldByName("Spec")
+		params, _ := spec.Type.FieldByName("ForProvider")
+		for _, ref := range rs {
+			if !s.Recognizes(ref.target) {
+				t.Errorf("%s: %s references unknown kind %s", gvk.Kind, strings.Join(ref.path, "."), ref.target)
+			}
+			if !resolvesInType(params.Type, ref) {
+				t.Errorf("%s: no reference field for %s", gvk.Kind, strings.Join(ref.path, "."))
+			}
+		}
+	}
+}
+
+func resolvesInType(t reflect.Type, ref reference) bool {
+	for _, seg := range ref.path[:len(ref.path)-1] {
+		f, ok := fieldByTFName(t, seg)
+		if !ok {
+			return false
+		}
+		t = f.Type
+		for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice {
+			t = t.Elem()
+		}
+	}
+	vf, ok := fieldByTFName(t, ref.path[len(ref.path)-1])
+	if !ok {
+		return false
+	}
+	name := ref.refField
+	if name == "" {
+		name = vf.Name + "Ref"
+		if vf.Type.Kind() == reflect.Slice {
+			name += "s"
+		}
+	}
+	_, ok = t.FieldByName(name)
+	return ok
+}
+
+// eventRecorder records the events it is given.
+type eventRecorder struct {
+	events []xpevent.Event
+}
+
+func (r *eventRecorder) Event(_ runtime.Object, e xpevent.Event) { r.events = append(r.events, e) }
+
+func (r *eventRecorder) WithAnnotations(...string) xpevent.Recorder { return r }
+
+func ptr(s string) *string { return &s }
+
+func userRoles(name string, roles ...string) *userv1alpha1.Roles {
+	ur := &userv1alpha1.Roles{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: name}}
+	ur.Spec.ForProvider.UserID = ptr("user")
+	ur.Spec.ForProvider.UserIDRef = &xpv1.NamespacedReference{Name: "user"}
+	for _, r := range roles {
+		ur.Spec.ForProvider.RoleIds = append(ur.Spec.ForProvider.RoleIds, ptr("id-of-"+r))
+		ur.Spec.ForProvider.RoleIdsRefs = append(ur.Spec.ForProvider.RoleIdsRefs, xpv1.NamespacedReference{Name: r})
+	}
+	return ur
+}
+
+func TestReconcileClearsStaleDependents(t *testing.T) {
+	refs := namespacedReferences(t)[userv1alpha1.Roles_GroupVersionKind]
+	mapped := userRoles("mapped", "admin", "viewer")
+	optedOut := userRoles("opted-out", "admin")
+	optedOut.SetAnnotations(map[string]string{stalerefs.DisableRecoveryAnnotation: "true"})
+	unrelated := userRoles("unrelated", "viewer")
+	kube := fake.NewClientBuilder().
+		WithScheme(newScheme(t)).
+		WithObjects(mapped, optedOut, unrelated).
+		WithIndex(&userv1alpha1.Roles{}, referenceIndex, func(o client.Object) []string {
+			return referenceKeys(o, o.GetNamespace(), refs)
+		}).
+		Build()
+	record := &eventRecorder{}
+	r := &reconciler{
+		kube:       kube,
+		scheme:     kube.Scheme(),
+		log:        logging.NewNopLogger(),
+		record:     record,
+		target:     rolev1alpha1.Role_GroupVersionKind,
+		dependents: []dependentKind{{gvk: userv1alpha1.Roles_GroupVersionKind, refs: refs}},
+	}
+	admin := types.NamespacedName{Namespace: "team-a", Name: "admin"}
+	r.remember(admin, "id-of-admin")
+
+	if _, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: admin}); err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+	get := func(name string) *userv1alpha1.Roles {
+		ur := &userv1alpha1.Roles{}
+		if err := kube.Get(context.Background(), types.NamespacedName{Namespace: "team-a", Name: name}, ur); err != nil {
+			t.Fatal(err)
+		}
+		return ur
+	}
+	if got := get("mapped").Spec.ForProvider; got.RoleIds != nil || got.UserID == nil {
+		t.Errorf("mapped: roleIds = %v, userId = %v, want only the roles cleared", got.RoleIds, got.UserID)
+	}
+	if got := get("opted-out").Spec.ForProvider; got.RoleIds == nil {
+		t.Error("opted-out: roleIds cleared despite the opt-out annotation")
+	}
+	if got := get("unrelated").Spec.ForProvider; got.RoleIds == nil {
+		t.Error("unrelated: roleIds of a resource not referencing the role cleared")
+	}
+	want := "Referenced Role team-a/admin was recreated, cleared the values resolved from it so that they are resolved again: spec.forProvider.roleIds (was id-of-admin, id-of-viewer)"
+	if len(record.events) != 1 || record.events[0].Reason != reasonReferenceRecreated || record.events[0].Message != want {
+		t.Errorf("events = %+v, want one with message %q", record.events, want)
+	}
+
+	// Without stale identities there is nothing to do.
+	if _, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: admin}); err != nil || len(record.events) != 1 {
+		t.Errorf("second Reconcile() = %v, events %d, want no new event", err, len(record.events))
+	}
+}
+
+func TestIdentityChanges(t *testing.T) {
+	role := func(externalName string) *rolev1alpha1.Role {
+		r := &rolev1alpha1.Role{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "admin"}}
+		if externalName != "" {
+			meta.SetExternalName(r, externalName)
+		}
+		return r
+	}
+	cases := map[string]struct {
+		old, new  string
+		wantStale bool
+	}{
+		"Recreated":         {old: "id-1", new: "id-2", wantStale: true},
+		"Unchanged":         {old: "id-1", new: "id-1"},
+		"FirstCreated":      {old: "", new: "id-1"},
+		"ExternalNameUnset": {old: "id-1", new: ""},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := &reconciler{}
+			q := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+			defer q.ShutDown()
+			r.identityChanges().Update(context.Background(), event.UpdateEvent{ObjectOld: role(tc.old), ObjectNew: role(tc.new)}, q)
+			if got := q.Len() == 1; got != tc.wantStale {
+				t.Errorf("enqueued = %v, want %v", got, tc.wantStale)
+			}
+			stale := r.take(types.NamespacedName{Namespace: "team-a", Name: "admin"})
+			if tc.wantStale && !stale[tc.old] {
+				t.Errorf("stale = %v, want %q", stale, tc.old)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+/*
+Copyright 2021 Upbound Inc.
+*/
+
+package dependents
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	ujconfig "github.com/crossplane/upjet/v2/pkg/config"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplane-contrib/provider-keycloak/internal/clients/stalerefs"
+)
+
+// referenceIndex is the field index from a managed resource to the managed
+// resources it references, see referenceKey.
+const referenceIndex = "dependents.keycloak.crossplane.io/references"
+
+// reference is a cross-resource reference of a managed resource kind, as
+// configured for upjet.
+type reference struct {
+	// path is the Terraform path of the referencing value field, e.g.
+	// ["role_id"] or ["role", "id"].
+	path []string
+	// refField is the Go name of the reference field, or empty for the
+	// default <value field>Ref or <value field>Refs.
+	refField string
+	// target is the referenced kind.
+	target schema.GroupVersionKind
+}
+
+// references returns the references of every managed resource kind of p,
+// keyed by the referencing kind. References whose target is not a resource of
+// p are ignored.
+func references(p *ujconfig.Provider) map[schema.GroupVersionKind][]reference {
+	gvk := func(r *ujconfig.Resource) schema.GroupVersionKind {
+		group := p.RootGroup
+		if r.ShortGroup != "" {
+			group = strings.ToLower(r.ShortGroup) + "." + p.RootGroup
+		}
+		return schema.GroupVersionKind{Group: group, Version: r.Version, Kind: r.Kind}
+	}
+	refs := map[schema.GroupVersionKind][]reference{}
+	for _, r := range p.Resources {
+		for path, ref := range r.References {
+			target, ok := p.Resources[ref.TerraformName]
+			if !ok {
+				continue
+			}
+			refs[gvk(r)] = append(refs[gvk(r)], reference{
+				path:     strings.Split(path, "."),
+				refField: ref.RefFieldName,
+				target:   gvk(target),
+			})
+		}
+	}
+	for _, rs := range refs {
+		sort.Slice(rs, func(i, j int) bool {
+			return strings.Join(rs[i].path, ".") < strings.Join(rs[j].path, ".")
+		})
+	}
+	return refs
+}
+
+// referenceKey is the key under which a managed resource that references the
+// named resource of the given kind is indexed.
+func referenceKey(target schema.GroupKind, nn types.NamespacedName) string {
+	return target.String() + "/" + nn.String()
+}
+
+// referenceKeys returns the keys under which obj is indexed.
+func referenceKeys(obj any, namespace string, refs []reference) []string {
+	var keys []string
+	seen := map[string]bool{}
+	visitReferences(obj, namespace, refs, func(ref reference, f referenced) {
+		for _, t := range f.targets {
+			k := referenceKey(ref.target.GroupKind(), t)
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	})
+	return keys
+}
+
+// clearStale zeroes the value fields of obj that are resolved from a
+// reference to the named resource of the target kind and hold one of the
+// given stale values, so that they are resolved again. A slice value field
+// is cleared as a whole, because it is only resolved again once it is empty.
+// It returns the cleared fields.
+func clearStale(obj any, namespace string, refs []reference, target schema.GroupKind, nn types.NamespacedName, stale map[string]bool) []stalerefs.ClearedField {
+	var cleared []stalerefs.ClearedField
+	visitReferences(obj, namespace, refs, func(ref reference, f referenced) {
+		if ref.target.GroupKind() != target || !f.value.CanSet() {
+			return
+		}
+		refersTo := false
+		for _, t := range f.targets {
+			refersTo = refersTo || t == nn
+		}
+		values := stalerefs.StringValues(f.value)
+		isStale := false
+		for _, v := range values {
+			isStale = isStale || stale[v]
+		}
+		if !refersTo || !isStale {
+			return
+		}
+		cleared = append(cleared, stalerefs.ClearedField{Path: f.path, Values: values})
+		f.value.Set(reflect.Zero(f.value.Type()))
+	})
+	return cleared
+}
+
+// referenced is a reference-resolved value field of a managed resource.
+type referenced struct {
+	// path is the JSON path of the value field.
+	path string
+	// value is the value field.
+	value reflect.Value
+	// targets names the referenced resources. It has an entry per
+	// reference of a slice value field.
+	targets []types.NamespacedName
+}
+
+// visitReferences calls fn for every value field of obj that is resolved
+// from a set reference field, in spec.forProvider and spec.initProvider.
+// Namespaced references without a namespace refer to the namespace of obj.
+func visitReferences(obj any, namespace string, refs []reference, fn func(ref reference, f referenced)) {
+	v := reflect.ValueOf(obj)
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	spec := v.FieldByName("Spec")
+	if !spec.IsValid() || spec.Kind() != reflect.Struct {
+		return
+	}
+	for _, params := range []struct{ field, path string }{{"ForProvider", "spec.forProvider"}, {"InitProvider", "spec.initProvider"}} {
+		p := spec.FieldByName(params.field)
+		if !p.IsValid() {
+			continue
+		}
+		for _, ref := range refs {
+			visitPath(p, params.path, ref.path, func(parent reflect.Value, path string) {
+				if f, ok := referencedField(parent, path, namespace, ref); ok {
+					fn(ref, f)
+				}
+			})
+		}
+	}
+}
+
+// visitPath calls fn for every struct holding the last element of the given
+// Terraform path, descending into nested blocks and the elements of slices.
+func visitPath(v reflect.Value, jsonPath string, tfPath []string, fn func(parent reflect.Value, jsonPath string)) {
+	switch v.Kind() { //nolint:exhaustive // only structs and containers of structs hold nested fields
+	case reflect.Pointer:
+		if !v.IsNil() {
+			visitPath(v.Elem(), jsonPath, tfPath, fn)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			visitPath(v.Index(i), fmt.Sprintf("%s[%d]", jsonPath, i), tfPath, fn)
+		}
+	case reflect.Struct:
+		if len(tfPath) == 1 {
+			fn(v, jsonPath)
+			return
+		}
+		if f, ok := fieldByTFName(v.Type(), tfPath[0]); ok {
+			visitPath(v.FieldByIndex(f.Index), jsonPath+"."+stalerefs.JSONName(f), tfPath[1:], fn)
+		}
+	}
+}
+
+// referencedField returns the value field named by the last element of the
+// reference path in the struct parent if its reference field is set.
+func referencedField(parent reflect.Value, jsonPath, namespace string, ref reference) (referenced, bool) {
+	vf, ok := fieldByTFName(parent.Type(), ref.path[len(ref.path)-1])
+	if !ok {
+		return referenced{}, false
+	}
+	value := parent.FieldByIndex(vf.Index)
+	refName := ref.refField
+	if refName == "" {
+		refName = vf.Name + "Ref"
+		if value.Kind() == reflect.Slice {
+			refName += "s"
+		}
+	}
+	rv := parent.FieldByName(refName)
+	if !rv.IsValid() {
+		return referenced{}, false
+	}
+	var targets []types.NamespacedName
+	switch rv.Kind() { //nolint:exhaustive // upjet generates pointers to references and slices of references
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return referenced{}, false
+		}
+		targets = append(targets, referenceTarget(rv.Elem(), namespace))
+	case reflect.Slice:
+		for i := 0; i < rv.Len(); i++ {
+			targets = append(targets, referenceTarget(rv.Index(i), namespace))
+		}
+	}
+	if len(targets) == 0 {
+		return referenced{}, false
+	}
+	return referenced{path: jsonPath + "." + stalerefs.JSONName(vf), value: value, targets: targets}, true
+}
+
+// referenceTarget returns the name of the resource an xpv1.Reference or
+// xpv1.NamespacedReference refers to.
+func referenceTarget(ref reflect.Value, namespace string) types.NamespacedName {
+	nn := types.NamespacedName{Name: ref.FieldByName("Name").String()}
+	if ns := ref.FieldByName("Namespace"); ns.IsValid() {
+		nn.Namespace = namespace
+		if ns.String() != "" {
+			nn.Namespace = ns.String()
+		}
+	}
+	return nn
+}
+
+// fieldByTFName returns the field of t with the given Terraform name.
+func fieldByTFName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if n, _, _ := strings.Cut(f.Tag.Get("tf"), ","); n == name {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2021 Upbound Inc.
+*/
+
+// Package health periodically checks that the Keycloak instance behind each
+// ProviderConfig is reachable with its credentials and reports the outcome on
+// the ProviderConfig's status.
+package health
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+	"github.com/crossplane/upjet/v2/pkg/controller"
+
+	"github.com/crossplane-contrib/provider-keycloak/internal/clients"
+	"github.com/crossplane-contrib/provider-keycloak/internal/tfconcurrency"
+)
+
+// TypeHealthy indicates whether the provider can reach and authenticate to
+// the Keycloak instance of a ProviderConfig.
+const TypeHealthy xpv1.ConditionType = "Healthy"
+
+// Reasons a ProviderConfig is or is not healthy.
+const (
+	ReasonKeycloakReachable   xpv1.ConditionReason = "KeycloakReachable"
+	ReasonKeycloakUnreachable xpv1.ConditionReason = "KeycloakUnreachable"
+)
+
+// defaultInterval is used when the controller options carry no poll interval.
+const defaultInterval = 5 * time.Minute
+
+// refreshPeriod is how long an unchanged health status is kept before it is
+// written again, so that its LastCheckTime stays recent.
+const refreshPeriod = 30 * time.Minute
+
+const (
+	errGetProviderConfig = "cannot get ProviderConfig"
+	errUpdateStatus      = "cannot update ProviderConfig status"
+)
+
+// Status holds the health fields recorded on a ProviderConfig's status.
+type Status struct {
+	ServerVersion  string
+	ActiveEndpoint string
+	Realm          string
+	TokenExpiry    *metav1.Time
+	LastCheckTime  *metav1.Time
+	LastError      string
+}
+
+// Healthy returns a condition that indicates the Keycloak instance of a
+// ProviderConfig is reachable with its credentials.
+func Healthy() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeHealthy,
+		Status:             "True",
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonKeycloakReachable,
+	}
+}
+
+// Unhealthy returns a condition that indicates the Keycloak instance of a
+// ProviderConfig could not be reached or authenticated to.
+func Unhealthy(err error) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeHealthy,
+		Status:             "False",
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonKeycloakUnreachable,
+		Message:            err.Error(),
+	}
+}
+
+// Setup adds a controller that checks the health of every ProviderConfig of
+// the kind produced by newPC once per poll interval, and immediately whenever
+// its spec changes. getStatus and setStatus read and write the health fields
+// of the kind's status.
+func Setup(mgr ctrl.Manager, o controller.Options, gk string, newPC func() resource.ProviderConfig, getStatus func(resource.ProviderConfig) Status, setStatus func(resource.ProviderConfig, Status)) error {
+	name := "health/" + gk
+	interval := o.PollInterval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	r := &reconciler{
+		kube:      mgr.GetClient(),
+		log:       o.Logger.WithValues("controller", name),
+		newPC:     newPC,
+		getStatus: getStatus,
+		setStatus: setStatus,
+		interval:  interval,
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		WithOptions(o.ForControllerRuntime()).
+		For(newPC(), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
+
+type reconciler struct {
+	kube      client.Client
+	log       logging.Logger
+	newPC     func() resource.ProviderConfig
+	getStatus func(resource.ProviderConfig) Status
+	setStatus func(resource.ProviderConfig, Status)
+	interval  time.Duration
+}
+
+func (r *reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	log := r.log.WithValues("request", req)
+
+	pc := r.newPC()
+	if err := r.kube.Get(ctx, req.NamespacedName, pc); err != nil {
+		return reconcile.Result{}, errors.Wrap(resource.IgnoreNotFound(err), errGetProviderConfig)
+	}
+	if meta.WasDeleted(pc) {
+		return reconcile.Result{}, nil
+	}
+
+	prev, prevHealthy := r.getStatus(pc), pc.GetCondition(TypeHealthy)
+	h, err := clients.CheckHealth(ctx, r.kube, pc)
+	switch {
+	case errors.Is(err, tfconcurrency.ErrThrottled):
+		// The check is not a verdict on Keycloak; try again next interval.
+		return reconcile.Result{RequeueAfter: r.interval}, nil
+	case errors.Is(err, tfconcurrency.ErrKeycloakUnavailable) && prevHealthy.Status == corev1.ConditionFalse:
+		// The circuit breaker stays open until Keycloak is probed again, and
+		// its message only counts down to that.
+		return reconcile.Result{RequeueAfter: r.interval}, nil
+	}
+
+	var st Status
+	healthy, available := Healthy(), xpv1.Available()
+	if err != nil {
+		log.Debug("Keycloak health check failed", "error", err)
+		st.LastError = err.Error()
+		healthy, available = Unhealthy(err), xpv1.Unavailable().WithMessage(err.Error())
+	} else {
+		st.ServerVersion = h.ServerVersion
+		st.ActiveEndpoint = h.Endpoint
+		st.Realm = h.Realm
+		if h.TokenExpiry != nil {
+			// The API server stores times with second precision.
+			exp := metav1.NewTime(h.TokenExpiry.Truncate(time.Second))
+			st.TokenExpiry = &exp
+		}
+	}
+	// Only changes are written, and an unchanged status once per refresh
+	// period, so that a stable ProviderConfig does not cost an API server
+	// write per interval.
+	if healthy.Equal(prevHealthy) && st.ServerVersion == prev.ServerVersion && st.ActiveEndpoint == prev.ActiveEndpoint && st.Realm == prev.Realm &&
+		st.TokenExpiry.Equal(prev.TokenExpiry) && prev.LastCheckTime != nil && time.Since(prev.LastCheckTime.Time) < refreshPeriod {
+		return reconcile.Result{RequeueAfter: r.interval}, nil
+	}
+
+	orig := pc.DeepCopyObject().(client.Object)
+	now := metav1.Now()
+	st.LastCheckTime = &now
+	pc.SetConditions(healthy, available)
+	r.setStatus(pc, st)
+
+	return reconcile.Result{RequeueAfter: r.interval}, errors.Wrap(r.kube.Status().Patch(ctx, pc, client.MergeFrom(orig)), errUpdateStatus)
+}
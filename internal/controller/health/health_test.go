@@ -0,0 +1,128 @@
+package health
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/test"
+
+	"github.com/crossplane-contrib/provider-keycloak/apis/namespaced/v1beta1"
+)
+
+func TestReconcileUnreachable(t *testing.T) {
+	var stored *v1beta1.ClusterProviderConfig
+	patches := 0
+	kube := &test.MockClient{
+		MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+			switch o := obj.(type) {
+			case *v1beta1.ClusterProviderConfig:
+				if stored != nil {
+					stored.DeepCopyInto(o)
+					return nil
+				}
+				o.Name = key.Name
+				o.Spec.Credentials.Source = xpv1.CredentialsSourceSecret
+				o.Spec.Credentials.SecretRef = &xpv1.SecretKeySelector{
+					SecretReference: xpv1.SecretReference{Name: "missing", Namespace: "crossplane-system"},
+					Key:             "credentials",
+				}
+				return nil
+			case *corev1.Secret:
+				return apierrors.NewNotFound(corev1.Resource("secrets"), key.Name)
+			}
+			return nil
+		},
+		MockStatusPatch: func(_ context.Context, obj client.Object, _ client.Patch, _ ...client.SubResourcePatchOption) error {
+			stored = obj.(*v1beta1.ClusterProviderConfig).DeepCopy()
+			patches++
+			return nil
+		},
+	}
+	r := &reconciler{
+		kube:      kube,
+		log:       logging.NewNopLogger(),
+		newPC:     func() resource.ProviderConfig { return &v1beta1.ClusterProviderConfig{} },
+		getStatus: getTestStatus,
+		setStatus: setTestStatus,
+		interval:  defaultInterval,
+	}
+
+	res, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Name: "default"}})
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if res.RequeueAfter != defaultInterval {
+		t.Errorf("RequeueAfter = %v, want %v", res.RequeueAfter, defaultInterval)
+	}
+	if stored == nil {
+		t.Fatal("status was not updated")
+	}
+	if c := stored.GetCondition(TypeHealthy); c.Status != corev1.ConditionFalse || c.Reason != ReasonKeycloakUnreachable {
+		t.Errorf("Healthy condition = %+v, want False/%s", c, ReasonKeycloakUnreachable)
+	}
+	if c := stored.GetCondition(xpv1.TypeReady); c.Status != corev1.ConditionFalse {
+		t.Errorf("Ready condition = %+v, want False", c)
+	}
+	if stored.Status.LastError == "" || stored.Status.LastCheckTime == nil {
+		t.Errorf("status = %+v, want LastError and LastCheckTime set", stored.Status)
+	}
+	if stored.Status.ServerVersion != "" {
+		t.Errorf("ServerVersion = %q, want empty", stored.Status.ServerVersion)
+	}
+
+	// A check with the same outcome writes nothing.
+	if _, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Name: "default"}}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if patches != 1 {
+		t.Errorf("status patches = %d, want 1", patches)
+	}
+
+	// An unchanged status is written again once the refresh period passed.
+	stale := metav1.NewTime(time.Now().Add(-refreshPeriod))
+	stored.Status.LastCheckTime = &stale
+	if _, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Name: "default"}}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if patches != 2 || !stale.Before(stored.Status.LastCheckTime) {
+		t.Errorf("status patches = %d with LastCheckTime %v, want a refreshed LastCheckTime", patches, stored.Status.LastCheckTime)
+	}
+}
+
+func TestReconcileNotFound(t *testing.T) {
+	kube := &test.MockClient{
+		MockGet: test.NewMockGetFn(apierrors.NewNotFound(v1beta1.SchemeGroupVersion.WithResource("clusterproviderconfigs").GroupResource(), "gone")),
+	}
+	r := &reconciler{
+		kube:  kube,
+		log:   logging.NewNopLogger(),
+		newPC: func() resource.ProviderConfig { return &v1beta1.ClusterProviderConfig{} },
+	}
+	res, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Name: "gone"}})
+	if err != nil || res.RequeueAfter != 0 {
+		t.Errorf("Reconcile() = (%+v, %v), want no requeue and no error", res, err)
+	}
+}
+
+func getTestStatus(pc resource.ProviderConfig) Status {
+	s := pc.(*v1beta1.ClusterProviderConfig).Status
+	return Status{ServerVersion: s.ServerVersion, LastCheckTime: s.LastCheckTime, LastError: s.LastError}
+}
+
+func setTestStatus(pc resource.ProviderConfig, st Status) {
+	s := &pc.(*v1beta1.ClusterProviderConfig).Status
+	s.ServerVersion = st.ServerVersion
+	s.LastCheckTime = st.LastCheckTime
+	s.LastError = st.LastError
+}
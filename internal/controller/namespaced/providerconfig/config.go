@@ -14,16 +14,61 @@ import (
 	"github.com/crossplane/upjet/v2/pkg/controller"
 
 	"github.com/crossplane-contrib/provider-keycloak/apis/namespaced/v1beta1"
+	"github.com/crossplane-contrib/provider-keycloak/internal/controller/health"
 )
 
-// Setup adds a controller that reconciles ProviderConfigs and
-// ClusterProviderConfigs by accounting for their current usage.
+// Setup adds controllers that reconcile ProviderConfigs and
+// ClusterProviderConfigs by accounting for their current usage, and
+// controllers that report their health.
 func Setup(mgr ctrl.Manager, o controller.Options) error {
 	if err := setupNamespacedProviderConfig(mgr, o); err != nil {
 		return err
 	}
-	return setupClusterProviderConfig(mgr, o)
+	if err := setupClusterProviderConfig(mgr, o); err != nil {
+		return err
+	}
+	if err := health.Setup(mgr, o, v1beta1.ProviderConfigGroupKind, func() resource.ProviderConfig { return &v1beta1.ProviderConfig{} }, healthStatus, setHealthStatus); err != nil {
+		return err
+	}
+	return health.Setup(mgr, o, v1beta1.ClusterProviderConfigGroupKind, func() resource.ProviderConfig { return &v1beta1.ClusterProviderConfig{} }, healthStatus, setHealthStatus)
+}
 
+func healthStatus(pc resource.ProviderConfig) health.Status {
+	var s *v1beta1.ProviderConfigStatus
+	switch p := pc.(type) {
+	case *v1beta1.ProviderConfig:
+		s = &p.Status
+	case *v1beta1.ClusterProviderConfig:
+		s = &p.Status
+	default:
+		return health.Status{}
+	}
+	return health.Status{
+		ServerVersion:  s.ServerVersion,
+		ActiveEndpoint: s.ActiveEndpoint,
+		Realm:          s.Realm,
+		TokenExpiry:    s.TokenExpiry,
+		LastCheckTime:  s.LastCheckTime,
+		LastError:      s.LastError,
+	}
+}
+
+func setHealthStatus(pc resource.ProviderConfig, st health.Status) {
+	var s *v1beta1.ProviderConfigStatus
+	switch p := pc.(type) {
+	case *v1beta1.ProviderConfig:
+		s = &p.Status
+	case *v1beta1.ClusterProviderConfig:
+		s = &p.Status
+	default:
+		return
+	}
+	s.ServerVersion = st.ServerVersion
+	s.ActiveEndpoint = st.ActiveEndpoint
+	s.Realm = st.Realm
+	s.TokenExpiry = st.TokenExpiry
+	s.LastCheckTime = st.LastCheckTime
+	s.LastError = st.LastError
 }
 
 func setupNamespacedProviderConfig(mgr ctrl.Manager, o controller.Options) error {
@@ -0,0 +1,64 @@
+/*
+Copyright 2024 Upbound Inc.
+*/
+
+package keycloaksession
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/keycloak/terraform-provider-keycloak/keycloak"
+)
+
+// bindings maps the Keycloak Terraform clients the provider instrumented to
+// their sessions.
+var bindings sync.Map // map[*keycloak.KeycloakClient]*Session
+
+// bind records that the tokens of c are recorded by s.
+func bind(c *keycloak.KeycloakClient, s *Session) {
+	if c == nil || s == nil {
+		return
+	}
+	bindings.Store(c, s)
+}
+
+// Unbind forgets the session of c, e.g. once c is discarded.
+func Unbind(c *keycloak.KeycloakClient) {
+	if c != nil {
+		bindings.Delete(c)
+	}
+}
+
+// SessionOf returns the session of c, if it was instrumented.
+func SessionOf(c *keycloak.KeycloakClient) (*Session, bool) {
+	if c == nil {
+		return nil, false
+	}
+	v, ok := bindings.Load(c)
+	if !ok {
+		return nil, false
+	}
+	return v.(*Session), true
+}
+
+// AdminGet reads path of the Keycloak admin API into out with the session of
+// c. See Session.Get.
+func AdminGet(ctx context.Context, c *keycloak.KeycloakClient, path string, params map[string]string, out any) error {
+	s, ok := SessionOf(c)
+	if !ok {
+		return errors.New("keycloak client was not instrumented by the provider")
+	}
+	return s.Get(ctx, path, params, out)
+}
+
+// AdminPut writes in to path of the Keycloak admin API with the session of
+// c. See Session.Put.
+func AdminPut(ctx context.Context, c *keycloak.KeycloakClient, path string, in any) error {
+	s, ok := SessionOf(c)
+	if !ok {
+		return errors.New("keycloak client was not instrumented by the provider")
+	}
+	return s.Put(ctx, path, in)
+}
@@ -7,24 +7,47 @@ package keycloaksession
 import (
 	"context"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
-	"reflect"
 	"sort"
 	"strings"
+	"sync"
 	"time"
-	"unsafe"
 
-	"github.com/keycloak/terraform-provider-keycloak/keycloak"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // logoutURLTemplate is the OIDC end-session endpoint pattern.
 // Parameters: base URL (including optional base path), realm name.
 const logoutURLTemplate = "%s/realms/%s/protocol/openid-connect/logout"
 
+// revokeURLTemplate is the OAuth 2.0 token revocation endpoint pattern.
+// Parameters: base URL (including optional base path), realm name.
+const revokeURLTemplate = "%s/realms/%s/protocol/openid-connect/revoke"
+
+// Token type hints of the token revocation endpoint.
+const (
+	tokenTypeAccess  = "access_token"
+	tokenTypeRefresh = "refresh_token"
+)
+
+var revocations = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "keycloak",
+	Subsystem: "token",
+	Name:      "revocations_total",
+	Help:      "Number of tokens of discarded Keycloak sessions that were revoked, by token type and result.",
+}, []string{"token_type", "result"})
+
+// Collectors returns the Prometheus collectors of this package. They must be
+// registered once, e.g. with the controller-runtime metrics registry.
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{revocations}
+}
+
 // logoutTimeout caps how long a single logout HTTP call may take.
 const logoutTimeout = 10 * time.Second
 
@@ -69,95 +92,54 @@ func IsPasswordGrant(config map[string]any) bool {
 	return username != "" && password != ""
 }
 
-// logoutConfigKeys lists the only configuration keys cached for logout.
-// Notably, "password" is NOT retained—only a pre-computed boolean
-// "is_password_grant" signals that the session needs explicit logout.
-var logoutConfigKeys = []string{
-	"url",
-	"base_path",
-	"realm",
-	"client_id",
-	"client_secret",
-	"is_password_grant",
-}
-
-// LogoutConfig returns a minimal copy of config containing only the
-// fields required to perform a session logout. The actual password is
-// not retained; instead, a boolean "is_password_grant" is computed and
-// stored to signal whether the session requires explicit logout.
-func LogoutConfig(config map[string]any) map[string]any {
-	m := make(map[string]any, len(logoutConfigKeys))
-	for _, k := range logoutConfigKeys {
-		if v, ok := config[k]; ok {
-			m[k] = v
-		}
+// TokenExpiry returns the expiry time recorded in the "exp" claim of a JWT
+// access token. The token is only decoded, not verified. It returns false if
+// the token is not a JWT or carries no expiry.
+func TokenExpiry(token string) (time.Time, bool) {
+	var claims struct {
+		Exp int64 `json:"exp"`
 	}
-	// Compute and store the grant-type boolean so that password is
-	// never retained in the cache.
-	m["is_password_grant"] = IsPasswordGrant(config)
-	return m
-}
-
-// ExtractRefreshToken reads the current refresh token from a
-// KeycloakClient instance. The upstream library keeps the token in
-// the unexported field clientCredentials (*ClientCredentials).
-// Because ClientCredentials is an exported type with an exported
-// RefreshToken field, we only need unsafe to cross the unexported
-// pointer boundary. If the struct layout changes in a future version
-// the function silently returns "".
-func ExtractRefreshToken(kcClient *keycloak.KeycloakClient) (token string) {
-	if kcClient == nil {
-		return ""
+	if !decodeClaims(token, &claims) || claims.Exp == 0 {
+		return time.Time{}, false
 	}
-	defer func() {
-		if r := recover(); r != nil {
-			token = ""
-		}
-	}()
+	return time.Unix(claims.Exp, 0), true
+}
 
-	v := reflect.ValueOf(kcClient).Elem()
-	credsField := v.FieldByName("clientCredentials")
-	if !credsField.IsValid() || credsField.IsNil() {
-		return ""
+// decodeClaims decodes the payload of a JWT into claims without verifying
+// it. It returns false if the token is not a JWT.
+func decodeClaims(token string, claims any) bool {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
 	}
-	// credsField is an unexported *ClientCredentials pointer.
-	// ClientCredentials and its RefreshToken field are exported.
-	creds := *(**keycloak.ClientCredentials)(unsafe.Pointer(credsField.UnsafeAddr())) //nolint:gosec // required to access unexported pointer field for session cleanup
-	if creds == nil {
-		return ""
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return false
 	}
-	return creds.RefreshToken
+	return json.Unmarshal(payload, claims) == nil
 }
 
-// LogoutSession ends the Keycloak session associated with the given
-// client by posting to the OIDC logout endpoint with the client's
-// current refresh token. It is a best-effort operation: errors are
-// silently ignored. It is a no-op for non-password-grant configs or
-// when the refresh token cannot be obtained.
-func LogoutSession(ctx context.Context, config map[string]any, kcClient *keycloak.KeycloakClient) {
-	// Check the pre-computed boolean first (from LogoutConfig), fall
-	// back to computing from username/password for raw configs.
-	if isPwGrant, ok := config["is_password_grant"].(bool); ok {
-		if !isPwGrant {
-			return
-		}
-	} else if !IsPasswordGrant(config) {
-		return
+// tokenSessionID returns the "sid" claim of a JWT access token, the ID of the
+// Keycloak user session it belongs to, or an empty string.
+func tokenSessionID(token string) string {
+	var claims struct {
+		Sid string `json:"sid"`
 	}
-
-	refreshToken := ExtractRefreshToken(kcClient)
-	if refreshToken == "" {
-		return
+	if !decodeClaims(token, &claims) {
+		return ""
 	}
+	return claims.Sid
+}
 
+// realmEndpoint returns the base URL of the Keycloak server, including the
+// base path, and the realm the provider authenticates against.
+func realmEndpoint(config map[string]any) (string, string) {
 	urlStr, _ := config["url"].(string)
 	basePath, _ := config["base_path"].(string)
 	realm, _ := config["realm"].(string)
 	if realm == "" {
 		realm = "master"
 	}
-	clientID, _ := config["client_id"].(string)
-	clientSecret, _ := config["client_secret"].(string)
 
 	// Normalize by trimming whitespace, trimming trailing slash from
 	// URL, and ensuring base_path has a leading slash (if non-empty)
@@ -169,29 +151,159 @@ func LogoutSession(ctx context.Context, config map[string]any, kcClient *keycloa
 		basePath = "/" + basePath
 	}
 	basePath = strings.TrimRight(basePath, "/")
+	return urlStr + basePath, realm
+}
 
-	logoutURL := fmt.Sprintf(logoutURLTemplate, urlStr+basePath, url.PathEscape(realm))
-
+// revokeToken revokes a single token (RFC 7009). auth is the authentication
+// of the client the token was issued to.
+func revokeToken(ctx context.Context, hc *http.Client, revokeURL string, auth url.Values, token, hint string) error {
 	data := url.Values{
-		"client_id":     {clientID},
-		"refresh_token": {refreshToken},
+		"token":           {token},
+		"token_type_hint": {hint},
 	}
-	if clientSecret != "" {
-		data.Set("client_secret", clientSecret)
+	for k, v := range auth {
+		data[k] = v
 	}
+	status, err := postForm(ctx, hc, revokeURL, data)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("token revocation failed with status %d", status)
+	}
+	return nil
+}
 
-	logoutCtx, cancel := context.WithTimeout(ctx, logoutTimeout)
+// postForm posts a form to Keycloak and returns the response status.
+func postForm(ctx context.Context, hc *http.Client, endpoint string, data url.Values) (int, error) {
+	reqCtx, cancel := context.WithTimeout(ctx, logoutTimeout)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(logoutCtx, http.MethodPost, logoutURL, strings.NewReader(data.Encode()))
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, endpoint, strings.NewReader(data.Encode()))
 	if err != nil {
-		return
+		return 0, err
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := hc.Do(req)
 	if err != nil {
-		return
+		return 0, err
 	}
 	_ = resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// Session records the tokens of a Keycloak Terraform client. The client
+// logs in and renews its tokens itself; the tokenTransport its HTTP client is
+// instrumented with observes the responses of the token endpoint. The
+// provider therefore knows every token the client uses and can revoke them,
+// and make the admin requests the client offers no method for, without
+// reaching into the client's internals.
+type Session struct {
+	http     *http.Client
+	base     string
+	adminURL string
+	realm    string
+	headers  map[string]string
+
+	passwordGrant bool
+	// staticToken is an access token the configuration provides. It is
+	// used as is, and never refreshed nor revoked.
+	staticToken string
+
+	// login logs the Keycloak client in, and refresh renews its access
+	// token, both with the client's own login path.
+	login   func(ctx context.Context) error
+	refresh func(ctx context.Context) error
+
+	// onTokens, if set, is called with the tokens of every token endpoint
+	// response.
+	onTokens func(ctx context.Context, t Tokens)
+
+	// mu serializes logins and renewals.
+	mu sync.Mutex
+
+	// tokensMu guards tokens, auth, invalid and observed, which the
+	// transport records.
+	tokensMu sync.RWMutex
+	tokens   Tokens
+	// auth is the client authentication of the last token request.
+	auth url.Values
+	// invalid is set once Keycloak rejected the access token.
+	invalid bool
+	// observed counts the token responses observed.
+	observed uint64
+}
+
+// newSession returns a session that records the tokens obtained through hc,
+// the HTTP client of a Keycloak Terraform client configured with config.
+func newSession(config map[string]any, hc *http.Client) *Session {
+	base, realm := realmEndpoint(config)
+	s := &Session{
+		http:          hc,
+		base:          base,
+		adminURL:      base,
+		realm:         realm,
+		headers:       map[string]string{},
+		passwordGrant: IsPasswordGrant(config),
+	}
+	s.staticToken, _ = config["access_token"].(string)
+	if admin, _ := config["admin_url"].(string); strings.TrimSpace(admin) != "" {
+		s.adminURL = strings.TrimRight(strings.TrimSpace(admin), "/")
+	}
+	if h, ok := config["additional_headers"].(map[string]any); ok {
+		for k, v := range h {
+			if v, ok := v.(string); ok {
+				s.headers[k] = v
+			}
+		}
+	}
+	return s
+}
+
+// Logout revokes the access and refresh tokens of the session at Keycloak's
+// token revocation endpoint, so that they cannot be used after the Keycloak
+// client is discarded, whatever grant they were obtained with. For the
+// password grant it first ends the server-side session by posting the
+// refresh token to the OIDC logout endpoint. Both authenticate with the
+// client authentication of the client's last token request. It is a
+// best-effort operation: failed revocations are counted by the
+// keycloak_token_revocations_total metric and otherwise ignored, and logout
+// errors are ignored. Tokens provided by the configuration are not revoked.
+func (s *Session) Logout(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokensMu.Lock()
+	t, auth := s.tokens, s.auth
+	s.tokens, s.auth = Tokens{}, nil
+	s.tokensMu.Unlock()
+	if t.AccessToken == "" && t.RefreshToken == "" {
+		return
+	}
+
+	if t.RefreshToken != "" && s.passwordGrant {
+		data := url.Values{"refresh_token": {t.RefreshToken}}
+		for k, v := range auth {
+			data[k] = v
+		}
+		_, _ = postForm(ctx, s.http, fmt.Sprintf(logoutURLTemplate, s.base, url.PathEscape(s.realm)), data)
+	}
+
+	revokeURL := fmt.Sprintf(revokeURLTemplate, s.base, url.PathEscape(s.realm))
+	for _, tok := range []struct{ token, hint string }{
+		{t.AccessToken, tokenTypeAccess},
+		{t.RefreshToken, tokenTypeRefresh},
+	} {
+		if tok.token == "" {
+			continue
+		}
+		result := "success"
+		if err := revokeToken(ctx, s.http, revokeURL, auth, tok.token, tok.hint); err != nil {
+			result = "failure"
+		}
+		revocations.WithLabelValues(tok.hint, result).Inc()
+	}
 }
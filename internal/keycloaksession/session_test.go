@@ -5,7 +5,13 @@ Copyright 2024 Upbound Inc.
 package keycloaksession
 
 import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 )
 
 func TestConfigCacheKey(t *testing.T) {
@@ -105,47 +111,87 @@ func TestIsPasswordGrant(t *testing.T) {
 	}
 }
 
-func TestExtractRefreshToken_nil(t *testing.T) {
-	if token := ExtractRefreshToken(nil); token != "" {
-		t.Fatalf("expected empty token for nil client, got %q", token)
+func TestTokenExpiry(t *testing.T) {
+	encode := func(payload string) string {
+		return "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".c2lnbmF0dXJl"
+	}
+	tests := []struct {
+		name   string
+		token  string
+		want   time.Time
+		wantOK bool
+	}{
+		{
+			name:   "jwt with exp claim",
+			token:  encode(`{"exp":1767225600,"iss":"https://keycloak.example.com/realms/master"}`),
+			want:   time.Unix(1767225600, 0),
+			wantOK: true,
+		},
+		{
+			name:  "jwt without exp claim",
+			token: encode(`{"iss":"https://keycloak.example.com/realms/master"}`),
+		},
+		{
+			name:  "opaque token",
+			token: "not-a-jwt",
+		},
+		{
+			name:  "malformed payload",
+			token: "a.%%%.c",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := TokenExpiry(tt.token)
+			if ok != tt.wantOK || !got.Equal(tt.want) {
+				t.Fatalf("TokenExpiry() = (%v, %v), want (%v, %v)", got, ok, tt.want, tt.wantOK)
+			}
+		})
 	}
 }
 
-func TestLogoutConfig(t *testing.T) {
-	full := map[string]any{
-		"url":           "https://keycloak.example.com",
-		"base_path":     "/auth",
-		"realm":         "myrealm",
-		"client_id":     "admin-cli",
-		"client_secret": "secret",
-		"username":      "admin",
-		"password":      "pass",
-		"access_token":  "should-not-be-retained",
-		"jwt_token":     "should-not-be-retained",
-		"additional_headers": map[string]any{
-			"X-Custom": "value",
-		},
+func TestRevokeToken(t *testing.T) {
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("ParseForm: %v", err)
+		}
+		got = map[string]string{}
+		for k := range r.PostForm {
+			got[k] = r.PostForm.Get(k)
+		}
+		if r.PostForm.Get("client_secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	base, realm := realmEndpoint(map[string]any{"url": srv.URL + "/", "base_path": "auth"})
+	if base != srv.URL+"/auth" || realm != "master" {
+		t.Fatalf("realmEndpoint() = %q, %q", base, realm)
 	}
-	got := LogoutConfig(full)
 
-	// Should contain only logout-relevant keys (password/username NOT retained)
-	expectedKeys := []string{"url", "base_path", "realm", "client_id", "client_secret", "is_password_grant"}
-	if len(got) != len(expectedKeys) {
-		t.Fatalf("expected %d keys, got %d: %v", len(expectedKeys), len(got), got)
+	if err := revokeToken(context.Background(), http.DefaultClient, srv.URL, url.Values{"client_id": {"provider"}, "client_secret": {"secret"}}, "token-1", tokenTypeAccess); err != nil {
+		t.Fatalf("revokeToken() = %v", err)
 	}
-	for _, k := range expectedKeys {
-		if _, ok := got[k]; !ok {
-			t.Fatalf("expected key %q in logout config", k)
+	want := map[string]string{"client_id": "provider", "client_secret": "secret", "token": "token-1", "token_type_hint": "access_token"}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("form %s = %q, want %q", k, got[k], v)
 		}
 	}
-	// Sensitive keys should be absent
-	for _, k := range []string{"access_token", "jwt_token", "additional_headers", "username", "password"} {
-		if _, ok := got[k]; ok {
-			t.Fatalf("key %q should not be in logout config", k)
-		}
+
+	if err := revokeToken(context.Background(), http.DefaultClient, srv.URL, url.Values{"client_id": {"provider"}}, "token-2", tokenTypeRefresh); err == nil {
+		t.Fatal("expected a rejected revocation to fail")
+	}
+}
+
+func TestTokenSessionID(t *testing.T) {
+	token := "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(`{"sid":"5c1d","exp":1767225600}`)) + ".c2lnbmF0dXJl"
+	if got := tokenSessionID(token); got != "5c1d" {
+		t.Errorf("tokenSessionID() = %q, want 5c1d", got)
 	}
-	// is_password_grant should be true since username+password are set
-	if got["is_password_grant"] != true {
-		t.Fatalf("expected is_password_grant=true, got %v", got["is_password_grant"])
+	if got := tokenSessionID("not-a-jwt"); got != "" {
+		t.Errorf("tokenSessionID() of an opaque token = %q, want empty", got)
 	}
 }
@@ -0,0 +1,246 @@
+/*
+Copyright 2024 Upbound Inc.
+*/
+
+package keycloaksession
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/keycloak/terraform-provider-keycloak/keycloak"
+)
+
+// Tokens are the tokens of a Session, as observed on the last response of the
+// token endpoint.
+type Tokens struct {
+	AccessToken  string
+	RefreshToken string
+	// SessionID is the ID of the Keycloak user session the tokens belong
+	// to. It is empty for grants that start no session.
+	SessionID string
+
+	// Expiry is when the access token expires.
+	Expiry time.Time
+}
+
+// tokenResponse is the body of a successful token endpoint response.
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	SessionState string `json:"session_state"`
+}
+
+// clientAuthKeys are the form parameters a client authenticates with at the
+// token, logout and revocation endpoints.
+var clientAuthKeys = []string{"client_id", "client_secret", "client_assertion_type", "client_assertion"}
+
+func (s *Session) current() Tokens {
+	s.tokensMu.RLock()
+	defer s.tokensMu.RUnlock()
+	return s.tokens
+}
+
+// observe records the tokens of a token endpoint response, and the client
+// authentication of the request they were obtained with.
+func (s *Session) observe(ctx context.Context, form url.Values, body []byte) error {
+	var r tokenResponse
+	if err := json.Unmarshal(body, &r); err != nil {
+		return fmt.Errorf("cannot decode token response: %w", err)
+	}
+	if r.AccessToken == "" {
+		return errors.New("token response carries no access token")
+	}
+	t := Tokens{AccessToken: r.AccessToken, RefreshToken: r.RefreshToken, SessionID: r.SessionState}
+	if t.SessionID == "" {
+		t.SessionID = tokenSessionID(r.AccessToken)
+	}
+	switch exp, ok := TokenExpiry(r.AccessToken); {
+	case r.ExpiresIn > 0:
+		t.Expiry = time.Now().Add(time.Duration(r.ExpiresIn) * time.Second)
+	case ok:
+		t.Expiry = exp
+	}
+	auth := url.Values{}
+	for _, k := range clientAuthKeys {
+		if v := form.Get(k); v != "" {
+			auth.Set(k, v)
+		}
+	}
+
+	s.tokensMu.Lock()
+	s.tokens, s.auth, s.invalid = t, auth, false
+	s.observed++
+	s.tokensMu.Unlock()
+	if s.onTokens != nil {
+		s.onTokens(ctx, t)
+	}
+	return nil
+}
+
+// Tokens returns the tokens the Keycloak client obtained last, or the access
+// token the configuration provides.
+func (s *Session) Tokens() Tokens {
+	if s.staticToken != "" {
+		t := Tokens{AccessToken: s.staticToken}
+		t.Expiry, _ = TokenExpiry(s.staticToken)
+		return t
+	}
+	return s.current()
+}
+
+// Invalidate marks the access token of the session as due for renewal, e.g.
+// because Keycloak rejected it before it expired.
+func (s *Session) Invalidate() {
+	s.tokensMu.Lock()
+	defer s.tokensMu.Unlock()
+	s.invalid = true
+}
+
+// accessToken returns the access token admin requests are authorized with.
+// If the Keycloak client has not logged in yet, it logs in; if its token was
+// invalidated or has expired, it renews it. Either way it goes through the
+// client's own login path and takes the token the transport observed.
+func (s *Session) accessToken(ctx context.Context) (string, error) {
+	if s.staticToken != "" {
+		return s.staticToken, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokensMu.RLock()
+	t, invalid, observed := s.tokens, s.invalid, s.observed
+	s.tokensMu.RUnlock()
+
+	var err error
+	switch {
+	case t.AccessToken == "":
+		// The client logs in on its first request. The request itself
+		// may fail, e.g. for lack of permissions, once the login
+		// succeeded.
+		err = s.login(ctx)
+	case invalid || (!t.Expiry.IsZero() && !time.Now().Before(t.Expiry)):
+		err = s.refresh(ctx)
+	default:
+		return t.AccessToken, nil
+	}
+	s.tokensMu.RLock()
+	renewed := s.observed != observed
+	t = s.tokens
+	s.tokensMu.RUnlock()
+	switch {
+	case renewed:
+		return t.AccessToken, nil
+	case err != nil:
+		return "", err
+	}
+	return "", errors.New("the keycloak client obtained no access token")
+}
+
+// SessionID returns the ID of the Keycloak user session of the session's
+// current tokens, if any.
+func (s *Session) SessionID() string {
+	return s.current().SessionID
+}
+
+// Get reads path of the Keycloak admin API, e.g. /realms/master/components,
+// into out. params are added as query parameters. It is meant for the admin
+// reads the Keycloak Terraform client offers no method for. Errors carry the
+// response status as *keycloak.ApiError, like those of the Terraform client.
+func (s *Session) Get(ctx context.Context, path string, params map[string]string, out any) error {
+	body, err := s.adminRequest(ctx, http.MethodGet, path, params, nil)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, out)
+}
+
+// Delete deletes path of the Keycloak admin API, e.g.
+// /realms/master/sessions/{id}. Errors are those of Get.
+func (s *Session) Delete(ctx context.Context, path string) error {
+	_, err := s.adminRequest(ctx, http.MethodDelete, path, nil, nil)
+	return err
+}
+
+// Put writes in as JSON to path of the Keycloak admin API, e.g.
+// /realms/master/clients/{id}. Errors are those of Get.
+func (s *Session) Put(ctx context.Context, path string, in any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	_, err = s.adminRequest(ctx, http.MethodPut, path, nil, body)
+	return err
+}
+
+// adminRequest sends a request to the admin API. If Keycloak rejects the
+// access token, e.g. because it was revoked or the session ended before the
+// token expired, the token is renewed and the request retried once.
+func (s *Session) adminRequest(ctx context.Context, method, path string, params map[string]string, body []byte) ([]byte, error) {
+	u, err := url.Parse(s.adminURL + "/admin" + path)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	for k, v := range params {
+		q.Set(k, v)
+	}
+	u.RawQuery = q.Encode()
+
+	for attempt := 0; ; attempt++ {
+		token, err := s.accessToken(ctx)
+		if err != nil {
+			return nil, err
+		}
+		status, respBody, err := s.send(ctx, method, u.String(), token, body)
+		if err != nil {
+			return nil, fmt.Errorf("error sending %s request to %s: %w", method, path, err)
+		}
+		if status == http.StatusUnauthorized && attempt == 0 && s.staticToken == "" {
+			s.Invalidate()
+			continue
+		}
+		if status >= http.StatusBadRequest {
+			return nil, &keycloak.ApiError{
+				Code:    status,
+				Message: fmt.Sprintf("error sending %s request to %s: %d %s. Response body: %s", method, path, status, http.StatusText(status), respBody),
+			}
+		}
+		return respBody, nil
+	}
+}
+
+// send sends a single admin request authorized with token and returns the
+// response status and body.
+func (s *Session) send(ctx context.Context, method, endpoint, token string, body []byte) (int, []byte, error) {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
+	if err != nil {
+		return 0, nil, err
+	}
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close() //nolint:errcheck // the body is fully read
+	respBody, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, respBody, err
+}
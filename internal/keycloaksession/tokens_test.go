@@ -0,0 +1,292 @@
+/*
+Copyright 2024 Upbound Inc.
+*/
+
+package keycloaksession
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/keycloak/terraform-provider-keycloak/keycloak"
+)
+
+// fakeKeycloak is a Keycloak token, logout, revocation and admin endpoint that
+// records the requests it receives.
+type fakeKeycloak struct {
+	mu      sync.Mutex
+	issued  int
+	grants  []string
+	revoked []string
+	// revokedWith are the client authentications of the revocation
+	// requests.
+	revokedWith []string
+	loggedOut   []string
+	authz       []string
+	puts        []string
+	// rejected are access tokens the admin API answers with 401.
+	rejected map[string]bool
+}
+
+func (f *fakeKeycloak) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_ = r.ParseForm()
+	if authz := r.Header.Get("Authorization"); f.rejected[authz] {
+		f.authz = append(f.authz, authz)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch r.URL.Path {
+	case "/realms/master/protocol/openid-connect/token":
+		f.grants = append(f.grants, r.PostForm.Get("grant_type"))
+		f.issued++
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  fmt.Sprintf("access-%d", f.issued),
+			"refresh_token": fmt.Sprintf("refresh-%d", f.issued),
+			"expires_in":    60,
+			"session_state": fmt.Sprintf("session-%d", f.issued),
+		})
+	case "/realms/master/protocol/openid-connect/logout":
+		f.loggedOut = append(f.loggedOut, r.PostForm.Get("refresh_token"))
+		w.WriteHeader(http.StatusNoContent)
+	case "/realms/master/protocol/openid-connect/revoke":
+		f.revoked = append(f.revoked, r.PostForm.Get("token"))
+		f.revokedWith = append(f.revokedWith, r.PostForm.Get("client_secret")+r.PostForm.Get("client_assertion"))
+	case "/admin/realms/master/components":
+		f.authz = append(f.authz, r.Header.Get("Authorization"))
+		if r.URL.Query().Get("name") == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"id":"c1"}]`))
+	case "/admin/realms/master/clients/c1":
+		body, _ := io.ReadAll(r.Body)
+		f.puts = append(f.puts, r.Method+" "+r.Header.Get("Content-Type")+" "+string(body))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// newTestSession returns a session whose login and refresh stand in for
+// those of a Keycloak Terraform client: they post the credentials of the
+// configuration to the token endpoint, through the instrumented transport.
+func newTestSession(t *testing.T, f *fakeKeycloak, config map[string]any) *Session {
+	t.Helper()
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	config["url"] = srv.URL
+	config["client_id"] = "admin-cli"
+
+	s := newSession(config, &http.Client{})
+	s.http.Transport = &tokenTransport{base: http.DefaultTransport, session: s}
+	form := url.Values{"client_id": {"admin-cli"}, "grant_type": {"client_credentials"}}
+	for k, v := range config {
+		switch k {
+		case "username", "password":
+			form.Set("grant_type", "password")
+			form.Set(k, v.(string))
+		case "client_secret":
+			form.Set(k, v.(string))
+		case "jwt_token":
+			form.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+			form.Set("client_assertion", v.(string))
+		}
+	}
+	login := func(ctx context.Context) error {
+		status, err := postForm(ctx, s.http, srv.URL+"/realms/master"+tokenEndpointSuffix, form)
+		if err == nil && status != http.StatusOK {
+			err = fmt.Errorf("login failed with status %d", status)
+		}
+		return err
+	}
+	s.login, s.refresh = login, login
+	return s
+}
+
+func TestSessionLoginLogout(t *testing.T) {
+	f := &fakeKeycloak{}
+	s := newTestSession(t, f, map[string]any{"username": "admin", "password": "admin", "client_secret": "secret"})
+	var observed []string
+	s.onTokens = func(_ context.Context, t Tokens) { observed = append(observed, t.SessionID) }
+	ctx := context.Background()
+
+	if err := s.Login(ctx); err != nil {
+		t.Fatalf("Login() = %v", err)
+	}
+	tokens := s.Tokens()
+	if tokens.AccessToken != "access-1" || tokens.RefreshToken != "refresh-1" || tokens.SessionID != "session-1" || tokens.Expiry.IsZero() {
+		t.Fatalf("Tokens() = %+v, want the tokens of the login", tokens)
+	}
+	if fmt.Sprint(observed) != "[session-1]" {
+		t.Errorf("observed sessions %v, want [session-1]", observed)
+	}
+
+	// A client that has logged in is not logged in again.
+	if err := s.Login(ctx); err != nil || len(f.grants) != 1 {
+		t.Errorf("Login() = %v with %d token requests, want the existing token to be reused", err, len(f.grants))
+	}
+
+	s.Logout(ctx)
+	if fmt.Sprint(f.loggedOut) != "[refresh-1]" {
+		t.Errorf("logged out %v, want [refresh-1]", f.loggedOut)
+	}
+	if fmt.Sprint(f.revoked) != "[access-1 refresh-1]" {
+		t.Errorf("revoked %v, want [access-1 refresh-1]", f.revoked)
+	}
+	if fmt.Sprint(f.revokedWith) != "[secret secret]" {
+		t.Errorf("revocations authenticated with %v, want the client secret of the login", f.revokedWith)
+	}
+	if got := s.Tokens(); got.AccessToken != "" || got.RefreshToken != "" {
+		t.Errorf("tokens after Logout = %+v, want none", got)
+	}
+}
+
+func TestSessionClientAssertionLogout(t *testing.T) {
+	f := &fakeKeycloak{}
+	s := newTestSession(t, f, map[string]any{"jwt_token": "assertion"})
+	ctx := context.Background()
+
+	if err := s.Login(ctx); err != nil {
+		t.Fatalf("Login() = %v", err)
+	}
+	s.Logout(ctx)
+	if len(f.loggedOut) != 0 {
+		t.Errorf("logged out %v, want no end-session call for the client credentials grant", f.loggedOut)
+	}
+	if fmt.Sprint(f.revoked) != "[access-1 refresh-1]" {
+		t.Errorf("revoked %v, want [access-1 refresh-1]", f.revoked)
+	}
+	if fmt.Sprint(f.revokedWith) != "[assertion assertion]" {
+		t.Errorf("revocations authenticated with %v, want the client assertion of the login", f.revokedWith)
+	}
+}
+
+func TestSessionStaticToken(t *testing.T) {
+	f := &fakeKeycloak{}
+	s := newTestSession(t, f, map[string]any{"access_token": "static"})
+	ctx := context.Background()
+
+	var out []map[string]any
+	if err := s.Get(ctx, "/realms/master/components", nil, &out); err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	s.Logout(ctx)
+	if len(f.grants) != 0 || len(f.revoked) != 0 {
+		t.Errorf("grants %v, revoked %v, want a static token to be neither renewed nor revoked", f.grants, f.revoked)
+	}
+	if fmt.Sprint(f.authz) != "[Bearer static]" {
+		t.Errorf("Authorization = %v, want the static token", f.authz)
+	}
+}
+
+func TestSessionGet(t *testing.T) {
+	f := &fakeKeycloak{}
+	s := newTestSession(t, f, map[string]any{"client_secret": "secret"})
+	ctx := context.Background()
+
+	var out []struct {
+		ID string `json:"id"`
+	}
+	if err := s.Get(ctx, "/realms/master/components", map[string]string{"type": "x"}, &out); err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	if len(out) != 1 || out[0].ID != "c1" {
+		t.Errorf("Get() = %+v", out)
+	}
+	if fmt.Sprint(f.authz) != "[Bearer access-1]" {
+		t.Errorf("Authorization = %v, want the token the transport observed", f.authz)
+	}
+
+	err := s.Get(ctx, "/realms/master/components", map[string]string{"name": "missing"}, &out)
+	var apiErr *keycloak.ApiError
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusNotFound {
+		t.Errorf("Get() of a missing resource = %v, want a 404 *keycloak.ApiError", err)
+	}
+}
+
+func TestSessionGetRenewsRejectedToken(t *testing.T) {
+	f := &fakeKeycloak{rejected: map[string]bool{"Bearer access-1": true}}
+	s := newTestSession(t, f, map[string]any{"client_secret": "secret"})
+	ctx := context.Background()
+
+	var out []map[string]any
+	if err := s.Get(ctx, "/realms/master/components", nil, &out); err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	if want := "[Bearer access-1 Bearer access-2]"; fmt.Sprint(f.authz) != want {
+		t.Errorf("Authorization = %v, want %v", f.authz, want)
+	}
+	if len(f.grants) != 2 {
+		t.Errorf("grants = %v, want a login and a renewal", f.grants)
+	}
+
+	// An invalidated token is renewed before it is used, and a renewed
+	// token that is rejected as well is not renewed a second time.
+	f.rejected["Bearer access-3"] = true
+	f.rejected["Bearer access-4"] = true
+	s.Invalidate()
+	err := s.Get(ctx, "/realms/master/components", nil, &out)
+	var apiErr *keycloak.ApiError
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusUnauthorized {
+		t.Errorf("Get() with a rejected token = %v, want a 401 *keycloak.ApiError", err)
+	}
+	if len(f.grants) != 4 {
+		t.Errorf("grants = %v, want a renewal of the invalidated and of the rejected token", f.grants)
+	}
+}
+
+func TestSessionPut(t *testing.T) {
+	f := &fakeKeycloak{}
+	s := newTestSession(t, f, map[string]any{"client_secret": "secret"})
+	ctx := context.Background()
+
+	if err := s.Put(ctx, "/realms/master/clients/c1", map[string]any{"attributes": map[string]string{"a": "b"}}); err != nil {
+		t.Fatalf("Put() = %v", err)
+	}
+	if want := `[PUT application/json {"attributes":{"a":"b"}}]`; fmt.Sprint(f.puts) != want {
+		t.Errorf("requests = %v, want %v", f.puts, want)
+	}
+	err := s.Put(ctx, "/realms/master/clients/missing", map[string]any{})
+	var apiErr *keycloak.ApiError
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusNotFound {
+		t.Errorf("Put() of a missing resource = %v, want a 404 *keycloak.ApiError", err)
+	}
+}
+
+func TestInstrument(t *testing.T) {
+	if _, err := Instrument(&keycloak.KeycloakClient{}, map[string]any{}, nil); !errors.Is(err, errNoHTTPClient) {
+		t.Errorf("Instrument() of a client without an HTTP client = %v, want %v", err, errNoHTTPClient)
+	}
+
+	// The Keycloak Terraform client must keep an HTTP client that can be
+	// instrumented, or the provider cannot observe its tokens.
+	c, err := keycloak.NewKeycloakClient(context.Background(), "https://keycloak.example.com", "", "", "admin-cli", "secret", "master", "", "", "", "", "", "", "", false, 15, "", false, "", "", "", false, nil, "")
+	if err != nil {
+		t.Fatalf("NewKeycloakClient() = %v", err)
+	}
+	t.Cleanup(func() { Unbind(c) })
+	s, err := Instrument(c, map[string]any{"url": "https://keycloak.example.com"}, nil)
+	if err != nil {
+		t.Fatalf("Instrument() = %v", err)
+	}
+	if got, ok := SessionOf(c); !ok || got != s {
+		t.Fatalf("SessionOf() = %v, %v, want the session of the instrumented client", got, ok)
+	}
+	if _, ok := s.http.Transport.(*tokenTransport); !ok {
+		t.Errorf("transport = %T, want a *tokenTransport", s.http.Transport)
+	}
+	Unbind(c)
+	if _, ok := SessionOf(c); ok {
+		t.Error("SessionOf() after Unbind: want no session")
+	}
+}
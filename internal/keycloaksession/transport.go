@@ -0,0 +1,148 @@
+/*
+Copyright 2024 Upbound Inc.
+*/
+
+package keycloaksession
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/keycloak/terraform-provider-keycloak/keycloak"
+)
+
+// tokenEndpointSuffix is the path suffix of the OIDC token endpoint of every
+// realm.
+const tokenEndpointSuffix = "/protocol/openid-connect/token"
+
+// errNoHTTPClient is returned by Instrument if the Keycloak Terraform client
+// has no HTTP client that could be instrumented.
+var errNoHTTPClient = errors.New("cannot instrument the keycloak client: it has no HTTP client")
+
+// tokenTransport wraps the http.RoundTripper of a Keycloak Terraform client.
+// It observes the responses of the token endpoint, whichever grant they
+// answer, and records the tokens they carry on the session, together with
+// the client authentication of the request. Because it only depends on the
+// OIDC wire format, it keeps working whatever the login code of the client
+// looks like.
+type tokenTransport struct {
+	base    http.RoundTripper
+	session *Session
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodPost || !strings.HasSuffix(req.URL.Path, tokenEndpointSuffix) {
+		return t.base.RoundTrip(req)
+	}
+	form, err := requestForm(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err := t.session.observe(req.Context(), form, body); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// requestForm returns the form a token request posts, leaving the request
+// body intact.
+func requestForm(req *http.Request) (url.Values, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return url.Values{}, nil
+	}
+	var raw []byte
+	var err error
+	if req.GetBody != nil {
+		var body io.ReadCloser
+		if body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+		raw, err = io.ReadAll(body)
+		_ = body.Close()
+	} else {
+		raw, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(raw))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return url.ParseQuery(string(raw))
+}
+
+// Instrument wraps the transport of the HTTP client of c, a Keycloak
+// Terraform client configured with config, so that the returned session
+// records the tokens c obtains, and binds c to the session. c must not have
+// logged in yet, or its first tokens go unnoticed: configure it with
+// initial_login disabled and log it in with Login once it is instrumented.
+// onTokens, if not nil, is called with the tokens of every login and renewal.
+func Instrument(c *keycloak.KeycloakClient, config map[string]any, onTokens func(context.Context, Tokens)) (*Session, error) {
+	hc, err := httpClientOf(c)
+	if err != nil {
+		return nil, err
+	}
+	s := newSession(config, hc)
+	s.onTokens = onTokens
+	s.login = func(ctx context.Context) error {
+		_, err := c.GetServerInfo(ctx)
+		return err
+	}
+	s.refresh = c.Refresh
+	base := hc.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	hc.Transport = &tokenTransport{base: base, session: s}
+	bind(c, s)
+	return s, nil
+}
+
+// Login logs the Keycloak client of the session in with its own login path,
+// unless it already has a token.
+func (s *Session) Login(ctx context.Context) error {
+	_, err := s.accessToken(ctx)
+	return err
+}
+
+// httpClientOf returns the HTTP client of c. The Keycloak Terraform client
+// offers no accessor for it, so it is found by its type among the fields of
+// the client, not by its name or position, and an error is returned if
+// there is not exactly one.
+func httpClientOf(c *keycloak.KeycloakClient) (*http.Client, error) {
+	if c == nil {
+		return nil, errNoHTTPClient
+	}
+	httpClientType := reflect.TypeFor[*http.Client]()
+	v := reflect.ValueOf(c).Elem()
+	var hc *http.Client
+	for i := range v.NumField() {
+		f := v.Field(i)
+		if f.Type() != httpClientType {
+			continue
+		}
+		if hc != nil || f.IsNil() {
+			return nil, errNoHTTPClient
+		}
+		hc = (*http.Client)(f.UnsafePointer())
+	}
+	if hc == nil {
+		return nil, errNoHTTPClient
+	}
+	return hc, nil
+}
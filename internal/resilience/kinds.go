@@ -0,0 +1,106 @@
+/*
+Copyright 2022 Upbound Inc.
+*/
+
+package resilience
+
+import (
+	"strings"
+	"unicode"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// KindSelector selects the managed resource kinds whose controllers are set
+// up. A nil KindSelector selects all kinds.
+type KindSelector struct {
+	entries []kindEntry
+}
+
+// kindEntry selects a kind, or all kinds of a group if kind is empty. group
+// is either an API group or the first label of one, such as role, which
+// selects the kinds of both the cluster-scoped and the namespaced API group.
+// It is empty if the entry selects a kind of any group.
+type kindEntry struct {
+	raw   string
+	kind  string
+	group string
+}
+
+// ParseKindSelector returns a KindSelector for the given entries, each of
+// which may be a comma separated list of:
+//
+//   - an API group, such as role.keycloak.m.crossplane.io,
+//   - the first label of an API group, such as role, which selects the group
+//     in both scopes,
+//   - a kind qualified by either of them, such as Role.role, or
+//   - a kind, such as Realm, which selects the kind in every group.
+//
+// Kinds start with an upper case letter, groups with a lower case one. It
+// returns nil if there are no entries, which selects all kinds.
+func ParseKindSelector(entries []string) *KindSelector {
+	var s KindSelector
+	for _, list := range entries {
+		for _, raw := range strings.Split(list, ",") {
+			raw = strings.TrimSpace(raw)
+			if raw == "" {
+				continue
+			}
+			e := kindEntry{raw: raw, group: raw}
+			if unicode.IsUpper([]rune(raw)[0]) {
+				e.kind, e.group, _ = strings.Cut(raw, ".")
+			}
+			s.entries = append(s.entries, e)
+		}
+	}
+	if len(s.entries) == 0 {
+		return nil
+	}
+	return &s
+}
+
+// Selects reports whether s selects the given kind.
+func (s *KindSelector) Selects(gk schema.GroupKind) bool {
+	if s == nil {
+		return true
+	}
+	for _, e := range s.entries {
+		if e.matches(gk) {
+			return true
+		}
+	}
+	return false
+}
+
+// Unmatched returns the entries of s that select none of the given kinds,
+// e.g. because they are misspelled.
+func (s *KindSelector) Unmatched(known []schema.GroupKind) []string {
+	if s == nil {
+		return nil
+	}
+	var out []string
+	for _, e := range s.entries {
+		matched := false
+		for _, gk := range known {
+			if e.matches(gk) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			out = append(out, e.raw)
+		}
+	}
+	return out
+}
+
+func (e kindEntry) matches(gk schema.GroupKind) bool {
+	if e.kind != "" && e.kind != gk.Kind {
+		return false
+	}
+	if e.group == "" || e.group == gk.Group {
+		return true
+	}
+	first, _, _ := strings.Cut(gk.Group, ".")
+	return e.group == first
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2022 Upbound Inc.
+*/
+
+package resilience
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestKindSelector(t *testing.T) {
+	role := schema.GroupKind{Group: "role.keycloak.crossplane.io", Kind: "Role"}
+	roleNamespaced := schema.GroupKind{Group: "role.keycloak.m.crossplane.io", Kind: "Role"}
+	realm := schema.GroupKind{Group: "realm.keycloak.m.crossplane.io", Kind: "Realm"}
+	keystore := schema.GroupKind{Group: "realm.keycloak.m.crossplane.io", Kind: "KeystoreRsa"}
+
+	cases := map[string]struct {
+		entries []string
+		want    map[schema.GroupKind]bool
+	}{
+		"NoneSelectsAll": {
+			want: map[schema.GroupKind]bool{role: true, roleNamespaced: true, realm: true, keystore: true},
+		},
+		"Group": {
+			entries: []string{"role.keycloak.m.crossplane.io"},
+			want:    map[schema.GroupKind]bool{role: false, roleNamespaced: true, realm: false},
+		},
+		"FirstLabelOfGroupSelectsBothScopes": {
+			entries: []string{"role"},
+			want:    map[schema.GroupKind]bool{role: true, roleNamespaced: true, realm: false},
+		},
+		"QualifiedKind": {
+			entries: []string{"Realm.realm"},
+			want:    map[schema.GroupKind]bool{realm: true, keystore: false, role: false},
+		},
+		"KindOfAnyGroup": {
+			entries: []string{"Role"},
+			want:    map[schema.GroupKind]bool{role: true, roleNamespaced: true, realm: false},
+		},
+		"CommaSeparatedAndRepeated": {
+			entries: []string{"Realm, role.keycloak.crossplane.io", "KeystoreRsa.realm.keycloak.m.crossplane.io"},
+			want:    map[schema.GroupKind]bool{role: true, roleNamespaced: false, realm: true, keystore: true},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := ParseKindSelector(tc.entries)
+			for gk, want := range tc.want {
+				if got := s.Selects(gk); got != want {
+					t.Errorf("Selects(%s) = %v, want %v", gk, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestKindSelectorUnmatched(t *testing.T) {
+	s := ParseKindSelector([]string{"role,Rol,Realm.role"})
+	known := []schema.GroupKind{{Group: "role.keycloak.m.crossplane.io", Kind: "Role"}}
+	if got, want := s.Unmatched(known), []string{"Rol", "Realm.role"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmatched() = %v, want %v", got, want)
+	}
+}
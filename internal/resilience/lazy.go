@@ -0,0 +1,63 @@
+/*
+Copyright 2022 Upbound Inc.
+*/
+
+package resilience
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const errAddLazyStart = "cannot wait for resources of the controller's kinds"
+
+// startLazily runs the setup function of s once there is at least one
+// resource of any of the given kinds. The resources are listed from the API
+// server every interval instead of being watched, so that kinds that are not
+// used cost neither an informer nor a watch. Only the leader waits, like the
+// controllers it sets up.
+func (m *Manager) startLazily(s *setup, gvks []schema.GroupVersionKind, interval time.Duration) {
+	s.set(StateIdle, nil)
+	err := m.Manager.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			if m.hasResources(ctx, gvks) {
+				m.log.Debug("Setting up a lazily started controller, its kinds are in use", "controller", s.name)
+				m.run(ctx, s)
+				return nil
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-t.C:
+			}
+		}
+	}))
+	if err != nil {
+		s.set(StateFailed, err)
+		m.log.Info(errAddLazyStart, "controller", s.name, "error", err)
+	}
+}
+
+// hasResources reports whether there is at least one resource of any of the
+// given kinds, in any namespace.
+func (m *Manager) hasResources(ctx context.Context, gvks []schema.GroupVersionKind) bool {
+	for _, gvk := range gvks {
+		l := &metav1.PartialObjectMetadataList{}
+		l.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err := m.GetAPIReader().List(ctx, l, client.Limit(1)); err != nil {
+			m.log.Debug("Cannot list the resources of a lazily started controller", "gvk", gvk.String(), "error", err)
+			continue
+		}
+		if len(l.Items) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -3,49 +3,165 @@ Copyright 2022 Upbound Inc.
 */
 
 // Package resilience keeps a single failing controller from taking down the
-// whole provider process.
+// whole provider process, and starts it again once it can. It also sets up
+// only the controllers of the selected kinds, optionally once their kinds are
+// in use, and reports the state of every controller.
 package resilience
 
 import (
 	"context"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
 	"github.com/crossplane/crossplane-runtime/v2/pkg/logging"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/config"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
 
-// WrapManager returns a manager whose Add isolates controller runnables: a
-// controller whose Start fails (e.g. its informer cache never syncs because
-// the stored objects of its kind cannot be listed or converted) is logged and
-// dropped instead of aborting the manager, which would also tear down the CRD
+const errNoController = "the setup of the controller added no controller"
+
+// RetryOptions configure how controllers that failed to start are retried.
+type RetryOptions struct {
+	// InitialBackoff is the time to wait before the first retry. It doubles
+	// with every failed retry.
+	InitialBackoff time.Duration
+	// MaxBackoff is the maximum time to wait between retries.
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryOptions are used for the zero RetryOptions.
+var DefaultRetryOptions = RetryOptions{InitialBackoff: 30 * time.Second, MaxBackoff: 10 * time.Minute}
+
+// Manager is a manager whose Add isolates controller runnables: a controller
+// whose Start fails (e.g. its informer cache never syncs because the stored
+// objects of its kind cannot be listed or converted) is logged and dropped
+// instead of aborting the manager, which would also tear down the CRD
 // conversion webhook served by the same process and turn one broken kind into
 // an outage for all of them (crossplane-contrib/provider-keycloak#669).
-func WrapManager(mgr manager.Manager, log logging.Logger) manager.Manager {
-	return &tolerantManager{Manager: mgr, log: log}
+//
+// Controllers set up through a Gate returned by Manager.Gate are set up
+// again with backoff after they were dropped, until they start.
+type Manager struct {
+	manager.Manager
+	log   logging.Logger
+	retry RetryOptions
+
+	// setupMu serializes the setups run through the gate, so that the
+	// controllers they add can be attributed to them.
+	setupMu sync.Mutex
+	// adding is the setup currently run by the gate, if any.
+	adding atomic.Pointer[setup]
+
+	mu     sync.Mutex
+	setups map[string]*setup
 }
 
-type tolerantManager struct {
-	manager.Manager
-	log logging.Logger
+// WrapManager returns a Manager wrapping mgr.
+func WrapManager(mgr manager.Manager, log logging.Logger, o RetryOptions) *Manager {
+	if o.InitialBackoff <= 0 {
+		o.InitialBackoff = DefaultRetryOptions.InitialBackoff
+	}
+	if o.MaxBackoff < o.InitialBackoff {
+		o.MaxBackoff = max(o.InitialBackoff, DefaultRetryOptions.MaxBackoff)
+	}
+	return &Manager{Manager: mgr, log: log, retry: o, setups: map[string]*setup{}}
 }
 
-func (m *tolerantManager) Add(r manager.Runnable) error {
-	if c, ok := r.(controller.Controller); ok {
-		return m.Manager.Add(&tolerantController{Controller: c, log: m.log})
+// Add adds r to the manager. Controllers are wrapped so that a failure to
+// start is not fatal. While a setup is set up again, only its controllers
+// are added; the other runnables it adds are still running from its first
+// run.
+func (m *Manager) Add(r manager.Runnable) error {
+	s := m.adding.Load()
+	c, ok := r.(controller.Controller)
+	if !ok {
+		if s != nil && s.retrying() {
+			return nil
+		}
+		return m.Manager.Add(r)
+	}
+	if s != nil {
+		s.set(StateStarting, nil)
 	}
-	return m.Manager.Add(r)
+	return m.Manager.Add(&tolerantController{Controller: c, log: m.log, manager: m, setup: s})
+}
+
+// GetControllerOptions returns the controller options of the manager. While
+// a setup is set up again, the name validation of controllers is skipped:
+// the controllers that failed to start still hold their names.
+func (m *Manager) GetControllerOptions() config.Controller {
+	o := m.Manager.GetControllerOptions()
+	if s := m.adding.Load(); s != nil && s.retrying() {
+		o.SkipNameValidation = ptr.To(true)
+	}
+	return o
+}
+
+// run runs the setup function of s. A setup function that fails before it
+// adds a controller leaves s pending; it is marked failed and retried.
+func (m *Manager) run(ctx context.Context, s *setup) {
+	m.setupMu.Lock()
+	s.set(StatePending, nil)
+	m.adding.Store(s)
+	s.fn()
+	m.adding.Store(nil)
+	m.setupMu.Unlock()
+	if s.current() == StatePending {
+		m.scheduleRetry(ctx, s, errors.New(errNoController))
+	}
+}
+
+// scheduleRetry runs the setup function of s again after a backoff that
+// doubles with every failed retry.
+func (m *Manager) scheduleRetry(ctx context.Context, s *setup, err error) {
+	s.mu.Lock()
+	backoff := m.retry.InitialBackoff
+	for i := 0; i < s.retries && backoff < m.retry.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, m.retry.MaxBackoff)
+	s.retries++
+	s.retryAt = time.Now().Add(backoff)
+	s.mu.Unlock()
+	s.set(StateFailed, err)
+	m.log.Info("Controller failed to start, the provider keeps running without it and retries to start it. Its managed resources are not reconciled until the underlying problem is fixed.", "controller", s.name, "retryIn", backoff.String(), "error", err)
+
+	go func() {
+		t := time.NewTimer(backoff)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+		case <-t.C:
+			m.run(ctx, s)
+		}
+	}()
 }
 
 type tolerantController struct {
 	controller.Controller
-	log logging.Logger
+	log     logging.Logger
+	manager *Manager
+	// setup is the setup that added the controller, if it was set up
+	// through the gate.
+	setup *setup
 }
 
 func (c *tolerantController) Start(ctx context.Context) error {
+	if c.setup != nil {
+		c.setup.set(StateRunning, nil)
+	}
 	err := c.Controller.Start(ctx)
 	if err == nil || ctx.Err() != nil {
 		return err
 	}
+	if c.setup != nil {
+		c.manager.scheduleRetry(ctx, c.setup, err)
+		return nil
+	}
 	c.log.Info("Controller failed to start, the provider keeps running without it. Its managed resources are not reconciled until the underlying problem is fixed and the provider is restarted.", "error", err)
 	return nil
 }
@@ -7,10 +7,25 @@ package resilience
 import (
 	"context"
 	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/crossplane/crossplane-runtime/v2/pkg/logging"
 	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/config"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -77,3 +92,210 @@ func TestTolerantControllerStart(t *testing.T) {
 		}
 	})
 }
+
+// fakeManager records the runnables it is given.
+type fakeManager struct {
+	manager.Manager
+	mu        sync.Mutex
+	runnables []manager.Runnable
+	skipNames []bool
+}
+
+func (m *fakeManager) Add(r manager.Runnable) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.runnables = append(m.runnables, r)
+	return nil
+}
+
+func (m *fakeManager) GetControllerOptions() config.Controller { return config.Controller{} }
+
+func (m *fakeManager) added() []manager.Runnable {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]manager.Runnable(nil), m.runnables...)
+}
+
+func TestGateRetriesFailedController(t *testing.T) {
+	fm := &fakeManager{}
+	m := WrapManager(fm, logging.NewNopLogger(), RetryOptions{InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+	gvk := schema.GroupVersionKind{Group: "role.keycloak.m.crossplane.io", Version: "v1alpha1", Kind: "Role"}
+
+	boom := errors.New("failed to wait for caches to sync")
+	attempts := 0
+	m.Gate(nil, GateOptions{}).Register(func() {
+		attempts++
+		var err error
+		if attempts == 1 {
+			err = boom
+		}
+		fm.skipNames = append(fm.skipNames, ptr.Deref(m.GetControllerOptions().SkipNameValidation, false))
+		_ = m.Add(&fakeController{start: func(context.Context) error { return err }})
+		_ = m.Add(manager.RunnableFunc(func(context.Context) error { return nil }))
+	}, gvk)
+
+	if got := len(fm.added()); got != 2 {
+		t.Fatalf("added %d runnables, want the controller and the runnable of the first setup", got)
+	}
+	name := "Role.role.keycloak.m.crossplane.io"
+	if got := testutil.ToFloat64(controllerState.WithLabelValues(name, string(StateStarting))); got != 1 {
+		t.Errorf("starting gauge = %v, want 1", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := fm.added()[0].Start(ctx); err != nil {
+		t.Fatalf("Start() = %v, want a failure to start not to propagate", err)
+	}
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/controllers", nil))
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), `"state":"failed"`) {
+		t.Errorf("ServeHTTP() = %d %s, want 503 reporting the failed controller", rec.Code, rec.Body.String())
+	}
+
+	// The setup is run again, adding only its controller.
+	deadline := time.Now().Add(5 * time.Second)
+	for len(fm.added()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	added := fm.added()
+	if len(added) != 3 {
+		t.Fatalf("added %d runnables, want the controller of the retry only", len(added))
+	}
+	if want := []bool{false, true}; !reflect.DeepEqual(fm.skipNames, want) {
+		t.Errorf("SkipNameValidation = %v, want %v", fm.skipNames, want)
+	}
+	go func() { _ = added[2].Start(ctx) }()
+	for m.setups[name].current() != StateRunning && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/controllers", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"state":"running"`) {
+		t.Errorf("ServeHTTP() = %d %s, want 200 reporting the running controller", rec.Code, rec.Body.String())
+	}
+	if got := testutil.ToFloat64(controllerState.WithLabelValues(name, string(StateFailed))); got != 0 {
+		t.Errorf("failed gauge = %v, want 0", got)
+	}
+}
+
+func TestGateRetriesSetupWithoutController(t *testing.T) {
+	fm := &fakeManager{}
+	m := WrapManager(fm, logging.NewNopLogger(), RetryOptions{InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+	gvk := schema.GroupVersionKind{Group: "role.keycloak.m.crossplane.io", Version: "v1alpha1", Kind: "Role"}
+
+	// The setup function fails before it adds a controller the first
+	// time, e.g. because its kind cannot be registered yet.
+	var mu sync.Mutex
+	attempts := 0
+	m.Gate(nil, GateOptions{}).Register(func() {
+		mu.Lock()
+		attempts++
+		failed := attempts == 1
+		mu.Unlock()
+		if !failed {
+			_ = m.Add(&fakeController{})
+		}
+	}, gvk)
+
+	name := "Role.role.keycloak.m.crossplane.io"
+	if got := m.setups[name].status(); got.State == StatePending {
+		t.Fatalf("state = %s, want a setup that added no controller not to stay pending", got.State)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(fm.added()) < 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := len(fm.added()); got != 1 {
+		t.Fatalf("added %d runnables, want the controller of the retry", got)
+	}
+	if got := m.setups[name].current(); got != StateStarting {
+		t.Errorf("state = %s, want %s", got, StateStarting)
+	}
+}
+
+func TestGateSelectsKinds(t *testing.T) {
+	fm := &fakeManager{}
+	m := WrapManager(fm, logging.NewNopLogger(), RetryOptions{})
+	g := m.Gate(nil, GateOptions{
+		Kinds:         ParseKindSelector([]string{"role"}),
+		AlwaysEnabled: []string{"keycloak.m.crossplane.io"},
+	})
+	var ran []string
+	for _, gvk := range []schema.GroupVersionKind{
+		{Group: "role.keycloak.m.crossplane.io", Version: "v1alpha1", Kind: "Role"},
+		{Group: "realm.keycloak.m.crossplane.io", Version: "v1alpha1", Kind: "Realm"},
+		{Group: "keycloak.m.crossplane.io", Version: "v1beta1", Kind: "ProviderConfig"},
+	} {
+		g.Register(func() {
+			ran = append(ran, gvk.Kind)
+			_ = m.Add(&fakeController{})
+		}, gvk)
+	}
+	if want := []string{"Role", "ProviderConfig"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("set up %v, want %v", ran, want)
+	}
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/controllers", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `{"name":"Realm.realm.keycloak.m.crossplane.io","state":"disabled"}`) {
+		t.Errorf("ServeHTTP() = %d %s, want 200 reporting the disabled controller", rec.Code, rec.Body.String())
+	}
+}
+
+// listingManager serves the API reader of a fakeManager.
+type listingManager struct {
+	*fakeManager
+	reader client.Reader
+}
+
+func (m *listingManager) GetAPIReader() client.Reader { return m.reader }
+
+func TestGateStartsLazily(t *testing.T) {
+	reader := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+	fm := &listingManager{fakeManager: &fakeManager{}, reader: reader}
+	m := WrapManager(fm, logging.NewNopLogger(), RetryOptions{})
+	gvk := corev1.SchemeGroupVersion.WithKind("ConfigMap")
+	always := schema.GroupVersionKind{Group: "keycloak.m.crossplane.io", Version: "v1beta1", Kind: "ProviderConfig"}
+	g := m.Gate(nil, GateOptions{Lazy: true, LazyPollInterval: time.Millisecond, AlwaysEnabled: []string{always.Group}})
+
+	var mu sync.Mutex
+	var ran []string
+	for _, gvk := range []schema.GroupVersionKind{gvk, always} {
+		g.Register(func() {
+			mu.Lock()
+			defer mu.Unlock()
+			ran = append(ran, gvk.Kind)
+		}, gvk)
+	}
+	if want := []string{"ProviderConfig"}; !reflect.DeepEqual(ran, want) {
+		t.Fatalf("set up %v right away, want %v", ran, want)
+	}
+	if got := m.setups["ConfigMap"].current(); got != StateIdle {
+		t.Errorf("state = %s, want %s", got, StateIdle)
+	}
+	added := fm.added()
+	if len(added) != 1 {
+		t.Fatalf("added %d runnables, want the one waiting for resources", len(added))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- added[0].Start(ctx) }()
+	if err := reader.(client.Client).Create(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cm"}}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Start() = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the controller was not set up once a resource of its kind existed")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"ProviderConfig", "ConfigMap"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("set up %v, want %v", ran, want)
+	}
+}
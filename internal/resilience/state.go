@@ -0,0 +1,243 @@
+/*
+Copyright 2022 Upbound Inc.
+*/
+
+package resilience
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	xpcontroller "github.com/crossplane/crossplane-runtime/v2/pkg/controller"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// State is the state of a controller set up through the gate.
+type State string
+
+// States of a controller.
+const (
+	// StatePending controllers wait for the gate to open, e.g. for the CRD of
+	// their kind to be installed.
+	StatePending State = "pending"
+	// StateStarting controllers were added to the manager, which did not
+	// start them yet, e.g. because this replica is not the leader.
+	StateStarting State = "starting"
+	// StateRunning controllers were started and did not fail.
+	StateRunning State = "running"
+	// StateFailed controllers failed to start and are retried.
+	StateFailed State = "failed"
+	// StateDisabled controllers are not set up because their kinds are not
+	// selected.
+	StateDisabled State = "disabled"
+	// StateIdle controllers are started lazily and wait for the first
+	// resource of their kinds.
+	StateIdle State = "idle"
+)
+
+var states = []State{StatePending, StateStarting, StateRunning, StateFailed, StateDisabled, StateIdle}
+
+var controllerState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "keycloak",
+	Subsystem: "controller",
+	Name:      "state",
+	Help:      "State of the managed resource controllers, 1 for the current state of a controller and 0 for the others.",
+}, []string{"controller", "state"})
+
+// Collectors returns the Prometheus collectors of the controller states.
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{controllerState}
+}
+
+// setup is a controller setup function registered with the gate.
+type setup struct {
+	name string
+	fn   func()
+
+	mu      sync.Mutex
+	state   State
+	err     error
+	retries int
+	retryAt time.Time
+}
+
+// set records the state of the controller of s.
+func (s *setup) set(state State, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state, s.err = state, err
+	for _, st := range states {
+		v := 0.0
+		if st == state {
+			v = 1
+		}
+		controllerState.WithLabelValues(s.name, string(st)).Set(v)
+	}
+}
+
+// current returns the state of the controller of s.
+func (s *setup) current() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// retrying reports whether the setup is run again.
+func (s *setup) retrying() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.retries > 0
+}
+
+// status is the reported status of a controller.
+type status struct {
+	Name    string     `json:"name"`
+	State   State      `json:"state"`
+	Error   string     `json:"error,omitempty"`
+	Retries int        `json:"retries,omitempty"`
+	RetryAt *time.Time `json:"retryAt,omitempty"`
+}
+
+func (s *setup) status() status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := status{Name: s.name, State: s.state, Retries: s.retries}
+	if s.state == StateFailed {
+		st.Error = s.err.Error()
+		st.RetryAt = &s.retryAt
+	}
+	return st
+}
+
+// GateOptions configure which controllers a gate sets up, and when.
+type GateOptions struct {
+	// Name prefixes the names the controllers set up through the gate are
+	// reported with, to tell them from the controllers of another gate that
+	// are set up for the same kinds.
+	Name string
+	// Kinds selects the kinds whose controllers are set up. A controller is
+	// set up if any of its kinds is selected.
+	Kinds *KindSelector
+	// Lazy defers setting up a controller until there is at least one
+	// resource of any of its kinds, which saves the informers and API server
+	// watches of the kinds that are not used.
+	Lazy bool
+	// LazyPollInterval is how often controllers that are started lazily
+	// check for resources of their kinds.
+	LazyPollInterval time.Duration
+	// AlwaysEnabled are API groups whose controllers are set up right away
+	// regardless of Kinds and Lazy, such as the group of the ProviderConfigs.
+	AlwaysEnabled []string
+}
+
+// DefaultLazyPollInterval is used for the zero LazyPollInterval.
+const DefaultLazyPollInterval = time.Minute
+
+// Gate returns a gate that registers the setup functions of controllers with
+// g, so that their controllers are set up again when they fail to start. A
+// nil g is a gate that is always open: setup functions registered with it
+// run right away, or once there are resources of their kinds if they are
+// started lazily.
+func (m *Manager) Gate(g xpcontroller.Gate, o GateOptions) xpcontroller.Gate {
+	if o.LazyPollInterval <= 0 {
+		o.LazyPollInterval = DefaultLazyPollInterval
+	}
+	return &gate{manager: m, gate: g, opts: o}
+}
+
+type gate struct {
+	manager *Manager
+	gate    xpcontroller.Gate
+	opts    GateOptions
+}
+
+// Register registers the setup function fn of a controller that must be run
+// once all given kinds are ready.
+func (g *gate) Register(fn func(), gvks ...schema.GroupVersionKind) {
+	names := make([]string, 0, len(gvks))
+	for _, gvk := range gvks {
+		names = append(names, gvk.GroupKind().String())
+	}
+	name := strings.Join(names, ",")
+	if g.opts.Name != "" {
+		name = g.opts.Name + "/" + name
+	}
+	s := &setup{name: name, fn: fn}
+	g.manager.mu.Lock()
+	g.manager.setups[s.name] = s
+	g.manager.mu.Unlock()
+
+	always := g.alwaysEnabled(gvks)
+	if !always && !g.selected(gvks) {
+		s.set(StateDisabled, nil)
+		g.manager.log.Debug("Not setting up the controller of kinds that are not enabled", "controller", s.name)
+		return
+	}
+	s.set(StatePending, nil)
+	start := func() { g.manager.run(context.Background(), s) }
+	if g.opts.Lazy && !always {
+		start = func() { g.manager.startLazily(s, gvks, g.opts.LazyPollInterval) }
+	}
+	if g.gate == nil {
+		start()
+		return
+	}
+	g.gate.Register(start, gvks...)
+}
+
+func (g *gate) selected(gvks []schema.GroupVersionKind) bool {
+	for _, gvk := range gvks {
+		if g.opts.Kinds.Selects(gvk.GroupKind()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *gate) alwaysEnabled(gvks []schema.GroupVersionKind) bool {
+	for _, gvk := range gvks {
+		for _, group := range g.opts.AlwaysEnabled {
+			if gvk.Group == group {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Set marks whether the given kind is ready.
+func (g *gate) Set(gvk schema.GroupVersionKind, ready bool) bool {
+	if g.gate == nil {
+		return false
+	}
+	return g.gate.Set(gvk, ready)
+}
+
+// ServeHTTP reports the state of the controllers set up through the gate as
+// JSON. It responds with 503 Service Unavailable while any of them failed to
+// start.
+func (m *Manager) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	m.mu.Lock()
+	all := make([]status, 0, len(m.setups))
+	for _, s := range m.setups {
+		all = append(all, s.status())
+	}
+	m.mu.Unlock()
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+
+	code := http.StatusOK
+	for _, s := range all {
+		if s.State == StateFailed {
+			code = http.StatusServiceUnavailable
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]any{"controllers": all})
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2021 Upbound Inc.
+*/
+
+// Package tenancy restricts the realms and namespaces a ProviderConfig may be
+// used for.
+package tenancy
+
+import (
+	"encoding/json"
+	"slices"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+	upjetresource "github.com/crossplane/upjet/v2/pkg/resource"
+
+	"github.com/crossplane-contrib/provider-keycloak/internal/conditions"
+)
+
+// Reasons of the Synced condition of a managed resource that its
+// ProviderConfig may not be used for.
+const (
+	ReasonRealmNotAllowed     xpv1.ConditionReason = "RealmNotAllowed"
+	ReasonNamespaceNotAllowed xpv1.ConditionReason = "NamespaceNotAllowed"
+)
+
+// metadataAllowedRealms is the key of the allowed realms in the client
+// metadata of a Terraform setup.
+const metadataAllowedRealms = "allowed_realms"
+
+const (
+	errFmtRealmNotAllowed     = "realm %q is not in the allowedRealms of the ProviderConfig"
+	errFmtNamespaceNotAllowed = "namespace %q is not in the allowedNamespaces of the ClusterProviderConfig"
+	errGetParameters          = "cannot get the parameters of the managed resource"
+)
+
+// realmParameters are the Terraform arguments that name the realm a resource
+// belongs to, in order of precedence.
+var realmParameters = []string{"realm_id", "realm"}
+
+// Realm returns the realm named by the given Terraform parameters, or an
+// empty string if they do not name one.
+func Realm(parameters map[string]any) string {
+	for _, p := range realmParameters {
+		if realm, ok := parameters[p].(string); ok && realm != "" {
+			return realm
+		}
+	}
+	return ""
+}
+
+// CheckRealm returns an error if realm is not one of the allowed realms. Every
+// realm is allowed if allowed is empty, and resources that do not belong to a
+// realm are always allowed.
+func CheckRealm(allowed []string, realm string) error {
+	if len(allowed) == 0 || realm == "" || slices.Contains(allowed, realm) {
+		return nil
+	}
+	return conditions.WithReason(ReasonRealmNotAllowed, errors.Errorf(errFmtRealmNotAllowed, realm))
+}
+
+// CheckNamespace returns an error if namespace is not one of the allowed
+// namespaces. Every namespace is allowed if allowed is empty, and
+// cluster-scoped resources are always allowed.
+func CheckNamespace(allowed []string, namespace string) error {
+	if len(allowed) == 0 || namespace == "" || slices.Contains(allowed, namespace) {
+		return nil
+	}
+	return conditions.WithReason(ReasonNamespaceNotAllowed, errors.Errorf(errFmtNamespaceNotAllowed, namespace))
+}
+
+// CheckManaged returns an error if the realm of a managed resource is not one
+// of the allowed realms. The realm is read from spec.forProvider, or from
+// spec.initProvider if it is not set there.
+func CheckManaged(allowed []string, mg resource.Managed) error {
+	if len(allowed) == 0 {
+		return nil
+	}
+	tr, ok := mg.(upjetresource.Parameterizable)
+	if !ok {
+		return nil
+	}
+	params, err := tr.GetParameters()
+	if err != nil {
+		return errors.Wrap(err, errGetParameters)
+	}
+	realm := Realm(params)
+	if realm == "" {
+		init, err := tr.GetInitParameters()
+		if err != nil {
+			return errors.Wrap(err, errGetParameters)
+		}
+		realm = Realm(init)
+	}
+	return CheckRealm(allowed, realm)
+}
+
+// AllowedRealms returns the allowed realms carried by the client metadata of
+// a Terraform setup, as passed to a GetIDFn.
+func AllowedRealms(terraformProviderConfig map[string]any) []string {
+	md, _ := terraformProviderConfig["client_metadata"].(map[string]string)
+	var realms []string
+	if v := md[metadataAllowedRealms]; v != "" {
+		_ = json.Unmarshal([]byte(v), &realms)
+	}
+	return realms
+}
+
+// SetAllowedRealms records the allowed realms in the client metadata of a
+// Terraform setup, so that they reach the lookups, which have no access to
+// the ProviderConfig. They are kept out of the provider configuration, which
+// identifies the cached clients of a Keycloak connection.
+func SetAllowedRealms(md map[string]string, realms []string) {
+	if len(realms) == 0 {
+		return
+	}
+	v, _ := json.Marshal(realms)
+	md[metadataAllowedRealms] = string(v)
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2021 Upbound Inc.
+*/
+
+package tenancy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckRealm(t *testing.T) {
+	cases := map[string]struct {
+		allowed []string
+		params  map[string]any
+		wantErr bool
+	}{
+		"Unrestricted":   {params: map[string]any{"realm_id": "team-b"}},
+		"AllowedRealmID": {allowed: []string{"team-a"}, params: map[string]any{"realm_id": "team-a"}},
+		"AllowedRealm":   {allowed: []string{"team-a"}, params: map[string]any{"realm": "team-a"}},
+		"NoRealm":        {allowed: []string{"team-a"}, params: map[string]any{"name": "x"}},
+		"OtherRealm":     {allowed: []string{"team-a"}, params: map[string]any{"realm_id": "master"}, wantErr: true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := CheckRealm(tc.allowed, Realm(tc.params))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("CheckRealm() error = %v, wantErr %t", err, tc.wantErr)
+			}
+			if err != nil && !strings.HasPrefix(err.Error(), string(ReasonRealmNotAllowed)+": ") {
+				t.Errorf("expected the error to carry reason %s, got %q", ReasonRealmNotAllowed, err)
+			}
+		})
+	}
+}
+
+func TestCheckNamespace(t *testing.T) {
+	allowed := []string{"team-a"}
+	if err := CheckNamespace(allowed, "team-a"); err != nil {
+		t.Errorf("allowed namespace: %v", err)
+	}
+	if err := CheckNamespace(allowed, ""); err != nil {
+		t.Errorf("cluster-scoped resource: %v", err)
+	}
+	if err := CheckNamespace(nil, "team-b"); err != nil {
+		t.Errorf("unrestricted: %v", err)
+	}
+	if err := CheckNamespace(allowed, "team-b"); err == nil {
+		t.Error("expected a namespace outside the allowed namespaces to be refused")
+	}
+}
+
+func TestAllowedRealmsRoundTrip(t *testing.T) {
+	md := map[string]string{}
+	SetAllowedRealms(md, nil)
+	if len(md) != 0 {
+		t.Fatalf("metadata = %v, want no key for unrestricted realms", md)
+	}
+	SetAllowedRealms(md, []string{"team-a", "team-b"})
+	got := AllowedRealms(map[string]any{"client_metadata": md})
+	if len(got) != 2 || got[0] != "team-a" || got[1] != "team-b" {
+		t.Fatalf("AllowedRealms() = %v", got)
+	}
+	if got := AllowedRealms(map[string]any{}); len(got) != 0 {
+		t.Errorf("AllowedRealms() without metadata = %v, want none", got)
+	}
+}
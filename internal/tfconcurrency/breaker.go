@@ -0,0 +1,298 @@
+/*
+Copyright 2021 Upbound Inc.
+*/
+
+package tfconcurrency
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/keycloak/terraform-provider-keycloak/keycloak"
+	"github.com/prometheus/client_golang/prometheus"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	"github.com/crossplane-contrib/provider-keycloak/internal/conditions"
+)
+
+// ReasonKeycloakUnavailable is the reason of the Synced condition of a managed
+// resource whose operation was short-circuited because the circuit breaker of
+// its provider configuration is open.
+const ReasonKeycloakUnavailable xpv1.ConditionReason = "KeycloakUnavailable"
+
+const errFmtCircuitOpen = "circuit breaker open after %d failures, next attempt in %s"
+
+// ErrKeycloakUnavailable is returned instead of a client while the circuit
+// breaker of a provider configuration is open.
+var ErrKeycloakUnavailable = errors.New("keycloak is unavailable")
+
+// connectionErrorMessages identify connection errors that only reach us as
+// text, e.g. inside Terraform diagnostics.
+var connectionErrorMessages = []string{
+	"connection refused",
+	"connection reset",
+	"no such host",
+	"no route to host",
+	"network is unreachable",
+	"i/o timeout",
+	"TLS handshake timeout",
+	"Client.Timeout exceeded",
+	"server misbehaving",
+}
+
+// serverErrorMessages identify 5xx responses that only reach us as text. The
+// Keycloak client includes the response status in its errors.
+var serverErrorMessages = []string{
+	"500 Internal Server Error",
+	"502 Bad Gateway",
+	"503 Service Unavailable",
+	"504 Gateway Timeout",
+}
+
+// refreshErrorMessage identifies errors of a Keycloak client that could not
+// refresh its access token after it expired.
+const refreshErrorMessage = "error refreshing credentials"
+
+// IsConnectionError reports whether err means that the endpoint could not be
+// reached, as opposed to Keycloak rejecting the request.
+func IsConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return containsAny(err.Error(), connectionErrorMessages)
+}
+
+// IsUnavailable reports whether err means that Keycloak is unavailable: it
+// could not be reached or answered with a server error.
+func IsUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var apiErr *keycloak.ApiError
+	if errors.As(err, &apiErr) && apiErr.Code >= 500 {
+		return true
+	}
+	return IsConnectionError(err) || containsAny(err.Error(), serverErrorMessages)
+}
+
+// IsRefreshFailure reports whether err means that a Keycloak client could not
+// refresh its session, after which it is of no further use.
+func IsRefreshFailure(err error) bool {
+	return err != nil && strings.Contains(err.Error(), refreshErrorMessage)
+}
+
+func containsAny(msg string, substrs []string) bool {
+	for _, s := range substrs {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// BreakerOptions configure the circuit breakers of all provider
+// configurations.
+type BreakerOptions struct {
+	// FailureThreshold is the number of consecutive failures that open a
+	// breaker. Zero disables the breakers.
+	FailureThreshold int
+
+	// InitialBackoff is how long an opened breaker short-circuits operations
+	// before it lets a single probe through.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the backoff, which doubles whenever a probe fails.
+	MaxBackoff time.Duration
+}
+
+var (
+	breakerOptionsMu sync.RWMutex
+	breakerOptions   = BreakerOptions{FailureThreshold: 5, InitialBackoff: 5 * time.Second, MaxBackoff: 5 * time.Minute}
+)
+
+// SetBreakerOptions replaces the options of the circuit breakers.
+func SetBreakerOptions(o BreakerOptions) {
+	breakerOptionsMu.Lock()
+	defer breakerOptionsMu.Unlock()
+	breakerOptions = o
+}
+
+func currentBreakerOptions() BreakerOptions {
+	breakerOptionsMu.RLock()
+	defer breakerOptionsMu.RUnlock()
+	return breakerOptions
+}
+
+// States of a circuit breaker, as reported by keycloak_circuit_breaker_state.
+const (
+	breakerClosed   = 0
+	breakerOpen     = 1
+	breakerHalfOpen = 2
+)
+
+// breakers holds the circuit breaker of every provider configuration that
+// has seen a failure, keyed by keycloaksession.ConfigCacheKey. Like the rate
+// limit, it is shared by all ProviderConfigs that resolve to the same
+// configuration and by the managed resource and lookup paths.
+var breakers sync.Map // map[string]*breaker
+
+var breakerOpened = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "keycloak",
+	Subsystem: "circuit_breaker",
+	Name:      "opened_total",
+	Help:      "Number of times the circuit breaker of a provider configuration opened, including failed probes.",
+}, []string{"config"})
+
+// breaker is the circuit breaker of a single provider configuration. It is
+// closed while Keycloak answers, opens after FailureThreshold consecutive
+// failures and then lets a single probe through once its backoff elapsed
+// (half-open). A successful probe closes it, a failed one opens it again with
+// twice the backoff.
+type breaker struct {
+	mu        sync.Mutex
+	failures  int
+	backoff   time.Duration
+	openUntil time.Time
+	probing   bool
+}
+
+func breakerFor(key string) *breaker {
+	if v, ok := breakers.Load(key); ok {
+		return v.(*breaker)
+	}
+	v, _ := breakers.LoadOrStore(key, &breaker{})
+	return v.(*breaker)
+}
+
+// allow returns an error if the breaker is open. It reports whether the
+// caller was let through as the probe of a half-open breaker, in which case
+// it must either record the outcome of its operation or abandon the probe.
+func (b *breaker) allow(o BreakerOptions) (probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if o.FailureThreshold <= 0 || b.failures < o.FailureThreshold {
+		return false, nil
+	}
+	if wait := time.Until(b.openUntil); wait > 0 || b.probing {
+		if wait < 0 {
+			wait = 0
+		}
+		return false, conditions.WithReason(ReasonKeycloakUnavailable,
+			errors.Wrapf(ErrKeycloakUnavailable, errFmtCircuitOpen, b.failures, wait.Round(time.Second)))
+	}
+	b.probing = true
+	return true, nil
+}
+
+// record updates the breaker with the outcome of an operation. Only errors
+// that mean Keycloak is unavailable count as failures; Keycloak rejecting a
+// request proves that it is up.
+func (b *breaker) record(o BreakerOptions, label string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if !IsUnavailable(err) {
+		b.failures = 0
+		b.backoff = 0
+		b.openUntil = time.Time{}
+		return
+	}
+	b.failures++
+	if o.FailureThreshold <= 0 || b.failures < o.FailureThreshold {
+		return
+	}
+	switch {
+	case b.backoff == 0:
+		b.backoff = o.InitialBackoff
+	case b.backoff < o.MaxBackoff:
+		b.backoff = min(2*b.backoff, o.MaxBackoff)
+	}
+	b.openUntil = time.Now().Add(b.backoff)
+	breakerOpened.WithLabelValues(label).Inc()
+}
+
+// abandon releases the probe of a half-open breaker whose operation never
+// reached Keycloak, e.g. because it was cancelled, so another one can probe.
+func (b *breaker) abandon() {
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+func (b *breaker) state(o BreakerOptions) float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case o.FailureThreshold <= 0 || b.failures < o.FailureThreshold:
+		return breakerClosed
+	case b.probing || !time.Now().Before(b.openUntil):
+		return breakerHalfOpen
+	default:
+		return breakerOpen
+	}
+}
+
+// CheckAvailable returns an error with the KeycloakUnavailable reason if the
+// circuit breaker of the given configuration is open. If it lets the caller
+// probe a half-open breaker, the caller must report the outcome with
+// RecordResult.
+func CheckAvailable(key string) error {
+	_, err := breakerFor(key).allow(currentBreakerOptions())
+	return err
+}
+
+// Admit checks the circuit breaker and then the rate limit of the given
+// configuration, like Pool.Borrow does. If it lets the caller probe a
+// half-open breaker, the caller must report the outcome with RecordResult.
+func Admit(key string) error {
+	b := breakerFor(key)
+	probe, err := b.allow(currentBreakerOptions())
+	if err != nil {
+		return err
+	}
+	if err := Allow(key); err != nil {
+		if probe {
+			b.abandon()
+		}
+		return err
+	}
+	return nil
+}
+
+// RecordResult updates the circuit breaker of the given configuration with
+// the outcome of an operation against Keycloak.
+func RecordResult(key string, err error) {
+	breakerFor(key).record(currentBreakerOptions(), ConfigLabel(key), err)
+}
+
+// RemoveBreaker removes the circuit breaker of the given configuration.
+func RemoveBreaker(key string) {
+	breakers.Delete(key)
+	breakerOpened.DeleteLabelValues(ConfigLabel(key))
+}
+
+var breakerStateDesc = prometheus.NewDesc("keycloak_circuit_breaker_state",
+	"State of the circuit breaker of a provider configuration: 0 closed, 1 open, 2 half-open.", []string{"config"}, nil)
+
+// breakerCollector reports the state of every circuit breaker when scraped.
+type breakerCollector struct{}
+
+func (breakerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- breakerStateDesc
+}
+
+func (breakerCollector) Collect(ch chan<- prometheus.Metric) {
+	o := currentBreakerOptions()
+	breakers.Range(func(key, value any) bool {
+		ch <- prometheus.MustNewConstMetric(breakerStateDesc, prometheus.GaugeValue, value.(*breaker).state(o), ConfigLabel(key.(string)))
+		return true
+	})
+}
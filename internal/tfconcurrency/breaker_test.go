@@ -0,0 +1,151 @@
+/*
+Copyright 2021 Upbound Inc.
+*/
+
+package tfconcurrency
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/keycloak/terraform-provider-keycloak/keycloak"
+)
+
+func TestIsUnavailable(t *testing.T) {
+	tests := map[string]struct {
+		err         error
+		connection  bool
+		unavailable bool
+	}{
+		"Nil":         {err: nil},
+		"NetError":    {err: &net.OpError{Op: "dial", Err: errors.New("refused")}, connection: true, unavailable: true},
+		"AsText":      {err: errors.New(`error sending POST request to https://site-a/realms/master/protocol/openid-connect/token: dial tcp 10.0.0.1:443: connect: connection refused`), connection: true, unavailable: true},
+		"Forbidden":   {err: errors.New("error sending GET request to /admin/realms: 403 Forbidden"), connection: false, unavailable: false},
+		"ServerError": {err: errors.New("error sending GET request to /admin/realms: 503 Service Unavailable. Response body: "), connection: false, unavailable: true},
+		"APIError":    {err: &keycloak.ApiError{Code: 502, Message: "bad gateway"}, connection: false, unavailable: true},
+		"NotFound":    {err: &keycloak.ApiError{Code: 404, Message: "not found"}, connection: false, unavailable: false},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := IsConnectionError(tc.err); got != tc.connection {
+				t.Errorf("IsConnectionError(%v) = %v, want %v", tc.err, got, tc.connection)
+			}
+			if got := IsUnavailable(tc.err); got != tc.unavailable {
+				t.Errorf("IsUnavailable(%v) = %v, want %v", tc.err, got, tc.unavailable)
+			}
+		})
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	o := BreakerOptions{FailureThreshold: 2, InitialBackoff: 20 * time.Millisecond, MaxBackoff: 30 * time.Millisecond}
+	down := errors.New("dial tcp 10.0.0.1:443: connect: connection refused")
+	b := &breaker{}
+
+	// Errors other than unavailability do not count.
+	b.record(o, "test", errors.New("403 Forbidden"))
+	b.record(o, "test", down)
+	if _, err := b.allow(o); err != nil {
+		t.Fatalf("allow() below the threshold = %v, want nil", err)
+	}
+
+	// The threshold opens the breaker.
+	b.record(o, "test", down)
+	_, err := b.allow(o)
+	if !errors.Is(err, ErrKeycloakUnavailable) {
+		t.Fatalf("allow() when open = %v, want %v", err, ErrKeycloakUnavailable)
+	}
+	if !strings.Contains(err.Error(), string(ReasonKeycloakUnavailable)+": ") {
+		t.Errorf("allow() error %q does not carry the %s reason", err, ReasonKeycloakUnavailable)
+	}
+	if got := b.state(o); got != breakerOpen {
+		t.Errorf("state() = %v, want open", got)
+	}
+
+	// Once the backoff elapsed a single probe is let through.
+	time.Sleep(o.InitialBackoff)
+	if probe, err := b.allow(o); !probe || err != nil {
+		t.Fatalf("allow() when half-open = %v, %v, want a probe", probe, err)
+	}
+	if _, err := b.allow(o); err == nil {
+		t.Fatal("allow() during a probe = nil, want an error")
+	}
+
+	// A failed probe opens the breaker again with twice the backoff, capped.
+	b.record(o, "test", down)
+	if b.backoff != o.MaxBackoff {
+		t.Errorf("backoff after a failed probe = %s, want %s", b.backoff, o.MaxBackoff)
+	}
+	if _, err := b.allow(o); err == nil {
+		t.Fatal("allow() after a failed probe = nil, want an error")
+	}
+
+	// A successful probe closes it.
+	time.Sleep(o.MaxBackoff)
+	if probe, _ := b.allow(o); !probe {
+		t.Fatal("allow() = no probe, want a probe")
+	}
+	b.record(o, "test", nil)
+	if got := b.state(o); got != breakerClosed {
+		t.Errorf("state() after a successful probe = %v, want closed", got)
+	}
+}
+
+func TestPoolBorrowBreaker(t *testing.T) {
+	const key = "breaker-pool"
+	SetBreakerOptions(BreakerOptions{FailureThreshold: 2, InitialBackoff: time.Hour, MaxBackoff: time.Hour})
+	t.Cleanup(func() {
+		SetBreakerOptions(BreakerOptions{FailureThreshold: 5, InitialBackoff: 5 * time.Second, MaxBackoff: 5 * time.Minute})
+		RemoveBreaker(key)
+	})
+
+	logins := 0
+	p := NewPool(2, func(context.Context) (*keycloak.KeycloakClient, error) {
+		logins++
+		return nil, errors.New("error sending POST request to https://keycloak/realms/master/protocol/openid-connect/token: 502 Bad Gateway")
+	})
+	p.SetConfigKey(key)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := p.Borrow(ctx); err == nil || errors.Is(err, ErrKeycloakUnavailable) {
+			t.Fatalf("borrow %d: error = %v, want the login error", i, err)
+		}
+	}
+	// The open breaker short-circuits borrowers without logging in.
+	if _, err := p.Borrow(ctx); !errors.Is(err, ErrKeycloakUnavailable) {
+		t.Fatalf("Borrow() error = %v, want %v", err, ErrKeycloakUnavailable)
+	}
+	if err := CheckAvailable(key); !errors.Is(err, ErrKeycloakUnavailable) {
+		t.Fatalf("CheckAvailable() = %v, want %v", err, ErrKeycloakUnavailable)
+	}
+	if logins != 2 {
+		t.Errorf("logins = %d, want 2", logins)
+	}
+	if got := len(p.sem); got != 0 {
+		t.Errorf("held slots = %d, want 0", got)
+	}
+}
+
+func TestPoolReleaseRecordsResult(t *testing.T) {
+	const key = "breaker-release"
+	t.Cleanup(func() { RemoveBreaker(key) })
+	p := NewPool(1, offlineFactory())
+	p.SetConfigKey(key)
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		c, err := p.Borrow(ctx)
+		if err != nil {
+			t.Fatalf("borrow %d: %v", i, err)
+		}
+		p.Release(c, errors.New("504 Gateway Timeout"))
+	}
+	if _, err := p.Borrow(ctx); !errors.Is(err, ErrKeycloakUnavailable) {
+		t.Fatalf("Borrow() error = %v, want %v", err, ErrKeycloakUnavailable)
+	}
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2021 Upbound Inc.
+*/
+
+package tfconcurrency
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Reasons of the keycloak_pool_borrow_failures_total metric.
+const (
+	borrowFailureThrottled   = "throttled"
+	borrowFailureCanceled    = "canceled"
+	borrowFailureClosed      = "closed"
+	borrowFailureCreate      = "create"
+	borrowFailureUnavailable = "unavailable"
+)
+
+var (
+	borrowWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "keycloak",
+		Subsystem: "pool",
+		Name:      "borrow_wait_seconds",
+		Help:      "Time Borrow took to hand out a client, including waiting for a free slot and creating the client.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 9),
+	}, []string{"config"})
+
+	borrowHold = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "keycloak",
+		Subsystem: "pool",
+		Name:      "borrow_hold_seconds",
+		Help:      "Time a client was borrowed for, i.e. the duration of the Keycloak operation it ran.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 4, 8),
+	}, []string{"config"})
+
+	borrowFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "keycloak",
+		Subsystem: "pool",
+		Name:      "borrow_failures_total",
+		Help:      "Number of borrows that did not return a client, by reason.",
+	}, []string{"config", "reason"})
+
+	saturated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "keycloak",
+		Subsystem: "pool",
+		Name:      "saturated_total",
+		Help:      "Number of borrows that found every client of the pool in use and had to wait for one.",
+	}, []string{"config"})
+
+	createDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "keycloak",
+		Subsystem: "pool",
+		Name:      "client_create_seconds",
+		Help:      "Time it took to create and log in a pooled client.",
+	}, []string{"config"})
+
+	createFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "keycloak",
+		Subsystem: "pool",
+		Name:      "client_create_failures_total",
+		Help:      "Number of pooled clients whose creation or login failed.",
+	}, []string{"config"})
+
+	retired = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "keycloak",
+		Subsystem: "pool",
+		Name:      "clients_retired_total",
+		Help:      "Number of clients dropped from the pool after an operation instead of being reused.",
+	}, []string{"config"})
+)
+
+// deletePoolMetrics removes the series of the pool with the given
+// configuration label once the pool is closed.
+func deletePoolMetrics(label string) {
+	for _, v := range []*prometheus.HistogramVec{borrowWait, borrowHold, createDuration} {
+		v.DeleteLabelValues(label)
+	}
+	for _, v := range []*prometheus.CounterVec{saturated, createFailures, retired} {
+		v.DeleteLabelValues(label)
+	}
+	borrowFailures.DeletePartialMatch(prometheus.Labels{"config": label})
+}
+
+var (
+	poolSizeDesc = prometheus.NewDesc("keycloak_pool_size",
+		"Maximum number of clients of the pool of a provider configuration.", []string{"config"}, nil)
+	poolClientsDesc = prometheus.NewDesc("keycloak_pool_clients",
+		"Number of clients the pool of a provider configuration currently owns.", []string{"config"}, nil)
+	poolIdleDesc = prometheus.NewDesc("keycloak_pool_idle_clients",
+		"Number of idle clients in the pool of a provider configuration.", []string{"config"}, nil)
+	poolInUseDesc = prometheus.NewDesc("keycloak_pool_in_use_clients",
+		"Number of borrowed clients of the pool of a provider configuration.", []string{"config"}, nil)
+	poolWaitingDesc = prometheus.NewDesc("keycloak_pool_waiting_borrowers",
+		"Number of operations waiting for a client of the pool of a provider configuration.", []string{"config"}, nil)
+)
+
+// poolCollector reports the state of every registered pool when scraped.
+type poolCollector struct{}
+
+func (poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolSizeDesc
+	ch <- poolClientsDesc
+	ch <- poolIdleDesc
+	ch <- poolInUseDesc
+	ch <- poolWaitingDesc
+}
+
+func (poolCollector) Collect(ch chan<- prometheus.Metric) {
+	pools.Range(func(key, value any) bool {
+		p := value.(*Pool)
+		label := ConfigLabel(key.(string))
+		clients, idle, inUse := p.stats()
+		ch <- prometheus.MustNewConstMetric(poolSizeDesc, prometheus.GaugeValue, float64(cap(p.sem)), label)
+		ch <- prometheus.MustNewConstMetric(poolClientsDesc, prometheus.GaugeValue, float64(clients), label)
+		ch <- prometheus.MustNewConstMetric(poolIdleDesc, prometheus.GaugeValue, float64(idle), label)
+		ch <- prometheus.MustNewConstMetric(poolInUseDesc, prometheus.GaugeValue, float64(inUse), label)
+		ch <- prometheus.MustNewConstMetric(poolWaitingDesc, prometheus.GaugeValue, float64(p.waiting.Load()), label)
+		return true
+	})
+}
+
+// Collectors returns the Prometheus collectors of this package. They must be
+// registered once, e.g. with the controller-runtime metrics registry.
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		throttled, rateLimitCollector{},
+		breakerOpened, breakerCollector{},
+		borrowWait, borrowHold, borrowFailures, saturated, createDuration, createFailures, retired, poolCollector{},
+	}
+}
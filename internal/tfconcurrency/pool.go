@@ -26,11 +26,23 @@ package tfconcurrency
 
 import (
 	"context"
+	"errors"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/keycloak/terraform-provider-keycloak/keycloak"
 )
 
+// ErrPoolClosed is returned by Borrow once the pool has been closed, e.g.
+// because the credentials of its provider configuration were rotated.
+var ErrPoolClosed = errors.New("keycloak client pool is closed")
+
+// RetireFunc decides after an operation whether the client it ran on must be
+// dropped from the pool instead of being reused, e.g. because its endpoint is
+// no longer reachable. err is the error the operation ended with, if any.
+type RetireFunc func(c *keycloak.KeycloakClient, err error) bool
+
 // ClientFactory creates a new, independent *keycloak.KeycloakClient for a
 // single provider configuration. Implementations typically build the client
 // exactly the way the provider setup does (same config, same login), so that
@@ -58,10 +70,27 @@ type Pool struct {
 	// N concurrent logins at once.
 	createMu sync.Mutex
 
-	// mu guards idle and all.
-	mu   sync.Mutex
-	idle []*keycloak.KeycloakClient // returned clients available for reuse
-	all  []*keycloak.KeycloakClient // every client the pool owns (for Close)
+	// configKey is the cache key of the pool's configuration. It selects the
+	// token bucket Borrow takes a token from and labels the pool's metrics.
+	// Empty if the pool is neither rate limited nor instrumented.
+	configKey string
+
+	// retire, if set, is consulted by Release.
+	retire RetireFunc
+
+	// waiting is the number of borrowers blocked on a capacity slot.
+	waiting atomic.Int64
+
+	// mu guards idle, idleSince, borrowedAt, all, retired and closed.
+	mu         sync.Mutex
+	idle       []*keycloak.KeycloakClient // returned clients available for reuse
+	idleSince  map[*keycloak.KeycloakClient]time.Time
+	borrowedAt map[*keycloak.KeycloakClient]time.Time
+	all        []*keycloak.KeycloakClient // every client the pool owns (for Close)
+	// retired holds the clients that were still borrowed when Close gave up
+	// waiting for them. They are logged out, so they are never reused.
+	retired map[*keycloak.KeycloakClient]struct{}
+	closed  bool
 }
 
 // NewPool returns a pool that will hold at most size clients, created on
@@ -71,8 +100,11 @@ func NewPool(size int, factory ClientFactory) *Pool {
 		size = 1
 	}
 	return &Pool{
-		sem:     make(chan struct{}, size),
-		factory: factory,
+		sem:        make(chan struct{}, size),
+		factory:    factory,
+		idleSince:  make(map[*keycloak.KeycloakClient]time.Time),
+		borrowedAt: make(map[*keycloak.KeycloakClient]time.Time),
+		retired:    make(map[*keycloak.KeycloakClient]struct{}),
 	}
 }
 
@@ -86,40 +118,104 @@ func (p *Pool) Seed(c *keycloak.KeycloakClient) {
 	}
 	p.mu.Lock()
 	p.idle = append(p.idle, c)
+	p.idleSince[c] = time.Now()
 	p.all = append(p.all, c)
 	p.mu.Unlock()
 }
 
+// SetConfigKey sets the cache key of the pool's configuration. Borrow then
+// takes a token from the rate limit bucket of the configuration (see
+// SetRateLimit), and the pool's metrics are labelled with it. It must be
+// called before the pool is shared.
+func (p *Pool) SetConfigKey(key string) {
+	p.configKey = key
+}
+
+// SetRetire sets the function Release consults before a client is reused.
+// It must be called before the pool is shared.
+func (p *Pool) SetRetire(f RetireFunc) {
+	p.retire = f
+}
+
 // Borrow returns a client for exclusive use by the caller until Return is
 // called. It blocks if the pool is at capacity, returning early only if ctx is
-// cancelled. On any error the borrow slot is released so the pool does not leak
-// capacity.
-func (p *Pool) Borrow(ctx context.Context) (*keycloak.KeycloakClient, error) {
-	// Acquire a capacity slot (bounds concurrency to the pool size).
+// cancelled. It fails with ErrKeycloakUnavailable without blocking if the
+// circuit breaker of the pool's configuration is open, and with ErrThrottled
+// if its rate limit is exhausted. On any error the borrow slot is released so the
+// pool does not leak capacity.
+func (p *Pool) Borrow(ctx context.Context) (c *keycloak.KeycloakClient, err error) {
+	label := ConfigLabel(p.configKey)
+	if p.configKey != "" {
+		// The breaker is checked first, so that operations that are turned
+		// away while Keycloak is down do not use up the rate limit.
+		b := breakerFor(p.configKey)
+		probe, err := b.allow(currentBreakerOptions())
+		if err != nil {
+			borrowFailures.WithLabelValues(label, borrowFailureUnavailable).Inc()
+			return nil, err
+		}
+		if probe {
+			// The probe ends with the operation, see Release, unless no
+			// operation is run at all.
+			defer func() {
+				if err != nil {
+					b.abandon()
+				}
+			}()
+		}
+		if err := Allow(p.configKey); err != nil {
+			borrowFailures.WithLabelValues(label, borrowFailureThrottled).Inc()
+			return nil, err
+		}
+	}
+
+	// Acquire a capacity slot (bounds concurrency to the pool size). If none
+	// is free the pool is saturated and the borrower queues for one.
+	start := time.Now()
 	select {
 	case p.sem <- struct{}{}:
-	case <-ctx.Done():
-		return nil, ctx.Err()
+	default:
+		saturated.WithLabelValues(label).Inc()
+		p.waiting.Add(1)
+		select {
+		case p.sem <- struct{}{}:
+			p.waiting.Add(-1)
+		case <-ctx.Done():
+			p.waiting.Add(-1)
+			borrowFailures.WithLabelValues(label, borrowFailureCanceled).Inc()
+			return nil, ctx.Err()
+		}
 	}
 
 	// Reuse an idle client if one is available.
 	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		<-p.sem
+		borrowFailures.WithLabelValues(label, borrowFailureClosed).Inc()
+		return nil, ErrPoolClosed
+	}
 	if n := len(p.idle); n > 0 {
 		c := p.idle[n-1]
 		p.idle[n-1] = nil
 		p.idle = p.idle[:n-1]
+		delete(p.idleSince, c)
+		p.borrowedAt[c] = time.Now()
 		p.mu.Unlock()
+		borrowWait.WithLabelValues(label).Observe(time.Since(start).Seconds())
 		return c, nil
 	}
 	p.mu.Unlock()
 
 	// None idle: create a new one (serialized). We hold the slot throughout, so
 	// the total number of clients can never exceed the pool size.
-	c, err := p.create(ctx)
+	c, err = p.create(ctx)
 	if err != nil {
 		<-p.sem // release the slot we acquired
+		borrowFailures.WithLabelValues(label, borrowFailureCreate).Inc()
 		return nil, err
 	}
+	borrowWait.WithLabelValues(label).Observe(time.Since(start).Seconds())
 	return c, nil
 }
 
@@ -127,27 +223,109 @@ func (p *Pool) create(ctx context.Context) (*keycloak.KeycloakClient, error) {
 	p.createMu.Lock()
 	defer p.createMu.Unlock()
 
+	label := ConfigLabel(p.configKey)
+	start := time.Now()
 	c, err := p.factory(ctx)
+	createDuration.WithLabelValues(label).Observe(time.Since(start).Seconds())
+	if p.configKey != "" {
+		RecordResult(p.configKey, err)
+	}
 	if err != nil {
+		createFailures.WithLabelValues(label).Inc()
 		return nil, err
 	}
 	p.mu.Lock()
 	p.all = append(p.all, c)
+	p.borrowedAt[c] = time.Now()
 	p.mu.Unlock()
 	return c, nil
 }
 
 // Return releases a previously borrowed client back to the pool for reuse and
-// frees a capacity slot. Returning a nil client only frees the slot.
+// frees a capacity slot. Returning a nil client only frees the slot, and so
+// does returning a client to a closed pool, or one that Close retired.
 func (p *Pool) Return(c *keycloak.KeycloakClient) {
 	if c != nil {
 		p.mu.Lock()
-		p.idle = append(p.idle, c)
+		p.observeHold(c)
+		_, retired := p.retired[c]
+		delete(p.retired, c)
+		if !retired && !p.closed {
+			p.idle = append(p.idle, c)
+			p.idleSince[c] = time.Now()
+		}
 		p.mu.Unlock()
 	}
 	<-p.sem
 }
 
+// observeHold records how long c was borrowed. p.mu must be held.
+func (p *Pool) observeHold(c *keycloak.KeycloakClient) {
+	if at, ok := p.borrowedAt[c]; ok {
+		delete(p.borrowedAt, c)
+		borrowHold.WithLabelValues(ConfigLabel(p.configKey)).Observe(time.Since(at).Seconds())
+	}
+}
+
+// Release ends a borrow like Return, passing the error the operation ended
+// with, which updates the circuit breaker of the pool's configuration. If the
+// pool's RetireFunc decides that the client must not be reused, the client is
+// dropped from the pool instead and the next Borrow creates a new one in its
+// place.
+func (p *Pool) Release(c *keycloak.KeycloakClient, err error) {
+	if p.configKey != "" {
+		RecordResult(p.configKey, err)
+	}
+	if c == nil || p.retire == nil || !p.retire(c, err) {
+		p.Return(c)
+		return
+	}
+	p.mu.Lock()
+	p.observeHold(c)
+	p.remove(c)
+	p.mu.Unlock()
+	retired.WithLabelValues(ConfigLabel(p.configKey)).Inc()
+	<-p.sem
+}
+
+// remove drops c from the clients the pool owns. p.mu must be held.
+func (p *Pool) remove(c *keycloak.KeycloakClient) {
+	for i, o := range p.all {
+		if o == c {
+			p.all = append(p.all[:i], p.all[i+1:]...)
+			break
+		}
+	}
+}
+
+// Shrink drops the clients that have been idle for longer than maxIdle, so
+// that a pool grown by a burst of reconciliations does not keep every login
+// session open forever. The pool always keeps at least one client. The
+// dropped clients are returned so the caller can log out their sessions.
+func (p *Pool) Shrink(maxIdle time.Duration) []*keycloak.KeycloakClient {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	var dropped []*keycloak.KeycloakClient
+	kept := p.idle[:0]
+	for _, c := range p.idle {
+		if len(p.all) > 1 && time.Since(p.idleSince[c]) > maxIdle {
+			delete(p.idleSince, c)
+			p.remove(c)
+			dropped = append(dropped, c)
+			continue
+		}
+		kept = append(kept, c)
+	}
+	for i := len(kept); i < len(p.idle); i++ {
+		p.idle[i] = nil
+	}
+	p.idle = kept
+	return dropped
+}
+
 // Clients returns a snapshot of every client the pool owns. It is used for
 // lifecycle operations such as logout on shutdown.
 func (p *Pool) Clients() []*keycloak.KeycloakClient {
@@ -157,3 +335,53 @@ func (p *Pool) Clients() []*keycloak.KeycloakClient {
 	copy(out, p.all)
 	return out
 }
+
+// Close stops the pool from handing out clients and drains it: it waits until
+// every borrowed client has been returned, or ctx is done, and then returns
+// every client the pool owns so the caller can log out their sessions. Clients
+// that are still borrowed when ctx is done are retired: they are not taken
+// back once returned. Borrow fails with ErrPoolClosed from then on. Close is
+// idempotent.
+func (p *Pool) Close(ctx context.Context) []*keycloak.KeycloakClient {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+
+	// Acquire every capacity slot. Once all are held no client is borrowed any
+	// more. Borrowers that are still queued for a slot see the closed flag and
+	// release theirs again.
+	held := 0
+drain:
+	for held < cap(p.sem) {
+		select {
+		case p.sem <- struct{}{}:
+			held++
+		case <-ctx.Done():
+			break drain
+		}
+	}
+	for ; held > 0; held-- {
+		<-p.sem
+	}
+
+	p.mu.Lock()
+	p.idle = nil
+	p.idleSince = make(map[*keycloak.KeycloakClient]time.Time)
+	for c := range p.borrowedAt {
+		p.retired[c] = struct{}{}
+	}
+	p.borrowedAt = make(map[*keycloak.KeycloakClient]time.Time)
+	p.mu.Unlock()
+	if p.configKey != "" {
+		deletePoolMetrics(ConfigLabel(p.configKey))
+	}
+	return p.Clients()
+}
+
+// stats returns the number of clients the pool owns, of idle ones, and of
+// borrowed ones.
+func (p *Pool) stats() (clients, idle, inUse int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.all), len(p.idle), len(p.sem)
+}
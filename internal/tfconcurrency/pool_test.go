@@ -6,11 +6,13 @@ package tfconcurrency
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/keycloak/terraform-provider-keycloak/keycloak"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 // newOfflineClient builds a real *keycloak.KeycloakClient that performs no
@@ -131,3 +133,195 @@ func TestPoolConcurrentBorrowReturn(t *testing.T) {
 		t.Fatalf("pool created %d clients, want <= %d", got, size)
 	}
 }
+
+func TestPoolCloseDrainsBorrowedClients(t *testing.T) {
+	p := NewPool(2, offlineFactory())
+	ctx := context.Background()
+
+	c1, err := p.Borrow(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	closed := make(chan []*keycloak.KeycloakClient, 1)
+	go func() {
+		closed <- p.Close(ctx)
+	}()
+
+	select {
+	case <-closed:
+		t.Fatal("Close should wait for the borrowed client to be returned")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	p.Return(c1)
+	select {
+	case got := <-closed:
+		if len(got) != 1 || got[0] != c1 {
+			t.Fatalf("Close returned %v, want the single pooled client", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close should return once the borrowed client is returned")
+	}
+
+	if _, err := p.Borrow(ctx); err != ErrPoolClosed {
+		t.Fatalf("Borrow after Close: got %v, want ErrPoolClosed", err)
+	}
+}
+
+func TestPoolCloseRespectsContextCancellation(t *testing.T) {
+	p := NewPool(1, offlineFactory())
+	ctx := context.Background()
+
+	c1, _ := p.Borrow(ctx)
+
+	cctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if got := p.Close(cctx); len(got) != 1 {
+		t.Fatalf("Close returned %d clients, want 1", len(got))
+	}
+
+	// The client that was still borrowed is logged out by the caller of
+	// Close, so the pool does not take it back.
+	p.Return(c1)
+	if len(p.idle) != 0 || len(p.retired) != 0 {
+		t.Errorf("idle = %v, retired = %v after returning a retired client, want both empty", p.idle, p.retired)
+	}
+	if got := len(p.sem); got != 0 {
+		t.Errorf("held slots = %d, want 0", got)
+	}
+}
+
+func TestPoolReleaseRetiresClient(t *testing.T) {
+	p := NewPool(1, offlineFactory())
+	errUnreachable := errors.New("dial tcp: connection refused")
+	p.SetRetire(func(_ *keycloak.KeycloakClient, err error) bool {
+		return errors.Is(err, errUnreachable)
+	})
+	ctx := context.Background()
+
+	c1, err := p.Borrow(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Release(c1, nil)
+
+	c2, err := p.Borrow(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c1 != c2 {
+		t.Fatal("expected a client released without error to be reused")
+	}
+	p.Release(c2, errUnreachable)
+
+	if got := len(p.Clients()); got != 0 {
+		t.Fatalf("expected the retired client to be dropped, pool owns %d", got)
+	}
+	c3, err := p.Borrow(ctx)
+	if err != nil {
+		t.Fatalf("Borrow after retiring the only client: %v", err)
+	}
+	if c3 == c2 {
+		t.Fatal("expected a new client in place of the retired one")
+	}
+	p.Release(c3, nil)
+}
+
+func TestPoolShrinkDropsIdleClients(t *testing.T) {
+	p := NewPool(3, offlineFactory())
+	ctx := context.Background()
+
+	borrowed := make([]*keycloak.KeycloakClient, 0, 3)
+	for i := 0; i < 3; i++ {
+		c, err := p.Borrow(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		borrowed = append(borrowed, c)
+	}
+	// Keep one client borrowed: Shrink must never drop it.
+	p.Return(borrowed[0])
+	p.Return(borrowed[1])
+
+	if got := p.Shrink(time.Hour); len(got) != 0 {
+		t.Fatalf("expected no client to be dropped before the idle timeout, got %d", len(got))
+	}
+	if got := p.Shrink(0); len(got) != 2 {
+		t.Fatalf("expected both idle clients to be dropped, got %d", len(got))
+	}
+	if got := len(p.Clients()); got != 1 {
+		t.Fatalf("expected the borrowed client to be kept, pool owns %d", got)
+	}
+
+	p.Return(borrowed[2])
+	if got := p.Shrink(0); len(got) != 0 {
+		t.Fatalf("expected the last client to be kept, dropped %d", len(got))
+	}
+}
+
+func TestPoolMetrics(t *testing.T) {
+	const key = "pool-metrics-config"
+	label := ConfigLabel(key)
+	p := NewPool(1, offlineFactory())
+	p.SetConfigKey(key)
+	RegisterKey(key, p)
+	t.Cleanup(func() { UnregisterKey(key, p) })
+	ctx := context.Background()
+
+	c, err := p.Borrow(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clients, idle, inUse := p.stats(); clients != 1 || idle != 0 || inUse != 1 {
+		t.Fatalf("stats() = %d, %d, %d, want 1, 0, 1", clients, idle, inUse)
+	}
+
+	// The pool is saturated, so a second borrow queues until it gives up.
+	wctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := p.Borrow(wctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Borrow() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if got := testutil.ToFloat64(saturated.WithLabelValues(label)); got != 1 {
+		t.Errorf("saturated = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(borrowFailures.WithLabelValues(label, borrowFailureCanceled)); got != 1 {
+		t.Errorf("canceled borrows = %v, want 1", got)
+	}
+	if got := p.waiting.Load(); got != 0 {
+		t.Errorf("waiting = %d, want 0", got)
+	}
+
+	p.Return(c)
+	if got := len(p.borrowedAt); got != 0 {
+		t.Errorf("borrowed clients after Return = %d, want 0", got)
+	}
+	if got := testutil.CollectAndCount(poolCollector{}, "keycloak_pool_idle_clients"); got < 1 {
+		t.Errorf("idle clients series = %d, want at least 1", got)
+	}
+
+	// Closing the pool removes its series.
+	p.Close(ctx)
+	if saturated.DeleteLabelValues(label) {
+		t.Error("saturated series still exists after Close")
+	}
+}
+
+func TestPoolMetricsCreateFailure(t *testing.T) {
+	const key = "pool-metrics-create-failure"
+	p := NewPool(1, func(context.Context) (*keycloak.KeycloakClient, error) {
+		return nil, errors.New("login failed")
+	})
+	p.SetConfigKey(key)
+	if _, err := p.Borrow(context.Background()); err == nil {
+		t.Fatal("Borrow() succeeded, want an error")
+	}
+	label := ConfigLabel(key)
+	if got := testutil.ToFloat64(createFailures.WithLabelValues(label)); got != 1 {
+		t.Errorf("create failures = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(borrowFailures.WithLabelValues(label, borrowFailureCreate)); got != 1 {
+		t.Errorf("create borrow failures = %v, want 1", got)
+	}
+}
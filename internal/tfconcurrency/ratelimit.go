@@ -0,0 +1,113 @@
+/*
+Copyright 2021 Upbound Inc.
+*/
+
+package tfconcurrency
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+)
+
+// ErrThrottled is returned instead of a client when the rate limit of a
+// provider configuration is exhausted. It does not wait for a token, so that
+// the operation fails fast and is retried on a later reconciliation instead of
+// blocking a worker.
+var ErrThrottled = errors.New("keycloak rate limit exceeded, retrying later")
+
+// limiters holds the token bucket of every rate limited provider
+// configuration, keyed by keycloaksession.ConfigCacheKey. ProviderConfigs that
+// resolve to the same configuration share one bucket, as do the managed
+// resource and lookup paths that use it.
+var limiters sync.Map // map[string]*rate.Limiter
+
+var throttled = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "keycloak",
+	Subsystem: "rate_limit",
+	Name:      "throttled_total",
+	Help:      "Number of operations rejected by the rate limit of a provider configuration.",
+}, []string{"config"})
+
+// SetRateLimit sets the sustained rate and burst of the token bucket of the
+// given configuration, creating the bucket on first use. A non-positive qps
+// removes the limit. A non-positive burst defaults to qps.
+func SetRateLimit(key string, qps, burst int) {
+	if qps <= 0 {
+		RemoveRateLimit(key)
+		return
+	}
+	if burst <= 0 {
+		burst = qps
+	}
+	if v, ok := limiters.Load(key); ok {
+		l := v.(*rate.Limiter)
+		if l.Limit() != rate.Limit(qps) {
+			l.SetLimit(rate.Limit(qps))
+		}
+		if l.Burst() != burst {
+			l.SetBurst(burst)
+		}
+		return
+	}
+	limiters.LoadOrStore(key, rate.NewLimiter(rate.Limit(qps), burst))
+}
+
+// RemoveRateLimit removes the token bucket of the given configuration.
+func RemoveRateLimit(key string) {
+	limiters.Delete(key)
+	throttled.DeleteLabelValues(ConfigLabel(key))
+}
+
+// Allow takes a token from the bucket of the given configuration. It returns
+// ErrThrottled if the bucket is empty, and nil if it is not or if the
+// configuration is not rate limited.
+func Allow(key string) error {
+	v, ok := limiters.Load(key)
+	if !ok || v.(*rate.Limiter).Allow() {
+		return nil
+	}
+	throttled.WithLabelValues(ConfigLabel(key)).Inc()
+	return ErrThrottled
+}
+
+// ConfigLabel shortens a configuration cache key to a metric label. The key is
+// a SHA-256 hash, so a prefix is unique enough and does not reveal anything
+// about the configuration.
+func ConfigLabel(key string) string {
+	if len(key) > 12 {
+		return key[:12]
+	}
+	return key
+}
+
+var (
+	rateLimitQPSDesc = prometheus.NewDesc("keycloak_rate_limit_qps",
+		"Sustained operations per second allowed for a provider configuration.", []string{"config"}, nil)
+	rateLimitBurstDesc = prometheus.NewDesc("keycloak_rate_limit_burst",
+		"Burst size of the rate limit of a provider configuration.", []string{"config"}, nil)
+	rateLimitTokensDesc = prometheus.NewDesc("keycloak_rate_limit_tokens",
+		"Tokens currently available in the rate limit bucket of a provider configuration.", []string{"config"}, nil)
+)
+
+// rateLimitCollector reports the state of every token bucket when scraped.
+type rateLimitCollector struct{}
+
+func (rateLimitCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- rateLimitQPSDesc
+	ch <- rateLimitBurstDesc
+	ch <- rateLimitTokensDesc
+}
+
+func (rateLimitCollector) Collect(ch chan<- prometheus.Metric) {
+	limiters.Range(func(key, value any) bool {
+		l := value.(*rate.Limiter)
+		label := ConfigLabel(key.(string))
+		ch <- prometheus.MustNewConstMetric(rateLimitQPSDesc, prometheus.GaugeValue, float64(l.Limit()), label)
+		ch <- prometheus.MustNewConstMetric(rateLimitBurstDesc, prometheus.GaugeValue, float64(l.Burst()), label)
+		ch <- prometheus.MustNewConstMetric(rateLimitTokensDesc, prometheus.GaugeValue, l.Tokens(), label)
+		return true
+	})
+}
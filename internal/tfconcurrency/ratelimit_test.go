@@ -0,0 +1,89 @@
+/*
+Copyright 2021 Upbound Inc.
+*/
+
+package tfconcurrency
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPoolBorrowThrottled(t *testing.T) {
+	const key = "throttled-pool"
+	SetRateLimit(key, 1, 2)
+	t.Cleanup(func() { RemoveRateLimit(key) })
+
+	p := NewPool(4, offlineFactory())
+	p.SetConfigKey(key)
+	ctx := context.Background()
+
+	// The burst allows two borrows in quick succession.
+	for i := 0; i < 2; i++ {
+		c, err := p.Borrow(ctx)
+		if err != nil {
+			t.Fatalf("borrow %d: %v", i, err)
+		}
+		p.Return(c)
+	}
+
+	// The third fails fast instead of waiting for a token, and does not hold
+	// a capacity slot.
+	if _, err := p.Borrow(ctx); !errors.Is(err, ErrThrottled) {
+		t.Fatalf("Borrow() error = %v, want %v", err, ErrThrottled)
+	}
+	if got := len(p.sem); got != 0 {
+		t.Fatalf("held slots = %d, want 0", got)
+	}
+}
+
+func TestSetRateLimit(t *testing.T) {
+	const key = "set-rate-limit"
+	t.Cleanup(func() { RemoveRateLimit(key) })
+
+	if err := Allow(key); err != nil {
+		t.Fatalf("Allow() without a limit = %v, want nil", err)
+	}
+
+	SetRateLimit(key, 1, 0)
+	if err := Allow(key); err != nil {
+		t.Fatalf("first Allow() = %v, want nil", err)
+	}
+	if err := Allow(key); !errors.Is(err, ErrThrottled) {
+		t.Fatalf("second Allow() = %v, want %v", err, ErrThrottled)
+	}
+
+	// Removing the limit lets every operation through again.
+	SetRateLimit(key, 0, 0)
+	for i := 0; i < 5; i++ {
+		if err := Allow(key); err != nil {
+			t.Fatalf("Allow() after removing the limit = %v, want nil", err)
+		}
+	}
+}
+
+func TestPoolBorrowBreakerBeforeRateLimit(t *testing.T) {
+	const key = "breaker-before-rate-limit"
+	SetBreakerOptions(BreakerOptions{FailureThreshold: 1, InitialBackoff: time.Hour, MaxBackoff: time.Hour})
+	SetRateLimit(key, 1, 1)
+	t.Cleanup(func() {
+		SetBreakerOptions(BreakerOptions{FailureThreshold: 5, InitialBackoff: 5 * time.Second, MaxBackoff: 5 * time.Minute})
+		RemoveBreaker(key)
+		RemoveRateLimit(key)
+	})
+	RecordResult(key, errors.New("dial tcp 10.0.0.1:443: connect: connection refused"))
+
+	p := NewPool(1, offlineFactory())
+	p.SetConfigKey(key)
+	for i := 0; i < 3; i++ {
+		if _, err := p.Borrow(context.Background()); !errors.Is(err, ErrKeycloakUnavailable) {
+			t.Fatalf("borrow %d: error = %v, want %v", i, err, ErrKeycloakUnavailable)
+		}
+	}
+	// Borrowers turned away by the open breaker leave the token in place.
+	if err := Allow(key); err != nil {
+		t.Errorf("Allow() = %v, want the token left by the rejected borrows", err)
+	}
+}
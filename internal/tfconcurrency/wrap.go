@@ -6,7 +6,10 @@ package tfconcurrency
 
 import (
 	"context"
+	"errors"
+	"runtime"
 	"sync"
+	"weak"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
@@ -24,6 +27,19 @@ var registry sync.Map // map[*keycloak.KeycloakClient]*Pool
 // wrapped callbacks are still race-free (serialized) as a defensive fallback.
 var fallbackMu sync.Map // map[*keycloak.KeycloakClient]*sync.Mutex
 
+// retiredMeta holds the primary clients that were unregistered, see
+// Unregister. The entries are weak, and dropped once the client is collected.
+var retiredMeta sync.Map // map[weak.Pointer[keycloak.KeycloakClient]]struct{}
+
+// pools maps the cache key of a configuration (see
+// keycloaksession.ConfigCacheKey) to its Pool, for callers that only know the
+// configuration, such as the identifying-properties lookups.
+var pools sync.Map // map[string]*Pool
+
+// ErrNoPool is returned by BorrowByKey if no pool is registered for the
+// configuration, e.g. because it was evicted since the provider setup.
+var ErrNoPool = errors.New("no keycloak client pool for the provider configuration")
+
 // Register associates a primary client (the schema callback meta) with its
 // pool. Both must be non-nil.
 func Register(meta *keycloak.KeycloakClient, p *Pool) {
@@ -31,15 +47,53 @@ func Register(meta *keycloak.KeycloakClient, p *Pool) {
 		return
 	}
 	registry.Store(meta, p)
+	retiredMeta.Delete(weak.Make(meta))
 }
 
-// Unregister removes the pool association for a primary client.
+// Unregister removes the pool association for a primary client, which is
+// retired: callbacks that still carry it as their meta fail with
+// ErrPoolClosed instead of falling back to it, since it is logged out.
 func Unregister(meta *keycloak.KeycloakClient) {
 	if meta == nil {
 		return
 	}
 	registry.Delete(meta)
 	fallbackMu.Delete(meta)
+	wp := weak.Make(meta)
+	if _, loaded := retiredMeta.LoadOrStore(wp, struct{}{}); !loaded {
+		runtime.AddCleanup(meta, func(wp weak.Pointer[keycloak.KeycloakClient]) { retiredMeta.Delete(wp) }, wp)
+	}
+}
+
+// RegisterKey associates the cache key of a configuration with its pool.
+func RegisterKey(key string, p *Pool) {
+	if p == nil {
+		return
+	}
+	pools.Store(key, p)
+}
+
+// UnregisterKey removes the association of a configuration's cache key with
+// the given pool. It is a no-op if the key was registered with another pool
+// since.
+func UnregisterKey(key string, p *Pool) {
+	pools.CompareAndDelete(key, p)
+}
+
+// BorrowByKey borrows a client from the pool of the configuration with the
+// given cache key, see Pool.Borrow. The returned release function must be
+// called exactly once, with the error the operation ended with.
+func BorrowByKey(ctx context.Context, key string) (*keycloak.KeycloakClient, func(error), error) {
+	v, ok := pools.Load(key)
+	if !ok {
+		return nil, func(error) {}, ErrNoPool
+	}
+	pool := v.(*Pool)
+	c, err := pool.Borrow(ctx)
+	if err != nil {
+		return nil, func(error) {}, err
+	}
+	return c, func(err error) { pool.Release(c, err) }, nil
 }
 
 func poolFor(meta any) *Pool {
@@ -57,25 +111,40 @@ func poolFor(meta any) *Pool {
 // shared meta. When a pool is registered it borrows a dedicated client (so the
 // callback runs concurrently with others, each on its own client). When no pool
 // is registered it returns the original meta guarded by a per-client mutex so
-// the callback is still serialized and therefore race-free. The returned
-// release function must always be called (defer) exactly once.
-func borrow(ctx context.Context, meta any) (client any, release func(), err error) {
+// the callback is still serialized and therefore race-free, unless the meta is
+// a retired primary client, see Unregister. The returned
+// release function must always be called (defer) exactly once, with the
+// error the callback ended with.
+func borrow(ctx context.Context, meta any) (client any, release func(error), err error) {
 	if pool := poolFor(meta); pool != nil {
 		c, berr := pool.Borrow(ctx)
 		if berr != nil {
-			return nil, func() {}, berr
+			return nil, func(error) {}, berr
 		}
-		return c, func() { pool.Return(c) }, nil
+		return c, func(err error) { pool.Release(c, err) }, nil
 	}
 
 	kc, ok := meta.(*keycloak.KeycloakClient)
 	if !ok || kc == nil {
-		return meta, func() {}, nil
+		return meta, func(error) {}, nil
+	}
+	if _, ok := retiredMeta.Load(weak.Make(kc)); ok {
+		return nil, func(error) {}, ErrPoolClosed
 	}
 	v, _ := fallbackMu.LoadOrStore(kc, &sync.Mutex{})
 	m := v.(*sync.Mutex)
 	m.Lock()
-	return meta, m.Unlock, nil
+	return meta, func(error) { m.Unlock() }, nil
+}
+
+// diagsError returns the first error of diags, or nil if there is none.
+func diagsError(diags diag.Diagnostics) error {
+	for _, d := range diags {
+		if d.Severity == diag.Error {
+			return errors.New(d.Summary + ": " + d.Detail)
+		}
+	}
+	return nil
 }
 
 // WrapProvider wraps every resource and data source in p so that their
@@ -130,12 +199,12 @@ func wrapContext(orig func(context.Context, *schema.ResourceData, any) diag.Diag
 	if orig == nil {
 		return nil
 	}
-	return func(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	return func(ctx context.Context, d *schema.ResourceData, meta any) (diags diag.Diagnostics) {
 		client, release, err := borrow(ctx, meta)
 		if err != nil {
 			return diag.FromErr(err)
 		}
-		defer release()
+		defer func() { release(diagsError(diags)) }()
 		return orig(ctx, d, client)
 	}
 }
@@ -144,12 +213,12 @@ func wrapCustomizeDiff(orig func(context.Context, *schema.ResourceDiff, any) err
 	if orig == nil {
 		return nil
 	}
-	return func(ctx context.Context, d *schema.ResourceDiff, meta any) error {
+	return func(ctx context.Context, d *schema.ResourceDiff, meta any) (err error) {
 		client, release, err := borrow(ctx, meta)
 		if err != nil {
 			return err
 		}
-		defer release()
+		defer func() { release(err) }()
 		return orig(ctx, d, client)
 	}
 }
@@ -158,12 +227,12 @@ func wrapStateContext(orig func(context.Context, *schema.ResourceData, any) ([]*
 	if orig == nil {
 		return nil
 	}
-	return func(ctx context.Context, d *schema.ResourceData, meta any) ([]*schema.ResourceData, error) {
+	return func(ctx context.Context, d *schema.ResourceData, meta any) (_ []*schema.ResourceData, err error) {
 		client, release, err := borrow(ctx, meta)
 		if err != nil {
 			return nil, err
 		}
-		defer release()
+		defer func() { release(err) }()
 		return orig(ctx, d, client)
 	}
 }
@@ -47,6 +47,60 @@ func TestWrapResourceSubstitutesPooledClient(t *testing.T) {
 	}
 }
 
+func TestWrapResourceRefusesRetiredPrimary(t *testing.T) {
+	primary := newOfflineClient(t)
+	Register(primary, NewPool(1, offlineFactory()))
+	Unregister(primary)
+
+	called := false
+	r := &schema.Resource{
+		ReadContext: func(context.Context, *schema.ResourceData, any) diag.Diagnostics {
+			called = true
+			return nil
+		},
+	}
+	WrapResource(r)
+
+	// A callback that still carries the retired primary does not fall back
+	// to it, since it is logged out.
+	if diags := r.ReadContext(context.Background(), nil, primary); !diags.HasError() {
+		t.Fatal("expected the callback of a retired primary client to fail")
+	}
+	if called {
+		t.Error("callback ran on a retired primary client")
+	}
+}
+
+func TestBorrowByKey(t *testing.T) {
+	ctx := context.Background()
+	if _, release, err := BorrowByKey(ctx, "unknown"); err != ErrNoPool {
+		release(err)
+		t.Fatalf("BorrowByKey of an unregistered key: got %v, want ErrNoPool", err)
+	}
+
+	pool := NewPool(1, offlineFactory())
+	RegisterKey("config", pool)
+	c, release, err := BorrowByKey(ctx, "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release(nil)
+	if got := pool.Clients(); len(got) != 1 || got[0] != c {
+		t.Fatal("expected the client to be borrowed from the registered pool")
+	}
+
+	UnregisterKey("config", NewPool(1, offlineFactory()))
+	if _, release, err := BorrowByKey(ctx, "config"); err != nil {
+		t.Fatalf("expected unregistering another pool to keep the key registered: %v", err)
+	} else {
+		release(nil)
+	}
+	UnregisterKey("config", pool)
+	if _, _, err := BorrowByKey(ctx, "config"); err != ErrNoPool {
+		t.Fatalf("BorrowByKey after UnregisterKey: got %v, want ErrNoPool", err)
+	}
+}
+
 // TestWrapResourceAllowsBoundedConcurrency proves the fix preserves concurrency
 // (more than one callback runs at once) while bounding it to the pool size.
 func TestWrapResourceAllowsBoundedConcurrency(t *testing.T) {